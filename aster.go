@@ -18,10 +18,13 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"os"
 	"sync"
+	"time"
 
 	"github.com/mgilbir/aster/internal/resvg"
 	"github.com/mgilbir/aster/internal/runtime"
+	"github.com/mgilbir/aster/internal/svgdoc"
 	"github.com/mgilbir/aster/internal/textmeasure"
 	"github.com/mgilbir/aster/internal/textmeasure/fonts/liberation"
 )
@@ -32,6 +35,11 @@ type Converter struct {
 	measurer *textmeasure.Measurer
 	fonts    []fontEntry // stashed for lazy PNG renderer init
 	loader   Loader      // stashed for Close()
+	cfg      *config     // stashed for render-time guards and options
+
+	renderLoader *renderLoader   // switches to WithRenderLoader loaders
+	prefetcher   *prefetchLoader // nil unless WithPrefetch is set
+	clamps       []string        // WithScaleClamp notices of the current call
 
 	pngOnce     sync.Once
 	pngRenderer *resvg.Renderer
@@ -44,6 +52,9 @@ func New(opts ...Option) (*Converter, error) {
 	for _, opt := range opts {
 		opt(cfg)
 	}
+	if len(cfg.schemeLoaders) > 0 {
+		cfg.loader = &SchemeLoader{Loaders: cfg.schemeLoaders, Default: cfg.loader}
+	}
 
 	var measurer *textmeasure.Measurer
 	var tm runtime.TextMeasurer
@@ -52,12 +63,27 @@ func New(opts ...Option) (*Converter, error) {
 		if cfg.systemFonts {
 			measurerOpts = append(measurerOpts, textmeasure.WithSystemFonts())
 		}
+		if cfg.noEmbeddedFonts {
+			measurerOpts = append(measurerOpts, textmeasure.WithoutEmbeddedFonts())
+		}
 		for _, f := range cfg.fonts {
 			measurerOpts = append(measurerOpts, textmeasure.WithFont(f.family, f.data))
 		}
 		if cfg.defaultFontFamily != "" {
 			measurerOpts = append(measurerOpts, textmeasure.WithDefaultFontFamily(cfg.defaultFontFamily))
 		}
+		for from, to := range cfg.fontAliases {
+			measurerOpts = append(measurerOpts, textmeasure.WithFontAlias(from, to))
+		}
+		if len(cfg.fontFeatures) > 0 {
+			measurerOpts = append(measurerOpts, textmeasure.WithFontFeatures(cfg.fontFeatures...))
+		}
+		if cfg.fontParser != nil {
+			measurerOpts = append(measurerOpts, textmeasure.WithFontParser(cfg.fontParser))
+		}
+		if cfg.textMetricsMode == TextMetricsCanvas {
+			measurerOpts = append(measurerOpts, textmeasure.WithMetricsMode(textmeasure.MetricsRoundedGlyphs))
+		}
 		var err error
 		measurer, err = textmeasure.New(measurerOpts...)
 		if err != nil {
@@ -66,14 +92,41 @@ func New(opts ...Option) (*Converter, error) {
 		tm = measurer
 	}
 
+	// The runtime loads through renderLoader, so a render can swap in its
+	// WithRenderLoader loader, and with WithPrefetch through a cache of data
+	// fetched ahead of each render.
+	rl := &renderLoader{base: cfg.loader}
+	var loader runtime.Loader = rl
+	var prefetcher *prefetchLoader
+	if cfg.prefetchWorkers > 0 {
+		prefetcher = &prefetchLoader{Loader: rl}
+		loader = prefetcher
+	}
+
 	rtCfg := runtime.Config{
-		Loader:       cfg.loader,
-		TextMeasurer: tm,
-		Theme:        cfg.theme,
-		MemoryLimit:  int(cfg.memoryLimit),
-		Timeout:      cfg.timeout,
-		Version:      cfg.vegaLiteVersion,
-		Timezone:     cfg.timezone,
+		Loader:         loader,
+		TextMeasurer:   tm,
+		Theme:          cfg.theme,
+		MemoryLimit:    int(cfg.memoryLimit),
+		StackSize:      cfg.stackSize,
+		Timeout:        cfg.timeout,
+		Version:        cfg.vegaLiteVersion,
+		Timezone:       cfg.timezone,
+		LogLevel:       cfg.logLevel,
+		Now:            cfg.fixedNow,
+		MaxTimerDepth:  cfg.maxTimerDepth,
+		FullPrecision:  cfg.fullPrecision,
+		StrictWarnings: cfg.strictWarnings,
+		StrictFonts:    cfg.strictFonts,
+		NonFinite:      cfg.nonFiniteNumbers,
+		DataBaseURL:    cfg.dataBaseURL,
+		NativeTooltips: cfg.nativeTooltips,
+		Modules:        cfg.modules,
+	}
+
+	if !cfg.trustedSpec {
+		rtCfg.MaxRequests = cfg.maxRequests
+		rtCfg.MaxMarks = cfg.maxMarks
 	}
 
 	rt, err := runtime.New(rtCfg)
@@ -82,10 +135,13 @@ func New(opts ...Option) (*Converter, error) {
 	}
 
 	return &Converter{
-		rt:       rt,
-		measurer: measurer,
-		fonts:    cfg.fonts,
-		loader:   cfg.loader,
+		rt:           rt,
+		measurer:     measurer,
+		fonts:        cfg.fonts,
+		loader:       cfg.loader,
+		cfg:          cfg,
+		renderLoader: rl,
+		prefetcher:   prefetcher,
 	}, nil
 }
 
@@ -111,17 +167,85 @@ func (c *Converter) Close() error {
 }
 
 // VegaToSVG renders a Vega spec (JSON) to an SVG string.
-func (c *Converter) VegaToSVG(spec []byte) (string, error) {
-	return c.rt.VegaToSVG(string(spec))
+func (c *Converter) VegaToSVG(spec []byte, opts ...RenderOption) (string, error) {
+	opts = c.withSource(opts, spec)
+	defer c.useRenderOptions(opts)()
+	start := time.Now()
+	spec, err := c.prepareVega(spec)
+	if err != nil {
+		return "", err
+	}
+	if err := c.prefetchVega(spec); err != nil {
+		return "", err
+	}
+	defer c.clearPrefetched()
+	svg, err := c.rt.VegaToSVG(string(spec))
+	if err != nil {
+		return "", err
+	}
+	if err := c.checkSVGSize(svg); err != nil {
+		return "", err
+	}
+	svg, err = c.postProcessSVG(svg, defaultRenderConfig(opts))
+	if err != nil {
+		return "", err
+	}
+	c.reportMetrics(start, 0)
+	return svg, nil
 }
 
 // VegaLiteToSVG renders a Vega-Lite spec (JSON) to an SVG string.
-func (c *Converter) VegaLiteToSVG(spec []byte) (string, error) {
-	return c.rt.VegaLiteToSVG(string(spec))
+func (c *Converter) VegaLiteToSVG(spec []byte, opts ...RenderOption) (string, error) {
+	opts = c.withSource(opts, spec)
+	defer c.useRenderOptions(opts)()
+	start := time.Now()
+	spec, err := c.prepareVegaLite(spec)
+	if err != nil {
+		return "", err
+	}
+	svg, err := c.vegaLiteSVG(spec)
+	if err != nil {
+		return "", err
+	}
+	if err := c.checkSVGSize(svg); err != nil {
+		return "", err
+	}
+	svg, err = c.postProcessSVG(svg, defaultRenderConfig(opts))
+	if err != nil {
+		return "", err
+	}
+	c.reportMetrics(start, 0)
+	return svg, nil
 }
 
 // VegaLiteToVega compiles a Vega-Lite spec (JSON) to a full Vega spec (JSON).
+// Under WithNonFiniteNumbers, non-finite numbers in the result are written
+// as bare NaN, Infinity and -Infinity, like the input. Under WithSortedKeys,
+// object keys are sorted.
 func (c *Converter) VegaLiteToVega(spec []byte) ([]byte, error) {
+	result, err := c.compileVegaLite(spec)
+	if err != nil {
+		return nil, err
+	}
+	if c.cfg.sortedKeys {
+		if result, err = sortKeys(result); err != nil {
+			return nil, err
+		}
+	}
+	if c.cfg.nonFiniteNumbers {
+		result = unquoteNonFinite(result)
+	}
+	return result, nil
+}
+
+// compileVegaLite compiles a Vega-Lite spec to Vega for internal use: any
+// non-finite numbers stay placeholder strings, so the result always decodes
+// with encoding/json and can be passed back to the runtime.
+func (c *Converter) compileVegaLite(spec []byte) ([]byte, error) {
+	spec, err := c.prepareVegaLite(spec)
+	if err != nil {
+		return nil, err
+	}
 	result, err := c.rt.VegaLiteToVega(string(spec))
 	if err != nil {
 		return nil, err
@@ -129,37 +253,207 @@ func (c *Converter) VegaLiteToVega(spec []byte) ([]byte, error) {
 	return []byte(result), nil
 }
 
+// CompileMany compiles a batch of Vega-Lite specs to Vega, reusing the
+// Converter's runtime for all of them. The results and errors are indexed
+// like specs: a spec that fails to compile has a nil result and a non-nil
+// error, and does not stop the rest of the batch. Compilation never fetches
+// data or measures text, so a Converter created with
+// WithTextMeasurement(false) is sufficient.
+func (c *Converter) CompileMany(specs [][]byte) ([][]byte, []error) {
+	results := make([][]byte, len(specs))
+	errs := make([]error, len(specs))
+	for i, spec := range specs {
+		results[i], errs[i] = c.VegaLiteToVega(spec)
+	}
+	return results, errs
+}
+
 // VegaToPNG renders a Vega spec (JSON) to a PNG image.
+// SVG post-processors run before rasterization; SVG output options (such as
+// WithResponsiveSVG) do not apply.
 func (c *Converter) VegaToPNG(spec []byte, opts ...PNGOption) ([]byte, error) {
-	svg, err := c.VegaToSVG(spec)
+	defer c.useRenderOptions(opts)()
+	start := time.Now()
+	spec, err := c.prepareVega(spec)
 	if err != nil {
 		return nil, err
 	}
-	return c.SVGToPNG(svg, opts...)
+	if err := c.prefetchVega(spec); err != nil {
+		return nil, err
+	}
+	defer c.clearPrefetched()
+	svg, err := c.rt.VegaToSVG(string(spec))
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkSVGSize(svg); err != nil {
+		return nil, err
+	}
+	svg, err = c.runSVGPostProcessors(svg)
+	if err != nil {
+		return nil, err
+	}
+	rasterStart := time.Now()
+	png, err := c.SVGToPNG(svg, opts...)
+	if err != nil {
+		return nil, err
+	}
+	c.reportMetrics(start, time.Since(rasterStart))
+	return png, nil
 }
 
 // VegaLiteToPNG renders a Vega-Lite spec (JSON) to a PNG image.
+// SVG post-processors run before rasterization; SVG output options (such as
+// WithResponsiveSVG) do not apply.
 func (c *Converter) VegaLiteToPNG(spec []byte, opts ...PNGOption) ([]byte, error) {
-	svg, err := c.VegaLiteToSVG(spec)
+	defer c.useRenderOptions(opts)()
+	start := time.Now()
+	spec, err := c.prepareVegaLite(spec)
 	if err != nil {
 		return nil, err
 	}
-	return c.SVGToPNG(svg, opts...)
+	svg, err := c.vegaLiteSVG(spec)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkSVGSize(svg); err != nil {
+		return nil, err
+	}
+	svg, err = c.runSVGPostProcessors(svg)
+	if err != nil {
+		return nil, err
+	}
+	rasterStart := time.Now()
+	png, err := c.SVGToPNG(svg, opts...)
+	if err != nil {
+		return nil, err
+	}
+	c.reportMetrics(start, time.Since(rasterStart))
+	return png, nil
+}
+
+// VegaLiteToSVGAndPNG renders a Vega-Lite spec (JSON) once and returns it
+// both as an SVG string and as a PNG image rasterized from that SVG, which
+// is cheaper than calling VegaLiteToSVG and VegaLiteToPNG. The options apply
+// to each output as they would in those methods: SVG output options shape
+// only the SVG, and WithScale only the PNG.
+func (c *Converter) VegaLiteToSVGAndPNG(spec []byte, opts ...RenderOption) (string, []byte, error) {
+	opts = c.withSource(opts, spec)
+	defer c.useRenderOptions(opts)()
+	start := time.Now()
+	spec, err := c.prepareVegaLite(spec)
+	if err != nil {
+		return "", nil, err
+	}
+	svg, err := c.vegaLiteSVG(spec)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := c.checkSVGSize(svg); err != nil {
+		return "", nil, err
+	}
+	svg, err = c.runSVGPostProcessors(svg)
+	if err != nil {
+		return "", nil, err
+	}
+	rasterStart := time.Now()
+	png, err := c.SVGToPNG(svg, opts...)
+	if err != nil {
+		return "", nil, err
+	}
+	raster := time.Since(rasterStart)
+	svg = c.svgOutput(svg, defaultRenderConfig(opts))
+	c.reportMetrics(start, raster)
+	return svg, png, nil
+}
+
+// VegaLiteToPNGMulti renders a Vega-Lite spec (JSON) once and rasterizes the
+// SVG at each of scales, returning the PNGs keyed by scale: a thumbnail, a
+// full-size and a retina image cost one spec evaluation instead of three.
+// The options apply as in VegaLiteToPNG, each scale taking the place of
+// WithScale. Repeated scales are rendered once; a scale that is not
+// positive is an error.
+func (c *Converter) VegaLiteToPNGMulti(spec []byte, scales []float64, opts ...PNGOption) (map[float64][]byte, error) {
+	for _, scale := range scales {
+		if !(scale > 0) {
+			return nil, fmt.Errorf("aster: PNG scale must be positive, got %v", scale)
+		}
+	}
+	defer c.useRenderOptions(opts)()
+	start := time.Now()
+	spec, err := c.prepareVegaLite(spec)
+	if err != nil {
+		return nil, err
+	}
+	svg, err := c.vegaLiteSVG(spec)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkSVGSize(svg); err != nil {
+		return nil, err
+	}
+	svg, err = c.runSVGPostProcessors(svg)
+	if err != nil {
+		return nil, err
+	}
+	rasterStart := time.Now()
+	pngs := make(map[float64][]byte, len(scales))
+	for _, scale := range scales {
+		if _, done := pngs[scale]; done {
+			continue
+		}
+		png, err := c.SVGToPNG(svg, append(opts[:len(opts):len(opts)], WithScale(scale))...)
+		if err != nil {
+			return nil, err
+		}
+		pngs[scale] = png
+	}
+	c.reportMetrics(start, time.Since(rasterStart))
+	return pngs, nil
 }
 
+// ResvgError is the error returned when resvg fails to render an SVG, for
+// instance because it is malformed. Message is resvg's own message, naming
+// the offending element and position.
+type ResvgError = resvg.Error
+
 // SVGToPNG converts an SVG string to a PNG image using resvg.
+// It is safe to call from multiple goroutines; rasterizations on the same
+// Converter run one at a time. An SVG resvg cannot render fails with a
+// *ResvgError.
 func (c *Converter) SVGToPNG(svg string, opts ...PNGOption) ([]byte, error) {
-	cfg := defaultPNGConfig()
-	for _, opt := range opts {
-		opt(cfg)
-	}
+	cfg := defaultRenderConfig(opts)
 
 	r, err := c.pngRendererInit()
 	if err != nil {
 		return nil, err
 	}
 
-	return r.Render(context.Background(), []byte(svg), cfg.scale)
+	scale := cfg.scale
+	if fit, ok := fitScale(svg, cfg); ok {
+		scale = fit
+	}
+	svg = svgdoc.ReplaceFontFamilies(svg, c.cfg.fontAliases)
+	switch cfg.antialiasing {
+	case AntialiasText:
+		svg = svgdoc.SetRootAttr(svg, "shape-rendering", "crispEdges")
+	case AntialiasNone:
+		svg = svgdoc.SetRootAttr(svg, "shape-rendering", "crispEdges")
+		svg = svgdoc.SetRootAttr(svg, "text-rendering", "optimizeSpeed")
+	}
+	data, err := r.Render(context.Background(), []byte(svg), scale)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.trim {
+		if data, err = trimPNG(data); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.dpiMetadata {
+		return setPNGDPI(data, scale*cssDPI)
+	}
+	return data, nil
 }
 
 // pngRendererInit lazily initializes the PNG renderer on first use.
@@ -167,25 +461,44 @@ func (c *Converter) pngRendererInit() (*resvg.Renderer, error) {
 	c.pngOnce.Do(func() {
 		// Build font list: embedded Liberation Sans + custom fonts.
 		var fonts []resvg.Font
-		fonts = append(fonts,
-			resvg.Font{Data: liberation.SansRegular},
-			resvg.Font{Data: liberation.SansBold},
-			resvg.Font{Data: liberation.SansItalic},
-			resvg.Font{Data: liberation.SansBoldItalic},
-			resvg.Font{Data: liberation.MonoRegular},
-			resvg.Font{Data: liberation.MonoBold},
-			resvg.Font{Data: liberation.MonoItalic},
-			resvg.Font{Data: liberation.MonoBoldItalic},
-		)
+		if !c.cfg.noEmbeddedFonts {
+			fonts = append(fonts,
+				resvg.Font{Data: liberation.SansRegular},
+				resvg.Font{Data: liberation.SansBold},
+				resvg.Font{Data: liberation.SansItalic},
+				resvg.Font{Data: liberation.SansBoldItalic},
+				resvg.Font{Data: liberation.MonoRegular},
+				resvg.Font{Data: liberation.MonoBold},
+				resvg.Font{Data: liberation.MonoItalic},
+				resvg.Font{Data: liberation.MonoBoldItalic},
+			)
+		}
+		// Match the measurer's font set: system fonts rank between the
+		// embedded and custom fonts.
+		if c.cfg.systemFonts {
+			files, err := textmeasure.SystemFontFiles()
+			if err != nil {
+				c.pngErr = fmt.Errorf("aster: initializing PNG renderer: %w", err)
+				return
+			}
+			for _, file := range files {
+				if data, err := os.ReadFile(file); err == nil {
+					fonts = append(fonts, resvg.Font{Data: data})
+				}
+			}
+		}
 		for _, f := range c.fonts {
 			fonts = append(fonts, resvg.Font{Data: f.data})
 		}
 
-		families := resvg.FamilyMapping{
-			SansSerif: "Liberation Sans",
-			Monospace: "Liberation Mono",
+		families := c.cfg.pngFamilies
+		if families.SansSerif == "" && !c.cfg.noEmbeddedFonts {
+			families.SansSerif = "Liberation Sans"
+		}
+		if families.Monospace == "" && !c.cfg.noEmbeddedFonts {
+			families.Monospace = "Liberation Mono"
 		}
-		c.pngRenderer, c.pngErr = resvg.New(context.Background(), fonts, families)
+		c.pngRenderer, c.pngErr = resvg.New(context.Background(), fonts, families, c.cfg.renderLanguages)
 		if c.pngErr != nil {
 			c.pngErr = fmt.Errorf("aster: initializing PNG renderer: %w", c.pngErr)
 		}
@@ -1,6 +1,9 @@
 package aster_test
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"math"
 	"net/http"
 	"net/http/httptest"
@@ -10,8 +13,10 @@ import (
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/mgilbir/aster"
+	"github.com/mgilbir/aster/internal/textmeasure"
 )
 
 // normalizeSVGNumbers rounds all floating-point numbers in an SVG string to
@@ -102,6 +107,38 @@ func TestVegaLiteToVega(t *testing.T) {
 	}
 }
 
+func TestCompileMany(t *testing.T) {
+	spec, err := os.ReadFile("testdata/bar-chart.vl.json")
+	if err != nil {
+		t.Fatalf("reading test spec: %v", err)
+	}
+
+	c, err := aster.New(aster.WithTextMeasurement(false))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	results, errs := c.CompileMany([][]byte{spec, []byte("not json"), spec})
+	if len(results) != 3 || len(errs) != 3 {
+		t.Fatalf("expected 3 results and errors, got %d and %d", len(results), len(errs))
+	}
+	for _, i := range []int{0, 2} {
+		if errs[i] != nil {
+			t.Errorf("spec %d: unexpected error: %v", i, errs[i])
+		}
+		if !strings.Contains(string(results[i]), `"$schema"`) {
+			t.Errorf("spec %d: expected a Vega spec, got %.100s", i, results[i])
+		}
+	}
+	if errs[1] == nil {
+		t.Error("spec 1: expected an error for invalid JSON")
+	}
+	if results[1] != nil {
+		t.Errorf("spec 1: expected nil result, got %.100s", results[1])
+	}
+}
+
 func TestDenyLoaderPreventsLoading(t *testing.T) {
 	// The default DenyLoader should prevent any data loading.
 	// A spec with inline data should still work.
@@ -145,6 +182,324 @@ func TestNoTextMeasurement(t *testing.T) {
 	}
 }
 
+func TestWithLogLevel(t *testing.T) {
+	spec, err := os.ReadFile("testdata/bar-chart.vl.json")
+	if err != nil {
+		t.Fatalf("reading test spec: %v", err)
+	}
+
+	for _, level := range []string{"none", "error", "warn", "info", "debug"} {
+		t.Run(level, func(t *testing.T) {
+			c, err := aster.New(aster.WithLogLevel(level))
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			defer func() { _ = c.Close() }()
+
+			svg, err := c.VegaLiteToSVG(spec)
+			if err != nil {
+				t.Fatalf("VegaLiteToSVG: %v", err)
+			}
+			if !strings.HasPrefix(svg, "<svg") {
+				t.Errorf("expected SVG output starting with <svg, got: %.100s", svg)
+			}
+		})
+	}
+}
+
+func TestWithLogLevelUnknown(t *testing.T) {
+	_, err := aster.New(aster.WithLogLevel("verbose"))
+	if err == nil || !strings.Contains(err.Error(), `unknown log level "verbose"`) {
+		t.Fatalf("expected unknown log level error, got %v", err)
+	}
+}
+
+func TestWithStrictWarnings(t *testing.T) {
+	// Bars have no shape channel, so Vega-Lite drops it with a warning.
+	spec := []byte(`{
+		"data": {"values": [{"a": "A", "b": 1}]},
+		"mark": "bar",
+		"encoding": {
+			"x": {"field": "a", "type": "nominal"},
+			"y": {"field": "b", "type": "quantitative"},
+			"shape": {"field": "a", "type": "nominal"}
+		}
+	}`)
+
+	lenient, err := aster.New(aster.WithLogLevel("none"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = lenient.Close() }()
+	if _, err := lenient.VegaLiteToSVG(spec); err != nil {
+		t.Fatalf("VegaLiteToSVG: %v", err)
+	}
+	if w := lenient.Warnings(); len(w) == 0 || !strings.Contains(strings.Join(w, "\n"), "shape") {
+		t.Errorf("expected a shape warning to be captured at log level none, got %q", w)
+	}
+
+	strict, err := aster.New(aster.WithStrictWarnings())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = strict.Close() }()
+	_, err = strict.VegaLiteToSVG(spec)
+	var warnErr *aster.WarningsError
+	if !errors.As(err, &warnErr) || len(warnErr.Warnings) == 0 {
+		t.Fatalf("expected a *WarningsError, got %v", err)
+	}
+
+	clean := []byte(`{"data": {"values": [{"a": 1}]}, "mark": "point", "encoding": {"x": {"field": "a", "type": "quantitative"}}}`)
+	if _, err := strict.VegaLiteToSVG(clean); err != nil {
+		t.Errorf("a spec without warnings should render in strict mode: %v", err)
+	}
+}
+
+func TestWithFixedNow(t *testing.T) {
+	spec := []byte(`{
+		"$schema": "https://vega.github.io/schema/vega/v5.json",
+		"width": 200,
+		"height": 50,
+		"marks": [{
+			"type": "text",
+			"encode": {"enter": {
+				"x": {"value": 10},
+				"y": {"value": 20},
+				"text": {"signal": "utcFormat(now(), '%Y-%m-%d %H:%M')"}
+			}}
+		}]
+	}`)
+
+	now := time.Date(2024, time.March, 15, 9, 30, 0, 0, time.UTC)
+	c, err := aster.New(aster.WithFixedNow(now))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	first, err := c.VegaToSVG(spec)
+	if err != nil {
+		t.Fatalf("VegaToSVG: %v", err)
+	}
+	if !strings.Contains(first, "2024-03-15 09:30") {
+		t.Errorf("expected the fixed time in the output, got: %s", first)
+	}
+
+	second, err := c.VegaToSVG(spec)
+	if err != nil {
+		t.Fatalf("VegaToSVG: %v", err)
+	}
+	if first != second {
+		t.Error("renders with a fixed clock should be identical")
+	}
+}
+
+func TestTruncatedLabelWidth(t *testing.T) {
+	const limit = 60.0
+	spec := []byte(`{
+		"data": {"values": [
+			{"cat": "An extremely long category label", "v": 1},
+			{"cat": "Another very long category name", "v": 2}
+		]},
+		"mark": "bar",
+		"encoding": {
+			"x": {"field": "cat", "type": "nominal", "axis": {"labelLimit": 60, "labelAngle": 0}},
+			"y": {"field": "v", "type": "quantitative"}
+		}
+	}`)
+
+	c, err := aster.New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	svg, err := c.VegaLiteToSVG(spec)
+	if err != nil {
+		t.Fatalf("VegaLiteToSVG: %v", err)
+	}
+
+	m, err := textmeasure.New()
+	if err != nil {
+		t.Fatalf("textmeasure.New: %v", err)
+	}
+	labels := regexp.MustCompile(`>([^<>]*…)</text>`).FindAllStringSubmatch(svg, -1)
+	if len(labels) != 2 {
+		t.Fatalf("expected 2 truncated labels, got %d", len(labels))
+	}
+	for _, l := range labels {
+		// Axis labels default to 10px sans-serif. The ellipsis counts toward
+		// the limit, and truncation keeps as much of the label as fits.
+		w := m.MeasureText(l[1], "10px sans-serif")
+		if w > limit {
+			t.Errorf("label %q is %.2fpx wide, over the %vpx limit", l[1], w, limit)
+		}
+		if w < limit-10 {
+			t.Errorf("label %q is %.2fpx wide, truncated well short of the %vpx limit", l[1], w, limit)
+		}
+	}
+}
+
+func TestVegaLiteToData(t *testing.T) {
+	spec, err := os.ReadFile("testdata/bar-chart.vl.json")
+	if err != nil {
+		t.Fatalf("reading test spec: %v", err)
+	}
+
+	c, err := aster.New(aster.WithTextMeasurement(false))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	data, err := c.VegaLiteToData(spec, "json")
+	if err != nil {
+		t.Fatalf("VegaLiteToData json: %v", err)
+	}
+	var rows []map[string]any
+	if err := json.Unmarshal(data, &rows); err != nil {
+		t.Fatalf("decoding JSON output: %v", err)
+	}
+	if len(rows) != 5 {
+		t.Fatalf("expected 5 rows, got %d", len(rows))
+	}
+	if rows[0]["category"] != "A" {
+		t.Errorf("expected first category A, got %v", rows[0]["category"])
+	}
+
+	data, err = c.VegaLiteToData(spec, "csv")
+	if err != nil {
+		t.Fatalf("VegaLiteToData csv: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 6 {
+		t.Fatalf("expected header + 5 rows, got %d lines:\n%s", len(lines), data)
+	}
+	if !strings.HasPrefix(lines[0], "category,value") {
+		t.Errorf("unexpected CSV header: %s", lines[0])
+	}
+
+	if _, err := c.VegaLiteToData(spec, "xml"); err == nil {
+		t.Error("expected error for unsupported format")
+	}
+}
+
+func TestSignals(t *testing.T) {
+	spec := []byte(`{
+		"$schema": "https://vega.github.io/schema/vega/v5.json",
+		"width": 200,
+		"height": 100,
+		"signals": [
+			{"name": "barWidth", "value": 12},
+			{"name": "doubled", "init": "barWidth * 2"},
+			{"name": "label", "value": "hello"}
+		]
+	}`)
+
+	c, err := aster.New(aster.WithTextMeasurement(false))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	signals, err := c.Signals(spec)
+	if err != nil {
+		t.Fatalf("Signals: %v", err)
+	}
+	want := map[string]any{
+		"barWidth": 12.0,
+		"doubled":  24.0,
+		"label":    "hello",
+		"width":    200.0,
+		"height":   100.0,
+	}
+	for name, v := range want {
+		if got, ok := signals[name]; !ok || got != v {
+			t.Errorf("signal %q = %v (present %v), want %v", name, got, ok, v)
+		}
+	}
+}
+
+func TestSignalsWithTheme(t *testing.T) {
+	spec := []byte(`{"$schema": "https://vega.github.io/schema/vega/v5.json", "width": 200, "height": 100}`)
+
+	c, err := aster.New(aster.WithTextMeasurement(false), aster.WithTheme(`{"padding": 17}`))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	signals, err := c.Signals(spec)
+	if err != nil {
+		t.Fatalf("Signals: %v", err)
+	}
+	if got := fmt.Sprint(signals["padding"]); !strings.Contains(got, "17") {
+		t.Errorf("expected the theme's padding in the padding signal, got %v", got)
+	}
+}
+
+func TestContentBounds(t *testing.T) {
+	spec := []byte(`{
+		"$schema": "https://vega.github.io/schema/vega/v5.json",
+		"width": 200,
+		"height": 100,
+		"padding": 5,
+		"marks": [{
+			"type": "rect",
+			"encode": {"enter": {
+				"x": {"value": 10}, "y": {"value": 20},
+				"width": {"value": 30}, "height": {"value": 40},
+				"fill": {"value": "steelblue"}
+			}}
+		}]
+	}`)
+
+	c, err := aster.New(aster.WithTextMeasurement(false))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	// The rect, offset by the padding, rather than the 210x110 frame.
+	x, y, w, h, err := c.ContentBounds(spec)
+	if err != nil {
+		t.Fatalf("ContentBounds: %v", err)
+	}
+	if x != 15 || y != 25 || w != 30 || h != 40 {
+		t.Errorf("ContentBounds = %v, %v, %v, %v; want 15, 25, 30, 40", x, y, w, h)
+	}
+}
+
+func TestWithStrictFonts(t *testing.T) {
+	spec := []byte(`{
+		"title": {"text": "Sales", "font": "Unknown Font"},
+		"data": {"values": [{"a": "x", "b": 1}]},
+		"mark": "bar",
+		"encoding": {
+			"x": {"field": "a", "type": "nominal"},
+			"y": {"field": "b", "type": "quantitative"}
+		}
+	}`)
+
+	c, err := aster.New(aster.WithStrictFonts())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	_, err = c.VegaLiteToSVG(spec)
+	var fontsErr *aster.FontsError
+	if !errors.As(err, &fontsErr) || len(fontsErr.Families) != 1 || fontsErr.Families[0] != "Unknown Font" {
+		t.Fatalf("expected a FontsError for Unknown Font, got %v", err)
+	}
+
+	// The default sans-serif fonts always resolve.
+	plain := []byte(strings.Replace(string(spec), `, "font": "Unknown Font"`, "", 1))
+	if _, err := c.VegaLiteToSVG(plain); err != nil {
+		t.Errorf("VegaLiteToSVG with default fonts: %v", err)
+	}
+}
+
 // knownFailures lists specs that fail due to known runtime limitations
 // (e.g. polyfill gaps, unsupported features). These are skipped rather than
 // marked as errors so the test suite stays green while we work on fixes.
@@ -544,3 +899,58 @@ func TestVegaLiteExamples(t *testing.T) {
 		})
 	}
 }
+
+func TestUsedFonts(t *testing.T) {
+	spec := []byte(`{
+		"title": {"text": "Sales", "font": "Caveat, serif"},
+		"data": {"values": [{"a": "x", "b": 1}]},
+		"mark": "bar",
+		"encoding": {
+			"x": {"field": "a", "type": "nominal"},
+			"y": {"field": "b", "type": "quantitative"}
+		}
+	}`)
+
+	c, err := aster.New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	families, err := c.UsedFonts(spec)
+	if err != nil {
+		t.Fatalf("UsedFonts: %v", err)
+	}
+	// The title's fallback list, and the axes' default font.
+	if got := strings.Join(families, ","); got != "Caveat,sans-serif,serif" {
+		t.Errorf("UsedFonts = %q; want Caveat, sans-serif, serif", families)
+	}
+
+	// Families are reported as the measurer parsed them.
+	parsed, err := aster.New(aster.WithFontParser(func(s string) aster.CSSFont {
+		if strings.Contains(s, "Caveat") {
+			return aster.CSSFont{Size: 12, Family: []string{"Caveat Brush"}}
+		}
+		return aster.CSSFont{}
+	}))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = parsed.Close() }()
+	families, err = parsed.UsedFonts(spec)
+	if err != nil {
+		t.Fatalf("UsedFonts with WithFontParser: %v", err)
+	}
+	if got := strings.Join(families, ","); got != "Caveat Brush,sans-serif" {
+		t.Errorf("UsedFonts with WithFontParser = %q; want Caveat Brush, sans-serif", families)
+	}
+
+	estimating, err := aster.New(aster.WithTextMeasurement(false))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = estimating.Close() }()
+	if _, err := estimating.UsedFonts(spec); err == nil {
+		t.Error("expected an error without text measurement")
+	}
+}
@@ -0,0 +1,35 @@
+package aster
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ContentBounds parses a Vega spec, runs its dataflow once, and returns the
+// tight bounding box of everything the spec draws (marks, axes, legends and
+// titles) in the user coordinates of the SVG VegaToSVG renders for it. The
+// box excludes padding and any empty space autosize leaves, unlike the
+// <svg> width and height, so callers can crop to the content or line several
+// charts up by their plotting area.
+//
+// To measure a Vega-Lite spec, compile it first with VegaLiteToVega.
+func (c *Converter) ContentBounds(spec []byte) (x, y, w, h float64, err error) {
+	spec, err = c.prepareVega(spec)
+	if err != nil {
+		return 0, 0, 0, 0, err
+	}
+	if err := c.prefetchVega(spec); err != nil {
+		return 0, 0, 0, 0, err
+	}
+	defer c.clearPrefetched()
+	result, err := c.rt.ContentBounds(string(spec))
+	if err != nil {
+		return 0, 0, 0, 0, err
+	}
+
+	var b [4]float64
+	if err := json.Unmarshal([]byte(result), &b); err != nil {
+		return 0, 0, 0, 0, fmt.Errorf("aster: decoding bounds: %w", err)
+	}
+	return b[0], b[1], b[2] - b[0], b[3] - b[1], nil
+}
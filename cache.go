@@ -0,0 +1,149 @@
+package aster
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// ErrNotModified may be returned (or wrapped) by a Loader's Load to mean
+// "the copy you already have is current". A CachingLoader that holds an entry
+// for the URI answers with that entry; it signals this to the child loader
+// through the context (see HasCachedCopy). Without a cached entry,
+// ErrNotModified is returned to the caller like any other error.
+var ErrNotModified = errors.New("aster: not modified")
+
+type cachedCopyKey struct{}
+
+// HasCachedCopy reports whether the caller of Load holds a cached copy of
+// the URI, so the loader may answer ErrNotModified instead of the content.
+func HasCachedCopy(ctx context.Context) bool {
+	ok, _ := ctx.Value(cachedCopyKey{}).(bool)
+	return ok
+}
+
+// Cache stores loaded resources by URI for CachingLoader.
+type Cache interface {
+	Get(uri string) ([]byte, bool)
+	Put(uri string, data []byte) error
+}
+
+// MemoryCache is an in-process Cache. The zero value is ready to use and
+// safe for concurrent use.
+type MemoryCache struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+}
+
+func (c *MemoryCache) Get(uri string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, ok := c.entries[uri]
+	return data, ok
+}
+
+func (c *MemoryCache) Put(uri string, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string][]byte)
+	}
+	c.entries[uri] = data
+	return nil
+}
+
+// DiskCache is a Cache that keeps one file per URI in Dir, named by the
+// SHA-256 of the URI. Dir is created on first Put.
+type DiskCache struct {
+	Dir string
+}
+
+func (c *DiskCache) path(uri string) string {
+	sum := sha256.Sum256([]byte(uri))
+	return filepath.Join(c.Dir, hex.EncodeToString(sum[:]))
+}
+
+func (c *DiskCache) Get(uri string) ([]byte, bool) {
+	data, err := os.ReadFile(c.path(uri))
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+func (c *DiskCache) Put(uri string, data []byte) error {
+	if err := os.MkdirAll(c.Dir, 0o755); err != nil {
+		return fmt.Errorf("aster: DiskCache: %w", err)
+	}
+	// Write to a temp file and rename so readers never see a partial entry.
+	tmp, err := os.CreateTemp(c.Dir, "tmp-*")
+	if err != nil {
+		return fmt.Errorf("aster: DiskCache: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("aster: DiskCache: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("aster: DiskCache: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path(uri)); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("aster: DiskCache: %w", err)
+	}
+	return nil
+}
+
+// CachingLoader wraps a Loader and stores what it loads in Cache.
+//
+// By default a cached entry is served without consulting Loader. With
+// Revalidate set, Loader is always called, with HasCachedCopy(ctx) true when
+// an entry exists; it may then return ErrNotModified to reuse the entry, or
+// fresh content to replace it.
+type CachingLoader struct {
+	Loader     Loader
+	Cache      Cache
+	Revalidate bool
+}
+
+func (l *CachingLoader) Sanitize(ctx context.Context, uri string) (string, error) {
+	return l.Loader.Sanitize(ctx, uri)
+}
+
+func (l *CachingLoader) Load(ctx context.Context, uri string) ([]byte, error) {
+	cached, hit := l.Cache.Get(uri)
+	if hit && !l.Revalidate {
+		return cached, nil
+	}
+	if hit {
+		ctx = context.WithValue(ctx, cachedCopyKey{}, true)
+	}
+
+	data, err := l.Loader.Load(ctx, uri)
+	if err != nil {
+		if hit && errors.Is(err, ErrNotModified) {
+			return cached, nil
+		}
+		return nil, err
+	}
+	if err := l.Cache.Put(uri, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Close closes the wrapped Loader if it implements io.Closer.
+func (l *CachingLoader) Close() error {
+	if closer, ok := l.Loader.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
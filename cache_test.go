@@ -0,0 +1,134 @@
+package aster_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/mgilbir/aster"
+)
+
+// countingLoader serves a fixed body and counts Load calls. When notModified
+// is set it answers ErrNotModified whenever the caller holds a cached copy.
+type countingLoader struct {
+	body        string
+	notModified bool
+	calls       int
+}
+
+func (l *countingLoader) Sanitize(_ context.Context, uri string) (string, error) {
+	return uri, nil
+}
+
+func (l *countingLoader) Load(ctx context.Context, _ string) ([]byte, error) {
+	l.calls++
+	if l.notModified && aster.HasCachedCopy(ctx) {
+		return nil, aster.ErrNotModified
+	}
+	return []byte(l.body), nil
+}
+
+func TestCachingLoaderServesHits(t *testing.T) {
+	for name, cache := range map[string]aster.Cache{
+		"memory": &aster.MemoryCache{},
+		"disk":   &aster.DiskCache{Dir: t.TempDir()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			child := &countingLoader{body: "v1"}
+			l := &aster.CachingLoader{Loader: child, Cache: cache}
+			ctx := context.Background()
+
+			for i := 0; i < 2; i++ {
+				data, err := l.Load(ctx, "data.json")
+				if err != nil {
+					t.Fatalf("Load %d: %v", i, err)
+				}
+				if string(data) != "v1" {
+					t.Errorf("Load %d = %q, want v1", i, data)
+				}
+			}
+			if child.calls != 1 {
+				t.Errorf("expected 1 child load, got %d", child.calls)
+			}
+		})
+	}
+}
+
+func TestCachingLoaderRevalidateNotModified(t *testing.T) {
+	child := &countingLoader{body: "v1", notModified: true}
+	l := &aster.CachingLoader{Loader: child, Cache: &aster.MemoryCache{}, Revalidate: true}
+	ctx := context.Background()
+
+	if _, err := l.Load(ctx, "data.json"); err != nil {
+		t.Fatalf("first Load: %v", err)
+	}
+	child.body = "v2" // would be returned if the loader did not answer 304
+	data, err := l.Load(ctx, "data.json")
+	if err != nil {
+		t.Fatalf("revalidated Load: %v", err)
+	}
+	if string(data) != "v1" {
+		t.Errorf("expected cached v1 after ErrNotModified, got %q", data)
+	}
+	if child.calls != 2 {
+		t.Errorf("expected the child to be consulted twice, got %d", child.calls)
+	}
+}
+
+func TestCachingLoaderRevalidateReplaces(t *testing.T) {
+	child := &countingLoader{body: "v1"}
+	l := &aster.CachingLoader{Loader: child, Cache: &aster.MemoryCache{}, Revalidate: true}
+	ctx := context.Background()
+
+	if _, err := l.Load(ctx, "data.json"); err != nil {
+		t.Fatalf("first Load: %v", err)
+	}
+	child.body = "v2"
+	data, err := l.Load(ctx, "data.json")
+	if err != nil {
+		t.Fatalf("second Load: %v", err)
+	}
+	if string(data) != "v2" {
+		t.Errorf("expected fresh v2, got %q", data)
+	}
+}
+
+func TestCachingLoaderNotModifiedWithoutEntry(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotModified)
+	})
+	l := &aster.CachingLoader{Loader: aster.NewHandlerLoader(h, ""), Cache: &aster.MemoryCache{}}
+
+	_, err := l.Load(context.Background(), "http://localhost/data.json")
+	if !errors.Is(err, aster.ErrNotModified) {
+		t.Fatalf("expected ErrNotModified to surface without a cached entry, got %v", err)
+	}
+}
+
+func TestCachingLoaderHandlerConditional(t *testing.T) {
+	served := 0
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if aster.HasCachedCopy(r.Context()) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		served++
+		_, _ = w.Write([]byte(`[1]`))
+	})
+	l := &aster.CachingLoader{Loader: aster.NewHandlerLoader(h, ""), Cache: &aster.MemoryCache{}, Revalidate: true}
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		data, err := l.Load(ctx, "http://localhost/data.json")
+		if err != nil {
+			t.Fatalf("Load %d: %v", i, err)
+		}
+		if string(data) != `[1]` {
+			t.Errorf("Load %d = %q", i, data)
+		}
+	}
+	if served != 1 {
+		t.Errorf("expected the body to be served once, got %d", served)
+	}
+}
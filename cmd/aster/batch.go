@@ -0,0 +1,216 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mgilbir/aster"
+)
+
+// batchResult is the outcome of rendering one spec file.
+type batchResult struct {
+	out string
+	err error
+}
+
+// runBatch renders every *.json spec under an input directory to an output
+// directory, mirroring the input tree. With -jobs N the specs are spread over
+// N converters, each with its own runtime. Per-file results are reported
+// in sorted input order whatever the completion order, followed by a
+// summary. It returns an error if any spec fails.
+func runBatch(args []string, w io.Writer) error {
+	flags := flag.NewFlagSet("batch", flag.ExitOnError)
+	input := flags.String("i", "", "input directory of spec files (*.json)")
+	output := flags.String("o", "", "output directory")
+	format := flags.String("format", "svg", "output format: svg, png or pdf")
+	jobs := flags.Int("jobs", 1, "number of specs to render concurrently (at most GOMAXPROCS)")
+	loading := addLoaderFlags(flags)
+	version := addVersionFlag(flags)
+	fonts := addFontFlags(flags)
+	configFile := addConfigFlag(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *input == "" || *output == "" {
+		return fmt.Errorf("batch: -i and -o are required")
+	}
+	if err := loading.check(); err != nil {
+		return fmt.Errorf("batch: %w", err)
+	}
+	shared, err := versionOptions(*version)
+	if err != nil {
+		return fmt.Errorf("batch: %w", err)
+	}
+	fontOpts, err := fonts.options()
+	if err != nil {
+		return fmt.Errorf("batch: %w", err)
+	}
+	shared = append(shared, fontOpts...)
+	configOpts, err := configOptions(*configFile)
+	if err != nil {
+		return fmt.Errorf("batch: %w", err)
+	}
+	shared = append(shared, configOpts...)
+	if *format != "svg" && *format != "png" && *format != "pdf" {
+		return fmt.Errorf("batch: unknown format %q (expected svg, png or pdf)", *format)
+	}
+
+	files, err := specFiles(*input)
+	if err != nil {
+		return fmt.Errorf("batch: %w", err)
+	}
+	// Specs such as a.vl.json and a.json map to the same output file;
+	// rendering both would leave whichever finished last.
+	outputs := make([]string, len(files))
+	byOutput := make(map[string]string, len(files))
+	for i, rel := range files {
+		outputs[i] = filepath.Join(*output, batchOutput(rel, *format))
+		if prev, ok := byOutput[outputs[i]]; ok {
+			return fmt.Errorf("batch: %s and %s would both be written to %s; rename one", prev, rel, outputs[i])
+		}
+		byOutput[outputs[i]] = rel
+	}
+
+	// Each job owns a converter and keeps a core busy; more jobs than
+	// GOMAXPROCS only adds runtimes competing for the same cores.
+	n := max(*jobs, 1)
+	if procs := runtime.GOMAXPROCS(0); n > procs {
+		fmt.Fprintf(w, "batch: -jobs %d exceeds GOMAXPROCS, using %d\n", n, procs)
+		n = procs
+	}
+	n = max(min(n, len(files)), 1)
+
+	converters := make([]*aster.Converter, 0, n)
+	defer func() {
+		for _, c := range converters {
+			_ = c.Close()
+		}
+	}()
+	for range n {
+		opts, err := loading.options()
+		if err != nil {
+			return fmt.Errorf("batch: %w", err)
+		}
+		c, err := aster.New(append(opts, shared...)...)
+		if err != nil {
+			return fmt.Errorf("batch: %w", err)
+		}
+		converters = append(converters, c)
+	}
+
+	start := time.Now()
+	results := make([]batchResult, len(files))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for _, c := range converters {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				results[i] = renderBatchFile(c, filepath.Join(*input, files[i]), outputs[i], *format)
+			}
+		}()
+	}
+	for i := range files {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	failed := 0
+	for i, r := range results {
+		if r.err != nil {
+			failed++
+			fmt.Fprintf(w, "FAIL  %s: %v\n", files[i], r.err)
+			continue
+		}
+		fmt.Fprintf(w, "ok    %s -> %s\n", files[i], r.out)
+	}
+	fmt.Fprintf(w, "rendered %d of %d specs with %d jobs in %v\n",
+		len(files)-failed, len(files), n, time.Since(start).Round(time.Millisecond))
+	if failed > 0 {
+		return fmt.Errorf("batch: %d of %d specs failed", failed, len(files))
+	}
+	return nil
+}
+
+// specFiles returns the *.json files under dir, relative to it, sorted.
+func specFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".json") {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, rel)
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// batchOutput returns the output path for the spec file rel: rel with its
+// spec extension (.vl.json, .vg.json or .json) replaced by the format's.
+func batchOutput(rel, format string) string {
+	for _, ext := range []string{".vl.json", ".vg.json", ".json"} {
+		if strings.HasSuffix(rel, ext) {
+			return strings.TrimSuffix(rel, ext) + "." + format
+		}
+	}
+	return rel + "." + format
+}
+
+// renderBatchFile renders the spec file in and writes it to out.
+func renderBatchFile(c *aster.Converter, in, out, format string) batchResult {
+	spec, err := os.ReadFile(in)
+	if err != nil {
+		return batchResult{err: err}
+	}
+
+	var data []byte
+	vl := isVegaLite(spec)
+	switch {
+	case format == "svg" && vl:
+		var svg string
+		svg, err = c.VegaLiteToSVG(spec)
+		data = []byte(svg)
+	case format == "svg":
+		var svg string
+		svg, err = c.VegaToSVG(spec)
+		data = []byte(svg)
+	case format == "png" && vl:
+		data, err = c.VegaLiteToPNG(spec)
+	case format == "png":
+		data, err = c.VegaToPNG(spec)
+	case vl:
+		data, err = c.VegaLiteToPDF(spec)
+	default:
+		data, err = c.VegaToPDF(spec)
+	}
+	if err != nil {
+		return batchResult{err: err}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(out), 0o755); err != nil {
+		return batchResult{err: err}
+	}
+	if err := os.WriteFile(out, data, 0o644); err != nil {
+		return batchResult{err: err}
+	}
+	return batchResult{out: out}
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunBatchDuplicateOutputs(t *testing.T) {
+	in := t.TempDir()
+	for _, name := range []string{"a.vl.json", "a.json", "b.vg.json"} {
+		if err := os.WriteFile(filepath.Join(in, name), []byte(`{}`), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := runBatch([]string{"-i", in, "-o", t.TempDir(), "-jobs", "2"}, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "a.json and a.vl.json") {
+		t.Fatalf("expected the clashing specs to be reported, got %v", err)
+	}
+}
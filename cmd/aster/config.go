@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/mgilbir/aster"
+)
+
+func addConfigFlag(fs *flag.FlagSet) *string {
+	return fs.String("config", "", "Vega config `file` (JSON) applied to every render, e.g. house styling; a spec's own config wins")
+}
+
+// configOptions reads the -config file and returns the converter option
+// that applies it.
+func configOptions(path string) ([]aster.Option, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("-config: %w", err)
+	}
+	var config map[string]any
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("-config %s: not a JSON object: %w", path, err)
+	}
+	return []aster.Option{aster.WithTheme(string(bytes.TrimSpace(data)))}, nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mgilbir/aster"
+)
+
+// A -config file reaches Vega verbatim, even with text that means something
+// inside a JS template literal.
+func TestConfigOptionsQuoting(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	config := `{"background": "#abcdef", "note": "` + "`" + `${globalThis.x = 1}\\"}`
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts, err := configOptions(path)
+	if err != nil {
+		t.Fatalf("configOptions: %v", err)
+	}
+	c, err := aster.New(append(opts, aster.WithTextMeasurement(false))...)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	svg, err := c.VegaLiteToSVG([]byte(`{"data": {"values": [{"a": 1}]}, "mark": "point", "encoding": {"x": {"field": "a", "type": "quantitative"}}}`))
+	if err != nil {
+		t.Fatalf("VegaLiteToSVG: %v", err)
+	}
+	if !strings.Contains(svg, "#abcdef") {
+		t.Errorf("expected the config's background in the SVG, got %.200s", svg)
+	}
+}
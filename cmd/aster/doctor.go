@@ -0,0 +1,98 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/mgilbir/aster"
+)
+
+// doctorSpec is a minimal bar chart that exercises compilation, text
+// measurement, SVG serialization and PNG rasterization.
+const doctorSpec = `{
+  "data": {"values": [
+    {"category": "A", "value": 28},
+    {"category": "B", "value": 55},
+    {"category": "C", "value": 43}
+  ]},
+  "mark": "bar",
+  "encoding": {
+    "x": {"field": "category", "type": "nominal"},
+    "y": {"field": "value", "type": "quantitative"}
+  }
+}`
+
+// runDoctor renders doctorSpec through every output path and reports what
+// this build embeds, so users can confirm an install and attach the output
+// to bug reports. It returns an error if any check fails.
+func runDoctor(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	version := fs.String("vl", "", "Vega-Lite version to check (default: the build's default)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	versions, err := aster.AvailableVersions()
+	if err != nil {
+		fmt.Fprintf(w, "versions  FAIL  %v\n", err)
+		return fmt.Errorf("doctor: %w", err)
+	}
+	var listed []string
+	for _, v := range versions {
+		listed = append(listed, fmt.Sprintf("%s (Vega %s, Vega-Lite %s)", v.Key, v.Vega, v.VegaLite))
+	}
+	fmt.Fprintf(w, "versions  %s\n", strings.Join(listed, ", "))
+	fmt.Fprintf(w, "fonts     %s (embedded)\n", strings.Join(aster.EmbeddedFontFamilies(), ", "))
+
+	var opts []aster.Option
+	if *version != "" {
+		opts = append(opts, aster.WithVegaLiteVersion(*version))
+	}
+
+	failed := 0
+	check := func(name string, fn func() (string, error)) {
+		start := time.Now()
+		detail, err := fn()
+		elapsed := time.Since(start).Round(time.Millisecond)
+		if err != nil {
+			failed++
+			fmt.Fprintf(w, "%-9s FAIL  %v\n", name, err)
+			return
+		}
+		fmt.Fprintf(w, "%-9s ok    %v%s\n", name, elapsed, detail)
+	}
+
+	var c *aster.Converter
+	check("runtime", func() (string, error) {
+		var err error
+		c, err = aster.New(opts...)
+		return "", err
+	})
+	if c == nil {
+		return fmt.Errorf("doctor: runtime failed to initialize")
+	}
+	defer func() { _ = c.Close() }()
+
+	check("svg", func() (string, error) {
+		svg, err := c.VegaLiteToSVG([]byte(doctorSpec))
+		if err == nil && !strings.HasPrefix(svg, "<svg") {
+			err = fmt.Errorf("output is not an SVG document")
+		}
+		return fmt.Sprintf(", %d bytes", len(svg)), err
+	})
+	check("png", func() (string, error) {
+		png, err := c.VegaLiteToPNG([]byte(doctorSpec))
+		if err == nil && !strings.HasPrefix(string(png), "\x89PNG") {
+			err = fmt.Errorf("output is not a PNG image")
+		}
+		return fmt.Sprintf(", %d bytes", len(png)), err
+	})
+
+	if failed > 0 {
+		return fmt.Errorf("doctor: %d check(s) failed", failed)
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mgilbir/aster"
+)
+
+// fontList collects repeated -font FAMILY=path flags.
+type fontList []string
+
+func (l *fontList) String() string { return strings.Join(*l, ",") }
+
+func (l *fontList) Set(v string) error {
+	family, path, ok := strings.Cut(v, "=")
+	if !ok || strings.TrimSpace(family) == "" || path == "" {
+		return fmt.Errorf("expected FAMILY=path, got %q", v)
+	}
+	*l = append(*l, v)
+	return nil
+}
+
+// fontFlags holds the font flags shared by the rendering commands.
+type fontFlags struct {
+	fonts       fontList
+	defaultFont *string
+}
+
+func addFontFlags(fs *flag.FlagSet) *fontFlags {
+	f := &fontFlags{}
+	fs.Var(&f.fonts, "font", "register a TrueType/OpenType font as `FAMILY=path.ttf` (repeatable)")
+	f.defaultFont = fs.String("default-font", "", "font `family` for generic families such as sans-serif")
+	return f
+}
+
+// options reads the -font files and returns the converter options for the
+// flags. It reports every file that cannot be read, not just the first.
+func (f *fontFlags) options() ([]aster.Option, error) {
+	var opts []aster.Option
+	var errs []error
+	for _, v := range f.fonts {
+		family, path, _ := strings.Cut(v, "=")
+		data, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("-font %s: %w", strings.TrimSpace(family), err))
+			continue
+		}
+		opts = append(opts, aster.WithFont(strings.TrimSpace(family), data))
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	if *f.defaultFont != "" {
+		opts = append(opts, aster.WithDefaultFontFamily(*f.defaultFont))
+	}
+	return opts, nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mgilbir/aster"
+)
+
+// loaderFlags holds the data-loading flags shared by the rendering commands.
+type loaderFlags struct {
+	allowHTTP    *bool
+	allowDomains *string
+	dataDir      *string
+}
+
+func addLoaderFlags(fs *flag.FlagSet) *loaderFlags {
+	return &loaderFlags{
+		allowHTTP: fs.Bool("allow-http", false, "allow HTTP(S) data loading"),
+		allowDomains: fs.String("allow-domains", "",
+			"with -allow-http, load only from these comma-separated `hosts`, redirects included;\n"+
+				"without it a spec can make the CLI fetch any URL, including internal services (SSRF)"),
+		dataDir: fs.String("data-dir", "", "load relative data URLs from files under `dir`"),
+	}
+}
+
+// domains returns the -allow-domains hostnames.
+func (f *loaderFlags) domains() []string {
+	var domains []string
+	for _, d := range strings.Split(*f.allowDomains, ",") {
+		if d = strings.TrimSpace(d); d != "" {
+			domains = append(domains, d)
+		}
+	}
+	return domains
+}
+
+// check validates the flags before any converter is created.
+func (f *loaderFlags) check() error {
+	if *f.allowDomains != "" && !*f.allowHTTP {
+		return fmt.Errorf("-allow-domains requires -allow-http")
+	}
+	if *f.dataDir == "" {
+		return nil
+	}
+	info, err := os.Stat(*f.dataDir)
+	if err != nil {
+		return fmt.Errorf("-data-dir: %w", err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("-data-dir: %s is not a directory", *f.dataDir)
+	}
+	return nil
+}
+
+// options returns the converter options for the flags, with a fresh loader
+// for each call since a converter closes its loader.
+func (f *loaderFlags) options() ([]aster.Option, error) {
+	var loaders []aster.Loader
+	if *f.dataDir != "" {
+		fl, err := aster.NewFileLoader(*f.dataDir)
+		if err != nil {
+			return nil, err
+		}
+		loaders = append(loaders, fl)
+	}
+	if *f.allowHTTP {
+		hl := aster.NewHTTPLoader(nil)
+		hl.AllowedDomains = f.domains()
+		hl.MaxRedirects = 10
+		loaders = append(loaders, hl)
+	}
+
+	switch len(loaders) {
+	case 0:
+		return nil, nil
+	case 1:
+		return []aster.Option{aster.WithLoader(loaders[0])}, nil
+	default:
+		// FileLoader takes relative paths and HTTPLoader absolute URLs.
+		return []aster.Option{aster.WithLoader(aster.NewFallbackLoader(loaders...))}, nil
+	}
+}
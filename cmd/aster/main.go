@@ -5,10 +5,19 @@
 //	aster svg -i input.vl.json -o output.svg
 //	aster svg -i input.vl.json              # stdout
 //	cat spec.json | aster svg > output.svg  # stdin
+//	aster svg -i input.vl.json -data-dir data/  # relative data URLs from data/
+//	aster svg -i input.vl.json -allow-http -allow-domains example.com
 //	aster compile -i input.vl.json          # Vega-Lite → Vega JSON
+//	aster batch -i specs/ -o out/ -jobs 4   # render a directory
+//	aster svg -i input.vl.json -vl-version 5.8  # pick a Vega-Lite version
+//	aster versions                          # list embedded versions
+//	aster svg -i input.vl.json -font Inter=Inter.ttf -default-font Inter
+//	aster svg -i input.vl.json -config house-style.json
+//	aster doctor                            # self-test this build
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -28,7 +37,7 @@ func main() {
 
 func run() error {
 	if len(os.Args) < 2 {
-		return fmt.Errorf("usage: aster <command> [flags]\n\nCommands:\n  svg      Render spec to SVG\n  compile  Compile Vega-Lite to Vega JSON")
+		return fmt.Errorf("usage: aster <command> [flags]\n\nCommands:\n  svg      Render spec to SVG\n  compile  Compile Vega-Lite to Vega JSON\n  batch    Render a directory of specs\n  versions List the embedded Vega-Lite versions\n  doctor   Check that the runtime and renderers work")
 	}
 
 	command := os.Args[1]
@@ -37,8 +46,14 @@ func run() error {
 		return runSVG(os.Args[2:])
 	case "compile":
 		return runCompile(os.Args[2:])
+	case "batch":
+		return runBatch(os.Args[2:], os.Stdout)
+	case "versions":
+		return runVersions(os.Args[2:], os.Stdout)
+	case "doctor":
+		return runDoctor(os.Args[2:], os.Stdout)
 	default:
-		return fmt.Errorf("unknown command %q (expected svg or compile)", command)
+		return fmt.Errorf("unknown command %q (expected svg, compile, batch, versions or doctor)", command)
 	}
 }
 
@@ -46,20 +61,41 @@ func runSVG(args []string) (err error) {
 	fs := flag.NewFlagSet("svg", flag.ExitOnError)
 	input := fs.String("i", "", "input spec file (- or omit for stdin)")
 	output := fs.String("o", "", "output SVG file (omit for stdout)")
-	allowHTTP := fs.Bool("allow-http", false, "allow HTTP(S) data loading")
+	loading := addLoaderFlags(fs)
+	version := addVersionFlag(fs)
+	fonts := addFontFlags(fs)
+	configFile := addConfigFlag(fs)
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
+	if err := loading.check(); err != nil {
+		return err
+	}
+	opts, err := versionOptions(*version)
+	if err != nil {
+		return err
+	}
+	fontOpts, err := fonts.options()
+	if err != nil {
+		return err
+	}
+	opts = append(opts, fontOpts...)
+	configOpts, err := configOptions(*configFile)
+	if err != nil {
+		return err
+	}
+	opts = append(opts, configOpts...)
 
 	spec, err := readInput(*input)
 	if err != nil {
 		return err
 	}
 
-	var opts []aster.Option
-	if *allowHTTP {
-		opts = append(opts, aster.WithLoader(aster.NewHTTPLoader(nil)))
+	loaderOpts, err := loading.options()
+	if err != nil {
+		return err
 	}
+	opts = append(opts, loaderOpts...)
 
 	c, err := aster.New(opts...)
 	if err != nil {
@@ -88,16 +124,21 @@ func runCompile(args []string) (err error) {
 	fs := flag.NewFlagSet("compile", flag.ExitOnError)
 	input := fs.String("i", "", "input Vega-Lite spec file (- or omit for stdin)")
 	output := fs.String("o", "", "output Vega JSON file (omit for stdout)")
+	version := addVersionFlag(fs)
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
+	opts, err := versionOptions(*version)
+	if err != nil {
+		return err
+	}
 
 	spec, err := readInput(*input)
 	if err != nil {
 		return err
 	}
 
-	c, err := aster.New(aster.WithTextMeasurement(false))
+	c, err := aster.New(append(opts, aster.WithTextMeasurement(false))...)
 	if err != nil {
 		return err
 	}
@@ -122,11 +163,25 @@ func runCompile(args []string) (err error) {
 	return writeOutput(*output, append(formatted, '\n'))
 }
 
+// readInput reads the spec at path, or stdin for "" or "-". Empty input is
+// an error, rather than a confusing one from the renderer.
 func readInput(path string) ([]byte, error) {
+	var spec []byte
+	var err error
+	name := path
 	if path == "" || path == "-" {
-		return io.ReadAll(os.Stdin)
+		name = "stdin"
+		spec, err = io.ReadAll(os.Stdin)
+	} else {
+		spec, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(bytes.TrimSpace(spec)) == 0 {
+		return nil, fmt.Errorf("%s: empty spec", name)
 	}
-	return os.ReadFile(path)
+	return spec, nil
 }
 
 func writeOutput(path string, data []byte) error {
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mgilbir/aster"
+)
+
+// runVersions prints the Vega-Lite versions this build embeds, one per line,
+// with the Vega release each runs on.
+func runVersions(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("versions", flag.ExitOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	versions, err := aster.AvailableVersions()
+	if err != nil {
+		return fmt.Errorf("versions: %w", err)
+	}
+	for _, v := range versions {
+		fmt.Fprintf(w, "%-6s Vega-Lite %s, Vega %s\n", v.Key, v.VegaLite, v.Vega)
+	}
+	return nil
+}
+
+func addVersionFlag(fs *flag.FlagSet) *string {
+	return fs.String("vl-version", "", "Vega-Lite `version` to use, e.g. 5.8 (see aster versions; default: the build's default)")
+}
+
+// versionOptions returns the converter options selecting version, or an
+// error listing the embedded versions if this build lacks it.
+func versionOptions(version string) ([]aster.Option, error) {
+	if version == "" {
+		return nil, nil
+	}
+	if !aster.HasVegaLiteVersion(version) {
+		versions, err := aster.AvailableVersions()
+		if err != nil {
+			return nil, err
+		}
+		keys := make([]string, len(versions))
+		for i, v := range versions {
+			keys[i] = v.Key
+		}
+		return nil, fmt.Errorf("unknown Vega-Lite version %q (available: %s)", version, strings.Join(keys, ", "))
+	}
+	return []aster.Option{aster.WithVegaLiteVersion(version)}, nil
+}
@@ -1,7 +1,8 @@
 // Command vendor-datasets downloads vega-datasets data files from jsDelivr
 // and saves them to testdata/vega-datasets/data/. These files are used by
 // vega-lite example specs that reference external data via relative URLs
-// like "data/cars.json".
+// like "data/cars.json". A small subset is also embedded in the aster
+// package for ExampleDatasetsLoader; see exampledata.go.
 //
 // It also writes a LICENSE file noting the BSD-3-Clause license.
 package main
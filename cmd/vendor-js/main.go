@@ -12,9 +12,11 @@ package main
 import (
 	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"io/fs"
 	"log"
 	"net/http"
 	"os"
@@ -118,11 +120,13 @@ func main() {
 		Versions: make(map[string]VersionDef),
 	}
 
+	var summaries []string
 	for _, vs := range sets {
-		vegaVer, err := vendorVersion(vs)
+		vegaVer, diff, err := vendorVersion(vs)
 		if err != nil {
 			log.Fatalf("vendoring %s: %v", vs.key, err)
 		}
+		summaries = append(summaries, fmt.Sprintf("[%s] %s", vs.key, diff.summary()))
 		index.Versions[vs.key] = VersionDef{
 			VegaVersion:     vegaVer,
 			VegaLiteVersion: vs.vegaLiteVersion,
@@ -139,12 +143,24 @@ func main() {
 		log.Fatalf("writing versions index: %v", err)
 	}
 	log.Printf("wrote versions index to %s", indexPath)
+
+	log.Printf("changes versus the previously vendored manifests:")
+	for _, line := range summaries {
+		log.Printf("  %s", line)
+	}
 }
 
-func vendorVersion(vs versionSet) (string, error) {
+func vendorVersion(vs versionSet) (string, manifestDiff, error) {
 	outDir := filepath.Join("internal", "js", "modules", vs.key)
 	if err := os.MkdirAll(outDir, 0o755); err != nil {
-		return "", fmt.Errorf("creating output dir: %w", err)
+		return "", manifestDiff{}, fmt.Errorf("creating output dir: %w", err)
+	}
+
+	// Keep the currently vendored manifest to report what changes.
+	manifestPath := filepath.Join(outDir, "manifest.json")
+	prior, err := readManifest(manifestPath)
+	if err != nil {
+		return "", manifestDiff{}, err
 	}
 
 	modules := make(map[string]*module) // name → module
@@ -174,7 +190,7 @@ func vendorVersion(vs versionSet) (string, error) {
 
 		src, err := fetchESM(item.name, item.version)
 		if err != nil {
-			return "", fmt.Errorf("fetching %s@%s: %w", item.name, item.version, err)
+			return "", manifestDiff{}, fmt.Errorf("fetching %s@%s: %w", item.name, item.version, err)
 		}
 
 		mod := &module{
@@ -228,7 +244,7 @@ func vendorVersion(vs versionSet) (string, error) {
 	}
 
 	if vegaVersion == "" {
-		return "", fmt.Errorf("vega version not resolved from dependencies")
+		return "", manifestDiff{}, fmt.Errorf("vega version not resolved from dependencies")
 	}
 
 	log.Printf("[%s] resolved Vega %s, downloaded %d modules, computing load order...", vs.key, vegaVersion, len(modules))
@@ -236,7 +252,7 @@ func vendorVersion(vs versionSet) (string, error) {
 	// Topological sort for load order.
 	order, err := topoSort(modules)
 	if err != nil {
-		return "", fmt.Errorf("topological sort: %w", err)
+		return "", manifestDiff{}, fmt.Errorf("topological sort: %w", err)
 	}
 
 	// Write module files and build manifest.
@@ -252,7 +268,7 @@ func vendorVersion(vs versionSet) (string, error) {
 		outPath := filepath.Join(outDir, filename)
 
 		if err := os.WriteFile(outPath, []byte(mod.source), 0o644); err != nil {
-			return "", fmt.Errorf("writing %s: %w", outPath, err)
+			return "", manifestDiff{}, fmt.Errorf("writing %s: %w", outPath, err)
 		}
 
 		hash := sha256.Sum256([]byte(mod.source))
@@ -264,21 +280,90 @@ func vendorVersion(vs versionSet) (string, error) {
 		})
 	}
 
-	manifestPath := filepath.Join(outDir, "manifest.json")
 	manifestJSON, err := json.MarshalIndent(manifest, "", "  ")
 	if err != nil {
-		return "", fmt.Errorf("marshaling manifest: %w", err)
+		return "", manifestDiff{}, fmt.Errorf("marshaling manifest: %w", err)
 	}
 	if err := os.WriteFile(manifestPath, manifestJSON, 0o644); err != nil {
-		return "", fmt.Errorf("writing manifest: %w", err)
+		return "", manifestDiff{}, fmt.Errorf("writing manifest: %w", err)
 	}
 
+	diff := diffManifests(prior, &manifest)
 	log.Printf("[%s] wrote %d modules + manifest to %s", vs.key, len(order), outDir)
 	for _, m := range manifest.Modules {
-		log.Printf("  [%s] %s@%s (%s)", vs.key, m.Name, m.Version, m.Filename)
+		log.Printf("  [%s] %s@%s (%s) %s", vs.key, m.Name, m.Version, m.Filename, diff.status[m.Name])
 	}
+	for _, name := range diff.removed {
+		log.Printf("  [%s] %s removed", vs.key, name)
+	}
+
+	return vegaVersion, diff, nil
+}
+
+// readManifest reads a previously written manifest, or returns nil if there
+// is none.
+func readManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading prior manifest: %w", err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("parsing prior manifest %s: %w", path, err)
+	}
+	return &m, nil
+}
+
+// manifestDiff compares a newly vendored manifest to the prior one.
+type manifestDiff struct {
+	status                             map[string]string // module name → change, for logging
+	added, bumped, modified, unchanged int
+	removed                            []string
+}
+
+// diffManifests compares modules by name: a module is added, bumped (its
+// version changed), modified (same version, different sha256) or unchanged.
+// prior may be nil, when nothing was vendored before.
+func diffManifests(prior, next *Manifest) manifestDiff {
+	d := manifestDiff{status: make(map[string]string)}
+	old := make(map[string]ManifestModule)
+	if prior != nil {
+		for _, m := range prior.Modules {
+			old[m.Name] = m
+		}
+	}
+	for _, m := range next.Modules {
+		p, ok := old[m.Name]
+		delete(old, m.Name)
+		switch {
+		case !ok:
+			d.added++
+			d.status[m.Name] = "added"
+		case p.Version != m.Version:
+			d.bumped++
+			d.status[m.Name] = fmt.Sprintf("bumped from %s", p.Version)
+		case p.SHA256 != m.SHA256:
+			d.modified++
+			d.status[m.Name] = "contents changed"
+		default:
+			d.unchanged++
+			d.status[m.Name] = "unchanged"
+		}
+	}
+	for name := range old {
+		d.removed = append(d.removed, name)
+	}
+	sort.Strings(d.removed)
+	return d
+}
 
-	return vegaVersion, nil
+// summary describes the diff in one line.
+func (d manifestDiff) summary() string {
+	return fmt.Sprintf("%d bumped, %d contents changed, %d added, %d removed, %d unchanged",
+		d.bumped, d.modified, d.added, len(d.removed), d.unchanged)
 }
 
 func fetchESM(name, version string) (string, error) {
@@ -0,0 +1,114 @@
+package aster
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// dataTable is the primary dataset returned by the bridge.
+type dataTable struct {
+	Name    string          `json:"name"`
+	Columns []string        `json:"columns"`
+	Values  json.RawMessage `json:"values"`
+}
+
+// VegaLiteToData compiles a Vega-Lite spec, runs its dataflow, and serializes
+// the primary dataset (the one feeding the chart's marks, after all
+// transforms) in the given format: "csv" or "json".
+//
+// The dataflow runs in a headless view (Vega's "none" renderer) and no scene
+// graph is serialized, so this is cheaper than a render of the same spec.
+//
+// CSV columns are the union of all row keys in first-seen order. Nested
+// values are written as JSON text.
+func (c *Converter) VegaLiteToData(spec []byte, format string) ([]byte, error) {
+	format = strings.ToLower(format)
+	if format != "csv" && format != "json" {
+		return nil, fmt.Errorf("aster: unsupported data format %q (expected csv or json)", format)
+	}
+
+	spec, err := c.prepareVegaLite(spec)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.prefetchVegaLite(spec); err != nil {
+		return nil, err
+	}
+	defer c.clearPrefetched()
+	result, err := c.rt.VegaLiteToData(string(spec))
+	if err != nil {
+		return nil, err
+	}
+
+	var table dataTable
+	if err := json.Unmarshal([]byte(result), &table); err != nil {
+		return nil, fmt.Errorf("aster: decoding dataset: %w", err)
+	}
+
+	if format == "json" {
+		return table.Values, nil
+	}
+	return encodeCSV(table)
+}
+
+// encodeCSV writes a header row followed by one record per value.
+func encodeCSV(table dataTable) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(table.Values))
+	dec.UseNumber() // keep numbers exactly as Vega serialized them
+	var rows []map[string]any
+	if err := dec.Decode(&rows); err != nil {
+		return nil, fmt.Errorf("aster: decoding dataset rows: %w", err)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(table.Columns); err != nil {
+		return nil, fmt.Errorf("aster: writing CSV header: %w", err)
+	}
+
+	record := make([]string, len(table.Columns))
+	for _, row := range rows {
+		for i, col := range table.Columns {
+			cell, err := csvCell(row[col])
+			if err != nil {
+				return nil, fmt.Errorf("aster: writing CSV column %q: %w", col, err)
+			}
+			record[i] = cell
+		}
+		if err := w.Write(record); err != nil {
+			return nil, fmt.Errorf("aster: writing CSV row: %w", err)
+		}
+	}
+
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("aster: writing CSV: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// csvCell formats a decoded JSON value as a CSV field.
+func csvCell(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		if v {
+			return "true", nil
+		}
+		return "false", nil
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+}
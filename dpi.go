@@ -0,0 +1,59 @@
+package aster
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"math"
+)
+
+// cssDPI is the resolution of one CSS pixel: 96 per inch.
+const cssDPI = 96
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// setPNGDPI returns data with a pHYs chunk declaring dpi pixels per inch,
+// replacing any pHYs chunk it already has. The chunk goes right after IHDR,
+// ahead of the image data as the PNG specification requires.
+func setPNGDPI(data []byte, dpi float64) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, fmt.Errorf("aster: setting PNG resolution: not a PNG")
+	}
+	ppm := uint32(math.Round(dpi / 0.0254)) // pixels per meter
+	phys := make([]byte, 9)
+	binary.BigEndian.PutUint32(phys[0:], ppm)
+	binary.BigEndian.PutUint32(phys[4:], ppm)
+	phys[8] = 1 // unit: meter
+
+	out := make([]byte, 0, len(data)+21)
+	out = append(out, pngSignature...)
+	for rest := data[len(pngSignature):]; len(rest) > 0; {
+		if len(rest) < 12 {
+			return nil, fmt.Errorf("aster: setting PNG resolution: truncated chunk")
+		}
+		n := binary.BigEndian.Uint32(rest)
+		if uint64(n)+12 > uint64(len(rest)) {
+			return nil, fmt.Errorf("aster: setting PNG resolution: truncated chunk")
+		}
+		chunk, kind := rest[:n+12], string(rest[4:8])
+		rest = rest[n+12:]
+		if kind == "pHYs" {
+			continue
+		}
+		out = append(out, chunk...)
+		if kind == "IHDR" {
+			out = appendPNGChunk(out, "pHYs", phys)
+		}
+	}
+	return out, nil
+}
+
+// appendPNGChunk appends a PNG chunk of the given type and data to b.
+func appendPNGChunk(b []byte, kind string, data []byte) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(data)))
+	start := len(b)
+	b = append(b, kind...)
+	b = append(b, data...)
+	return binary.BigEndian.AppendUint32(b, crc32.ChecksumIEEE(b[start:]))
+}
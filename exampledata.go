@@ -0,0 +1,95 @@
+package aster
+
+import (
+	"context"
+	"embed"
+	"io/fs"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// exampleDatasets is the curated subset of testdata/vega-datasets (see
+// cmd/vendor-datasets) that ExampleDatasetsLoader serves: the small files
+// behind the most common example charts, about 600 KB in all. Large files
+// such as movies.json, zipcodes.csv and us-10m.json are left out to keep
+// binaries small.
+//
+//go:embed testdata/vega-datasets/LICENSE
+//go:embed testdata/vega-datasets/data/anscombe.json
+//go:embed testdata/vega-datasets/data/barley.json
+//go:embed testdata/vega-datasets/data/cars.json
+//go:embed testdata/vega-datasets/data/co2-concentration.csv
+//go:embed testdata/vega-datasets/data/driving.json
+//go:embed testdata/vega-datasets/data/gapminder.json
+//go:embed testdata/vega-datasets/data/github.csv
+//go:embed testdata/vega-datasets/data/lookup_groups.csv
+//go:embed testdata/vega-datasets/data/lookup_people.csv
+//go:embed testdata/vega-datasets/data/monarchs.json
+//go:embed testdata/vega-datasets/data/normal-2d.json
+//go:embed testdata/vega-datasets/data/ohlc.json
+//go:embed testdata/vega-datasets/data/penguins.json
+//go:embed testdata/vega-datasets/data/population.json
+//go:embed testdata/vega-datasets/data/seattle-weather.csv
+//go:embed testdata/vega-datasets/data/sp500.csv
+//go:embed testdata/vega-datasets/data/stocks.csv
+//go:embed testdata/vega-datasets/data/unemployment.tsv
+//go:embed testdata/vega-datasets/data/us-state-capitals.json
+//go:embed testdata/vega-datasets/data/weekly-weather.json
+//go:embed testdata/vega-datasets/data/wheat.json
+//go:embed testdata/vega-datasets/data/world-110m.json
+var exampleDatasets embed.FS
+
+// ExampleDatasetsLoader returns a Loader that serves a curated subset of
+// vega-datasets v3.2.1 embedded in the binary, so example specs that
+// reference files such as "data/cars.json", "data/stocks.csv" or
+// "data/world-110m.json" render without network access or local files.
+// Relative paths with or without the "data/" prefix are accepted, as are the
+// jsDelivr, raw GitHub and vega.github.io URLs of the same files (see
+// VegaDatasetsRewriteRules). Datasets outside the subset fail with a
+// LoadError of kind LoadNotFound; serve those with a FileLoader over
+// testdata/vega-datasets or an HTTPLoader instead.
+//
+// The data is licensed BSD-3-Clause by the vega-datasets authors.
+func ExampleDatasetsLoader() Loader {
+	fsys, err := fs.Sub(exampleDatasets, "testdata/vega-datasets")
+	if err != nil {
+		panic(err) // the embedded directory is fixed at build time
+	}
+	return &RewriteLoader{
+		Loader: &exampleLoader{fsys: fsys},
+		Rules:  VegaDatasetsRewriteRules("data/"),
+	}
+}
+
+// exampleLoader serves relative paths from an embedded vega-datasets tree.
+type exampleLoader struct {
+	fsys fs.FS
+}
+
+func (l *exampleLoader) Sanitize(_ context.Context, uri string) (string, error) {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return "", newLoadError(LoadDenied, uri, "invalid URI %q: %w", uri, err)
+	}
+	if parsed.Scheme != "" {
+		return "", newLoadError(LoadDenied, uri, "ExampleDatasetsLoader only serves vega-datasets paths, got scheme %q in %q", parsed.Scheme, uri)
+	}
+
+	name := strings.TrimPrefix(path.Clean(parsed.Path), "./")
+	if !fs.ValidPath(name) {
+		return "", newLoadError(LoadDenied, uri, "ExampleDatasetsLoader rejects path %q", uri)
+	}
+	if !strings.HasPrefix(name, "data/") {
+		name = "data/" + name
+	}
+	return name, nil
+}
+
+func (l *exampleLoader) Load(_ context.Context, uri string) ([]byte, error) {
+	data, err := fs.ReadFile(l.fsys, uri)
+	if err != nil {
+		return nil, newLoadError(fileErrorKind(err), uri, "%q is not among the datasets ExampleDatasetsLoader embeds: %w", uri, err)
+	}
+	return data, nil
+}
@@ -7,6 +7,5 @@ require (
 	github.com/go-text/typesetting v0.3.3
 	github.com/tetratelabs/wazero v1.9.0
 	golang.org/x/image v0.35.0
+	golang.org/x/text v0.33.0
 )
-
-require golang.org/x/text v0.33.0 // indirect
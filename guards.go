@@ -0,0 +1,63 @@
+package aster
+
+import (
+	"fmt"
+
+	"github.com/mgilbir/aster/internal/runtime"
+)
+
+// ErrLimitExceeded is wrapped by the errors returned when a render trips one
+// of the untrusted-input guards (see WithTrustedSpec).
+var ErrLimitExceeded = runtime.ErrLimitExceeded
+
+// ErrRenderTimeout is wrapped by the errors returned when a render runs past
+// the WithTimeout deadline, including when a Loader call outlives it, so
+// callers can tell a slow spec from a broken one.
+var ErrRenderTimeout = runtime.ErrTimeout
+
+// ErrMemoryLimitExceeded is wrapped by the errors returned when a render runs
+// out of QuickJS memory under WithMemoryLimit: the spec needs more than the
+// limit allows, and raising the limit may let it render. The Converter stays
+// usable afterwards.
+var ErrMemoryLimitExceeded = runtime.ErrMemoryLimit
+
+// guarded reports whether untrusted-input guards apply to this Converter.
+func (c *Converter) guarded() bool {
+	return !c.cfg.trustedSpec
+}
+
+// checkSpecSize enforces WithMaxSpecBytes on an input spec.
+func (c *Converter) checkSpecSize(spec []byte) error {
+	if !c.guarded() || c.cfg.maxSpecBytes <= 0 || len(spec) <= c.cfg.maxSpecBytes {
+		return nil
+	}
+	return fmt.Errorf("%w: spec is %d bytes, over the %d byte maximum (WithMaxSpecBytes)",
+		ErrLimitExceeded, len(spec), c.cfg.maxSpecBytes)
+}
+
+// checkRequestCount enforces WithMaxRequests on the data URLs a spec
+// references, before WithPrefetch fetches them. The render itself is
+// limited by the runtime, which counts the URIs it is asked to sanitize.
+func (c *Converter) checkRequestCount(urls []string) error {
+	if !c.guarded() || c.cfg.maxRequests <= 0 {
+		return nil
+	}
+	distinct := make(map[string]bool, len(urls))
+	for _, u := range urls {
+		distinct[u] = true
+	}
+	if len(distinct) <= c.cfg.maxRequests {
+		return nil
+	}
+	return fmt.Errorf("%w: spec references %d distinct data URLs, over the %d maximum (WithMaxRequests)",
+		ErrLimitExceeded, len(distinct), c.cfg.maxRequests)
+}
+
+// checkSVGSize enforces WithMaxRenderBytes on a rendered SVG.
+func (c *Converter) checkSVGSize(svg string) error {
+	if !c.guarded() || c.cfg.maxRenderBytes <= 0 || len(svg) <= c.cfg.maxRenderBytes {
+		return nil
+	}
+	return fmt.Errorf("%w: rendered SVG is %d bytes, over the %d byte maximum (WithMaxRenderBytes)",
+		ErrLimitExceeded, len(svg), c.cfg.maxRenderBytes)
+}
@@ -0,0 +1,265 @@
+package aster_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mgilbir/aster"
+)
+
+func TestWithMaxRenderBytes(t *testing.T) {
+	spec, err := os.ReadFile("testdata/bar-chart.vl.json")
+	if err != nil {
+		t.Fatalf("reading test spec: %v", err)
+	}
+
+	c, err := aster.New(aster.WithMaxRenderBytes(100))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	if _, err := c.VegaLiteToSVG(spec); !errors.Is(err, aster.ErrLimitExceeded) {
+		t.Errorf("VegaLiteToSVG: expected ErrLimitExceeded, got %v", err)
+	}
+	if _, err := c.VegaLiteToPNG(spec); !errors.Is(err, aster.ErrLimitExceeded) {
+		t.Errorf("VegaLiteToPNG: expected ErrLimitExceeded, got %v", err)
+	}
+
+	trusted, err := aster.New(aster.WithMaxRenderBytes(100), aster.WithTrustedSpec())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = trusted.Close() }()
+
+	if _, err := trusted.VegaLiteToSVG(spec); err != nil {
+		t.Errorf("trusted spec should bypass the limit: %v", err)
+	}
+}
+
+func TestWithMaxSpecBytes(t *testing.T) {
+	spec, err := os.ReadFile("testdata/bar-chart.vl.json")
+	if err != nil {
+		t.Fatalf("reading test spec: %v", err)
+	}
+
+	c, err := aster.New(aster.WithMaxSpecBytes(len(spec) - 1))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	if _, err := c.VegaLiteToSVG(spec); !errors.Is(err, aster.ErrLimitExceeded) {
+		t.Errorf("VegaLiteToSVG: expected ErrLimitExceeded, got %v", err)
+	}
+	if _, err := c.VegaLiteToVega(spec); !errors.Is(err, aster.ErrLimitExceeded) {
+		t.Errorf("VegaLiteToVega: expected ErrLimitExceeded, got %v", err)
+	}
+	// The spec is rejected before it is parsed, so even invalid JSON trips
+	// the guard rather than a parse error.
+	junk := make([]byte, len(spec))
+	if _, err := c.VegaToSVG(junk); !errors.Is(err, aster.ErrLimitExceeded) {
+		t.Errorf("VegaToSVG: expected ErrLimitExceeded, got %v", err)
+	}
+
+	trusted, err := aster.New(aster.WithMaxSpecBytes(len(spec)-1), aster.WithTrustedSpec())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = trusted.Close() }()
+
+	if _, err := trusted.VegaLiteToSVG(spec); err != nil {
+		t.Errorf("trusted spec should bypass the limit: %v", err)
+	}
+}
+
+// stallingLoader blocks every load until its context is done.
+type stallingLoader struct{}
+
+func (stallingLoader) Sanitize(_ context.Context, uri string) (string, error) {
+	return uri, nil
+}
+
+func (stallingLoader) Load(ctx context.Context, _ string) ([]byte, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestRenderTimeout(t *testing.T) {
+	spec := []byte(`{
+		"$schema": "https://vega.github.io/schema/vega-lite/v5.json",
+		"data": {"url": "https://example.com/slow.csv"},
+		"mark": "bar",
+		"encoding": {"x": {"field": "a", "type": "nominal"}, "y": {"field": "b", "type": "quantitative"}}
+	}`)
+
+	c, err := aster.New(aster.WithLoader(stallingLoader{}), aster.WithTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	_, err = c.VegaLiteToSVG(spec)
+	if !errors.Is(err, aster.ErrRenderTimeout) {
+		t.Fatalf("expected ErrRenderTimeout, got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the loader's deadline error to be wrapped too, got %v", err)
+	}
+}
+
+func TestRenderTimeoutCPUBound(t *testing.T) {
+	// No data is loaded: the time goes into the dataflow itself.
+	spec := []byte(`{
+		"data": {"sequence": {"start": 0, "stop": 200000, "as": "x"}},
+		"transform": [{"calculate": "sqrt(datum.x) * sin(datum.x)", "as": "y"}],
+		"mark": "point",
+		"encoding": {"x": {"aggregate": "mean", "field": "y", "type": "quantitative"}}
+	}`)
+
+	c, err := aster.New(aster.WithTimeout(time.Millisecond))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	_, err = c.VegaLiteToSVG(spec)
+	if !errors.Is(err, aster.ErrRenderTimeout) {
+		t.Fatalf("expected ErrRenderTimeout, got %v", err)
+	}
+}
+
+func TestMemoryLimitExceeded(t *testing.T) {
+	// A million-row sequence, each row an object, does not fit in 64 MiB
+	// alongside Vega itself.
+	spec := []byte(`{
+		"data": {"sequence": {"start": 0, "stop": 1000000, "as": "x"}},
+		"transform": [{"calculate": "datum.x * 2", "as": "y"}],
+		"mark": "point",
+		"encoding": {"x": {"aggregate": "mean", "field": "y", "type": "quantitative"}}
+	}`)
+
+	c, err := aster.New(aster.WithMemoryLimit(64 << 20))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	_, err = c.VegaLiteToSVG(spec)
+	if !errors.Is(err, aster.ErrMemoryLimitExceeded) {
+		t.Fatalf("expected ErrMemoryLimitExceeded, got %v", err)
+	}
+
+	small := []byte(`{"data": {"values": [{"a": 1}]}, "mark": "point", "encoding": {"x": {"field": "a", "type": "quantitative"}}}`)
+	if _, err := c.VegaLiteToSVG(small); err != nil {
+		t.Errorf("a small spec should still render after running out of memory: %v", err)
+	}
+}
+
+func TestWithScaleClamp(t *testing.T) {
+	spec := []byte(`{
+		"width": 1e9, "height": 50,
+		"data": {"values": [{"a": 1}, {"a": 2}]},
+		"mark": "point",
+		"encoding": {"x": {"field": "a", "type": "quantitative"}}
+	}`)
+
+	c, err := aster.New(aster.WithScaleClamp(400, 300))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	svg, err := c.VegaLiteToSVG(spec)
+	if err != nil {
+		t.Fatalf("VegaLiteToSVG: %v", err)
+	}
+	// The 400px plot area plus axes and padding; the height is under the
+	// maximum and stays as the spec set it.
+	if w, h := svgSize(t, svg); w < 400 || w > 500 || h > 300 {
+		t.Errorf("expected the width clamped near 400 and the height kept, got %vx%v", w, h)
+	}
+	if w := c.Warnings(); len(w) == 0 || !strings.Contains(w[0], "clamped spec width") {
+		t.Errorf("expected the clamp reported in Warnings, got %q", w)
+	}
+
+	vg := []byte(`{"width": 5000, "height": 8000, "marks": []}`)
+	svg, err = c.VegaToSVG(vg)
+	if err != nil {
+		t.Fatalf("VegaToSVG: %v", err)
+	}
+	if w, h := svgSize(t, svg); w > 400 || h > 300 {
+		t.Errorf("expected a Vega spec clamped to 400x300, got %vx%v", w, h)
+	}
+}
+
+func TestWithMaxRequests(t *testing.T) {
+	// Five image marks, each with its own URL, like the tiles of a map.
+	spec := []byte(`{
+		"data": {"values": [
+			{"x": 0, "u": "https://tiles.example.com/0.png"},
+			{"x": 1, "u": "https://tiles.example.com/1.png"},
+			{"x": 2, "u": "https://tiles.example.com/2.png"},
+			{"x": 3, "u": "https://tiles.example.com/3.png"},
+			{"x": 4, "u": "https://tiles.example.com/4.png"}
+		]},
+		"mark": {"type": "image", "width": 10, "height": 10},
+		"encoding": {"x": {"field": "x", "type": "quantitative"}, "url": {"field": "u", "type": "nominal"}}
+	}`)
+
+	limited, err := aster.New(aster.WithLoader(&uriRecorder{}), aster.WithMaxRequests(3))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = limited.Close() }()
+	if _, err := limited.VegaLiteToSVG(spec); !errors.Is(err, aster.ErrLimitExceeded) {
+		t.Errorf("expected ErrLimitExceeded, got %v", err)
+	}
+
+	trusted, err := aster.New(aster.WithLoader(&uriRecorder{}), aster.WithMaxRequests(3), aster.WithTrustedSpec())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = trusted.Close() }()
+	if _, err := trusted.VegaLiteToSVG(spec); err != nil {
+		t.Errorf("WithTrustedSpec should lift the request limit: %v", err)
+	}
+}
+
+func TestWithMaxMarks(t *testing.T) {
+	spec, err := os.ReadFile("testdata/bar-chart.vl.json")
+	if err != nil {
+		t.Fatalf("reading test spec: %v", err)
+	}
+
+	c, err := aster.New(aster.WithMaxMarks(5))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	if _, err := c.VegaLiteToSVG(spec); !errors.Is(err, aster.ErrLimitExceeded) {
+		t.Errorf("VegaLiteToSVG: expected ErrLimitExceeded, got %v", err)
+	}
+	if _, err := c.VegaLiteToPNG(spec); !errors.Is(err, aster.ErrLimitExceeded) {
+		t.Errorf("VegaLiteToPNG: expected ErrLimitExceeded, got %v", err)
+	}
+	// Compiling draws nothing, so the limit does not apply.
+	if _, err := c.VegaLiteToVega(spec); err != nil {
+		t.Errorf("VegaLiteToVega: %v", err)
+	}
+
+	trusted, err := aster.New(aster.WithMaxMarks(5), aster.WithTrustedSpec())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = trusted.Close() }()
+
+	if _, err := trusted.VegaLiteToSVG(spec); err != nil {
+		t.Errorf("trusted spec should bypass the limit: %v", err)
+	}
+}
@@ -3,23 +3,83 @@
 //
 // Go registers these globals before this module loads:
 //   __aster_load(url)          → async, returns string (or throws)
+//   __aster_load_format(url)   → sync, returns the loaded data's format or ""
 //   __aster_sanitize(uri)      → sync, returns sanitized string (or throws)
 //   __aster_measure_text(text, font) → sync, returns number (width in px)
+//   __aster_metric(phase, ms)  → sync, records a phase duration
+//   __aster_warn(message)      → sync, records a warning
+//   __aster_check_marks(count) → sync, throws if a render has too many marks
+//                                (only registered under WithMaxMarks)
+//
+// and, when WithLogLevel is set, the string global __aster_log_level;
+// __aster_full_precision is true when WithFullPrecision is set,
+// __aster_non_finite is true when WithNonFiniteNumbers is set,
+// __aster_native_tooltips is true when WithNativeTooltips is set, and the
+// string global __aster_base_url holds the WithDataBaseURL base. Go sets
+// __aster_skip_measure to true for renders that estimate text widths
+// instead of calling __aster_measure_text.
 
 import * as vega from "vega";
 import * as vegaLite from "vega-lite";
-import { resetSVGDefIds } from "vega-scenegraph";
+import { resetSVGDefIds, sceneVisit } from "vega-scenegraph";
+import * as d3path from "d3-path";
+
+// dataBaseURL returns the configured WithDataBaseURL base, or "".
+function dataBaseURL() {
+  return typeof __aster_base_url === "string" ? __aster_base_url : "";
+}
+
+// URIs Vega treats as absolute: data URIs and anything with a protocol or
+// a protocol-relative host.
+const PROTOCOL_RE = /^(data:|([A-Za-z]+:)?\/\/)/;
+
+// Prefix a relative URI with the base URL, the way Vega's own sanitize
+// applies its baseURL option: plain concatenation, with a slash inserted
+// when neither side has one.
+function resolveBaseURL(uri) {
+  const base = dataBaseURL();
+  if (!base || typeof uri !== "string" || PROTOCOL_RE.test(uri)) return uri;
+  if (!uri.startsWith("/") && !base.endsWith("/")) uri = "/" + uri;
+  return base + uri;
+}
+
+// Text loaded for the current view that the Go loader reported, or
+// sniffed, as CSV or TSV.
+const delimitedText = new Map();
+
+// Vega-Lite gives URLs without a known extension format.type "json". Read
+// text known to be CSV or TSV with the matching reader instead, so
+// extensionless endpoints that serve CSV still parse.
+const readJSON = vega.formats("json");
+if (readJSON) {
+  const read = function (data, format) {
+    const detected = typeof data === "string" ? delimitedText.get(data) : undefined;
+    if (detected) {
+      return vega.formats(detected)(data, { ...format, type: detected });
+    }
+    return readJSON(data, format);
+  };
+  read.responseType = readJSON.responseType;
+  vega.formats("json", read);
+}
 
 // Create a custom Vega loader that delegates to Go callbacks.
 function createLoader() {
-  const loader = vega.loader();
+  delimitedText.clear();
+  const base = dataBaseURL();
+  const loader = base ? vega.loader({ baseURL: base }) : vega.loader();
 
   // Override http to use Go's loader.
   loader.http = async function (url, options) {
     if (typeof __aster_load !== "function") {
       throw new Error("aster: resource loading denied (no loader configured)");
     }
-    return await __aster_load(url);
+    const text = await __aster_load(url);
+    const format = typeof __aster_load_format === "function" ? __aster_load_format(url) : "";
+    if (format === "csv" || format === "tsv") {
+      delimitedText.set(text, format);
+    }
+    return text;
   };
 
   // Override file to always deny (Go controls all I/O).
@@ -32,6 +92,7 @@ function createLoader() {
   // Override sanitize to use Go's sanitizer.
   const origSanitize = loader.sanitize.bind(loader);
   loader.sanitize = async function (uri, options) {
+    uri = resolveBaseURL(uri);
     if (typeof __aster_sanitize === "function") {
       const sanitized = __aster_sanitize(uri);
       return { href: sanitized };
@@ -42,13 +103,128 @@ function createLoader() {
   return loader;
 }
 
+// d3-shape builds mark paths with d3-path rounded to 3 decimals. For full
+// precision, make every Path use the unrounded appender: Path's constructor
+// assigns this._append, which this prototype setter swallows.
+if (typeof __aster_full_precision !== "undefined" && __aster_full_precision && d3path.Path) {
+  const appendExact = function (strings) {
+    this._ += strings[0];
+    for (let i = 1, n = strings.length; i < n; ++i) {
+      this._ += arguments[i] + strings[i];
+    }
+  };
+  Object.defineProperty(d3path.Path.prototype, "_append", {
+    get() {
+      return appendExact;
+    },
+    set(_) {},
+  });
+}
+
+// Placeholder strings that carry NaN and ±Infinity through JSON, which has
+// no literal for them. Go quotes bare literals in input specs this way.
+const NON_FINITE = {
+  __aster_NaN__: NaN,
+  __aster_Infinity__: Infinity,
+  "__aster_-Infinity__": -Infinity,
+};
+
+function nonFiniteEnabled() {
+  return typeof __aster_non_finite !== "undefined" && __aster_non_finite;
+}
+
+// Parse a spec, reviving non-finite placeholders under WithNonFiniteNumbers.
+function parseSpec(json) {
+  if (!nonFiniteEnabled()) return JSON.parse(json);
+  return JSON.parse(json, function (key, value) {
+    if (typeof value === "string" && Object.hasOwn(NON_FINITE, value)) {
+      return NON_FINITE[value];
+    }
+    return value;
+  });
+}
+
+// Serialize a spec, writing non-finite numbers as placeholders under
+// WithNonFiniteNumbers (JSON.stringify would write null).
+function stringifySpec(spec) {
+  if (!nonFiniteEnabled()) return JSON.stringify(spec);
+  return JSON.stringify(spec, function (key, value) {
+    if (typeof value === "number" && !Number.isFinite(value)) {
+      if (Number.isNaN(value)) return "__aster_NaN__";
+      return value > 0 ? "__aster_Infinity__" : "__aster_-Infinity__";
+    }
+    return value;
+  });
+}
+
+// Vega log levels by the names accepted by WithLogLevel. Messages go to
+// Vega's default console handler.
+const LOG_LEVELS = {
+  none: vega.None,
+  error: vega.Error,
+  warn: vega.Warn,
+  info: vega.Info,
+  debug: vega.Debug,
+};
+
+// logLevel returns the configured Vega log level, or undefined when
+// WithLogLevel is unset.
+function logLevel() {
+  if (typeof __aster_log_level !== "string") return undefined;
+  return LOG_LEVELS[__aster_log_level];
+}
+
+// Create a Vega logger at the configured log level that also hands every
+// warning to Go, whether or not the level lets it print.
+function createLogger() {
+  const level = logLevel();
+  const logger = vega.logger(level !== undefined ? level : vega.Warn);
+  const warn = logger.warn;
+  logger.warn = function (...args) {
+    if (typeof __aster_warn === "function") {
+      __aster_warn(args.map(String).join(" "));
+    }
+    return warn.apply(this, args);
+  };
+  return logger;
+}
+
+// Create a headless view with the custom loader and configured log level.
+function createView(runtime) {
+  // Views are always headless ("none"): there is no DOM to draw into, and
+  // toSVG() serializes the scene graph independently of the view renderer.
+  return new vega.View(runtime, {
+    renderer: "none",
+    loader: createLoader(),
+    logger: createLogger(),
+  });
+}
+
+// Report the time elapsed since start (a performance.now() value) for a
+// render phase: "compile", "parse", "dataflow" or "serialize".
+function reportPhase(phase, start) {
+  if (typeof __aster_metric === "function") {
+    __aster_metric(phase, performance.now() - start);
+  }
+}
+
 // Override text measurement if Go provides it.
 if (typeof __aster_measure_text === "function") {
   // vega.textMetrics is the module-level object used by the scenegraph.
   if (vega.textMetrics) {
     const origWidth = vega.textMetrics.width;
+    // Vega's default estimation, for when Go measurement is skipped or fails.
+    const estimate = function (item, text) {
+      if (typeof origWidth === "function") {
+        return origWidth(item, text);
+      }
+      return String(text).length * (item.fontSize || 11) * 0.6;
+    };
+    // Vega also calls this when truncating a label to its limit, measuring
+    // the ellipsis ("…" or item.ellipsis) and the kept prefix separately.
     vega.textMetrics.width = function (item, text) {
       if (text == null || text === "") return 0;
+      if (globalThis.__aster_skip_measure === true) return estimate(item, text);
       const str = String(text);
       // Build a CSS font string from the item properties.
       const fontSize = item.fontSize || 11;
@@ -61,53 +237,190 @@ if (typeof __aster_measure_text === "function") {
         return __aster_measure_text(str, cssFont);
       } catch (e) {
         // Fall back to Vega's default estimation if Go measurement fails.
-        if (typeof origWidth === "function") {
-          return origWidth(item, text);
-        }
-        return str.length * fontSize * 0.6;
+        return estimate(item, text);
       }
     };
   }
 }
 
+const NATIVE_TOOLTIPS =
+  typeof __aster_native_tooltips !== "undefined" && __aster_native_tooltips === true;
+
+// Marks drawn as one SVG element for all their items.
+const SINGLE_ELEMENT_MARKS = new Set(["area", "line", "trail"]);
+
+/**
+ * Format a scenegraph item's tooltip as plain text, like Vega's tooltip
+ * handler: objects become one "key: value" line per entry.
+ * @param {*} tip - the item's tooltip value
+ * @returns {string|undefined}
+ */
+function tooltipText(tip) {
+  if (tip === undefined || tip === null || tip === "") return undefined;
+  if (typeof tip !== "object") return String(tip);
+  if (Array.isArray(tip)) return JSON.stringify(tip);
+  return Object.entries(tip)
+    .map(([key, value]) =>
+      `${key}: ${value !== null && typeof value === "object" ? JSON.stringify(value) : value}`)
+    .join("\n");
+}
+
+/**
+ * Collect the tooltip text of every item, per mark, in the order the SVG
+ * renderer emits the marks' <g> elements. Marks without tooltips are null.
+ * @param {object} view - Vega view whose dataflow has run
+ * @returns {Array<Array<string|undefined>|null>}
+ */
+function collectTooltips(view) {
+  const marks = [];
+  const visit = (mark) => {
+    if (mark.marktype === "group") {
+      marks.push(null);
+      sceneVisit(mark, (item) => sceneVisit(item, visit));
+      return;
+    }
+    let tips = [];
+    sceneVisit(mark, (item) => {
+      tips.push(tooltipText(item.tooltip));
+    });
+    if (SINGLE_ELEMENT_MARKS.has(mark.marktype)) tips = tips.slice(0, 1);
+    marks.push(tips.some((t) => t !== undefined) ? tips : null);
+  };
+  visit(view.scenegraph().root);
+  return marks;
+}
+
+// The index just past the start tag that opens at i, skipping quoted
+// attribute values.
+function tagEnd(svg, i) {
+  let quote = "";
+  for (; i < svg.length; i++) {
+    const ch = svg[i];
+    if (quote) {
+      if (ch === quote) quote = "";
+    } else if (ch === '"' || ch === "'") {
+      quote = ch;
+    } else if (ch === ">") {
+      return i + 1;
+    }
+  }
+  return svg.length;
+}
+
+function escapeXML(s) {
+  return s.replace(/&/g, "&amp;").replace(/</g, "&lt;").replace(/>/g, "&gt;");
+}
+
+/**
+ * Give each item element of the SVG a <title> child holding its tooltip, so
+ * viewers show it on hover without JavaScript.
+ * @param {string} svg - SVG markup from view.toSVG()
+ * @param {Array} marks - tooltips from collectTooltips
+ * @returns {string}
+ */
+function insertTooltipTitles(svg, marks) {
+  const markRe = /<g\b[^>]*?\bclass="mark-/g;
+  let out = "";
+  let last = 0;
+  let k = 0;
+  let m;
+  while (k < marks.length && (m = markRe.exec(svg)) !== null) {
+    const tips = marks[k++];
+    if (!tips) continue;
+    let i = tagEnd(svg, m.index);
+    out += svg.slice(last, i);
+    // An item element per tip, as direct children of the mark's <g>.
+    for (const tip of tips) {
+      if (svg[i] !== "<" || svg[i + 1] === "/") break;
+      const open = tagEnd(svg, i);
+      const name = /^<([\w:-]+)/.exec(svg.slice(i, open))[1];
+      const title = tip === undefined ? "" : `<title>${escapeXML(tip)}</title>`;
+      if (svg[open - 2] === "/") {
+        out += svg.slice(i, open - 2).trimEnd() + ">" + title + `</${name}>`;
+        i = open;
+      } else {
+        const close = svg.indexOf(`</${name}>`, open);
+        const end = close < 0 ? svg.length : close + name.length + 3;
+        out += svg.slice(i, open) + title + svg.slice(open, end);
+        i = end;
+      }
+    }
+    last = i;
+    markRe.lastIndex = i;
+  }
+  return out + svg.slice(last);
+}
+
+/**
+ * Count the items of a scenegraph: every mark instance, groups included.
+ * @param {object} root - the view's root scenegraph mark
+ * @returns {number}
+ */
+function countMarks(root) {
+  let n = 0;
+  const visit = (mark) => {
+    sceneVisit(mark, (item) => {
+      n++;
+      if (mark.marktype === "group") sceneVisit(item, visit);
+    });
+  };
+  visit(root);
+  return n;
+}
+
 /**
  * Compile a Vega-Lite spec to a Vega spec.
  * @param {string} specJSON - Vega-Lite spec as JSON string
  * @returns {string} - Vega spec as JSON string
  */
 export function vegaLiteToVega(specJSON) {
-  const vlSpec = JSON.parse(specJSON);
-  const vgSpec = vegaLite.compile(vlSpec).spec;
-  return JSON.stringify(vgSpec);
+  const vlSpec = parseSpec(specJSON);
+  const vgSpec = vegaLite.compile(vlSpec, { logger: createLogger() }).spec;
+  return stringifySpec(vgSpec);
 }
 
 /**
  * Render a Vega spec to SVG.
  * @param {string} specJSON - Vega spec as JSON string
  * @param {string} [theme] - Optional Vega theme config JSON
+ * @param {function} [prepareScene] - Called with the view after the
+ *   dataflow runs, to adjust the scenegraph before it is serialized
  * @returns {Promise<string>} - SVG string
  */
-export async function vegaToSvg(specJSON, theme) {
+export async function vegaToSvg(specJSON, theme, prepareScene) {
   // Reset clip-path/gradient ID counters so each render produces
   // deterministic IDs regardless of how many renders preceded it.
   resetSVGDefIds();
 
-  const spec = JSON.parse(specJSON);
-  const loader = createLoader();
+  const spec = parseSpec(specJSON);
 
   const runtimeOpts = {};
   if (theme) {
     runtimeOpts.config = JSON.parse(theme);
   }
 
+  let start = performance.now();
   const runtime = vega.parse(spec, runtimeOpts.config);
-  const view = new vega.View(runtime, {
-    renderer: "none",
-    loader: loader,
-  });
+  reportPhase("parse", start);
+  const view = createView(runtime);
 
   try {
-    const svg = await view.toSVG();
+    start = performance.now();
+    await view.runAsync();
+    reportPhase("dataflow", start);
+    if (prepareScene) {
+      prepareScene(view);
+    }
+    if (typeof __aster_check_marks === "function") {
+      __aster_check_marks(countMarks(view.scenegraph().root));
+    }
+    const tooltips = NATIVE_TOOLTIPS ? collectTooltips(view) : null;
+    start = performance.now();
+    let svg = await view.toSVG();
+    if (tooltips) {
+      svg = insertTooltipTitles(svg, tooltips);
+    }
+    reportPhase("serialize", start);
     return svg;
   } finally {
     view.finalize();
@@ -121,6 +434,218 @@ export async function vegaToSvg(specJSON, theme) {
  * @returns {Promise<string>} - SVG string
  */
 export async function vegaLiteToSvg(specJSON, theme) {
+  const start = performance.now();
   const vgSpecJSON = vegaLiteToVega(specJSON);
+  reportPhase("compile", start);
   return await vegaToSvg(vgSpecJSON, theme);
 }
+
+/**
+ * Empty every mark in the view's scenegraph that is not a legend or a group
+ * holding one, and return the legends' bounds in SVG user coordinates.
+ * @param {object} view - Vega view whose dataflow has run
+ * @returns {number[]} - [x1, y1, x2, y2]
+ */
+function isolateLegends(view) {
+  const box = [Infinity, Infinity, -Infinity, -Infinity];
+  const visit = (mark, dx, dy) => {
+    if (mark.role === "legend") {
+      for (const item of mark.items) {
+        const b = item.bounds;
+        if (!b || b.empty()) continue;
+        box[0] = Math.min(box[0], b.x1 + dx);
+        box[1] = Math.min(box[1], b.y1 + dy);
+        box[2] = Math.max(box[2], b.x2 + dx);
+        box[3] = Math.max(box[3], b.y2 + dy);
+      }
+      return true;
+    }
+    let found = false;
+    if (mark.marktype === "group") {
+      for (const item of mark.items) {
+        const x = dx + (item.x || 0);
+        const y = dy + (item.y || 0);
+        for (const child of item.items || []) {
+          if (visit(child, x, y)) found = true;
+        }
+      }
+    }
+    if (!found) {
+      mark.items = [];
+    }
+    return found;
+  };
+  // The renderer translates the scene by the view's padding origin.
+  const origin = view._origin || [0, 0];
+  visit(view.scenegraph().root, origin[0], origin[1]);
+  if (!(box[0] <= box[2] && box[1] <= box[3])) {
+    throw new Error("aster: spec has no legend");
+  }
+  return box;
+}
+
+/**
+ * Render only the legends of a Vega-Lite spec to SVG.
+ * @param {string} specJSON - Vega-Lite spec as JSON string
+ * @param {string} [theme] - Optional Vega theme config JSON
+ * @returns {Promise<string>} - JSON {svg, bounds}, bounds being the
+ *   legends' [x1, y1, x2, y2] in the SVG's user coordinates
+ */
+export async function vegaLiteToLegendSvg(specJSON, theme) {
+  const start = performance.now();
+  const vgSpecJSON = vegaLiteToVega(specJSON);
+  reportPhase("compile", start);
+  let bounds;
+  const svg = await vegaToSvg(vgSpecJSON, theme, (view) => {
+    bounds = isolateLegends(view);
+  });
+  return JSON.stringify({ svg, bounds });
+}
+
+/**
+ * Run a Vega spec's dataflow and return the bounds of everything it draws.
+ * @param {string} specJSON - Vega spec as JSON string
+ * @param {string} [theme] - Optional Vega theme config JSON
+ * @returns {Promise<string>} - JSON [x1, y1, x2, y2] in the SVG's user
+ *   coordinates
+ */
+export async function vegaContentBounds(specJSON, theme) {
+  const config = theme ? JSON.parse(theme) : undefined;
+  const view = createView(vega.parse(parseSpec(specJSON), config));
+
+  try {
+    await view.runAsync();
+    const b = view.scenegraph().root.bounds;
+    if (!b || b.empty()) {
+      throw new Error("aster: spec draws nothing");
+    }
+    // The renderer translates the scene by the view's padding origin.
+    const origin = view._origin || [0, 0];
+    return JSON.stringify([
+      b.x1 + origin[0],
+      b.y1 + origin[1],
+      b.x2 + origin[0],
+      b.y2 + origin[1],
+    ]);
+  } finally {
+    view.finalize();
+  }
+}
+
+/**
+ * Find the name of the dataset that feeds the spec's primary marks: the
+ * first non-group mark's `from.data` (or a facet's source), searched
+ * depth-first. Falls back to the last declared dataset.
+ * @param {object} spec - Vega spec
+ * @returns {string|undefined}
+ */
+function primaryDataName(spec) {
+  const visit = (marks) => {
+    for (const mark of marks || []) {
+      const from = mark.from || {};
+      if (mark.type === "group") {
+        if (from.facet && from.facet.data) return from.facet.data;
+        const nested = visit(mark.marks);
+        if (nested) return nested;
+      } else if (from.data) {
+        return from.data;
+      }
+    }
+    return undefined;
+  };
+  const found = visit(spec.marks);
+  if (found) return found;
+  const data = spec.data || [];
+  return data.length > 0 ? data[data.length - 1].name : undefined;
+}
+
+/**
+ * Compile a Vega-Lite spec, run its dataflow, and return the primary
+ * dataset after all transforms.
+ * @param {string} specJSON - Vega-Lite spec as JSON string
+ * @param {string} [theme] - Optional Vega theme config JSON
+ * @returns {Promise<string>} - JSON {name, columns, values}
+ */
+export async function vegaLiteToData(specJSON, theme) {
+  const spec = parseSpec(vegaLiteToVega(specJSON));
+  const name = primaryDataName(spec);
+  if (!name) {
+    throw new Error("aster: spec has no datasets");
+  }
+
+  const config = theme ? JSON.parse(theme) : undefined;
+  const view = createView(vega.parse(spec, config));
+
+  try {
+    // Only the dataflow runs; nothing is serialized.
+    await view.runAsync();
+    const values = view.data(name);
+    // Union of keys in first-seen order, for stable CSV columns.
+    const columns = [];
+    const seen = new Set();
+    for (const row of values) {
+      for (const key of Object.keys(row)) {
+        if (!seen.has(key)) {
+          seen.add(key);
+          columns.push(key);
+        }
+      }
+    }
+    return JSON.stringify({ name, columns, values });
+  } finally {
+    view.finalize();
+  }
+}
+
+/**
+ * Run a Vega spec's dataflow and return every signal's current value,
+ * including Vega's built-in signals (width, height, padding, ...).
+ * @param {string} specJSON - Vega spec as JSON string
+ * @param {string} [theme] - Optional Vega theme config JSON
+ * @returns {Promise<string>} - JSON object of signal name → value
+ */
+export async function vegaSignals(specJSON, theme) {
+  const config = theme ? JSON.parse(theme) : undefined;
+  const view = createView(vega.parse(parseSpec(specJSON), config));
+
+  try {
+    // Run once so signals with init/update expressions hold their values.
+    await view.runAsync();
+    const signals = {};
+    for (const name of Object.keys(view._runtime.signals)) {
+      signals[name] = view.signal(name);
+    }
+    return JSON.stringify(signals);
+  } finally {
+    view.finalize();
+  }
+}
+
+/**
+ * Run a Vega spec's dataflow once and return the current values of the
+ * named signals and datasets.
+ * @param {string} specJSON - Vega spec as JSON string
+ * @param {string} namesJSON - JSON {signals: string[], data: string[]}
+ * @param {string} [theme] - Optional Vega theme config JSON
+ * @returns {Promise<string>} - JSON {signals, data}
+ */
+export async function vegaState(specJSON, namesJSON, theme) {
+  const names = JSON.parse(namesJSON);
+  const config = theme ? JSON.parse(theme) : undefined;
+  const view = createView(vega.parse(parseSpec(specJSON), config));
+
+  try {
+    await view.runAsync();
+    const signals = {};
+    for (const name of names.signals) {
+      signals[name] = view.signal(name);
+    }
+    const data = {};
+    for (const name of names.data) {
+      data[name] = view.data(name);
+    }
+    return JSON.stringify({ signals, data });
+  } finally {
+    view.finalize();
+  }
+}
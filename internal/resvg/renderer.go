@@ -2,9 +2,13 @@ package resvg
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"math"
+	"strings"
+	"sync"
 
+	"github.com/mgilbir/aster/internal/svgdoc"
 	"github.com/tetratelabs/wazero"
 	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
@@ -19,11 +23,15 @@ type Font struct {
 // specific loaded font family name (e.g. "Liberation Sans").
 type FamilyMapping struct {
 	SansSerif string
+	Serif     string
 	Monospace string
 }
 
 // Renderer renders SVG to PNG via resvg compiled to WASM.
+// It is safe for concurrent use: the module's linear memory and its result
+// buffers are shared, so Render calls are serialized.
 type Renderer struct {
+	mu      sync.Mutex // guards all calls into module
 	runtime wazero.Runtime
 	module  api.Module
 
@@ -32,17 +40,32 @@ type Renderer struct {
 	fnFontDBInit         api.Function
 	fnFontDBAdd          api.Function
 	fnFontDBSetSansSerif api.Function
+	fnFontDBSetSerif     api.Function // optional; nil in builds without serif mapping
 	fnFontDBSetMonospace api.Function
+	fnSetLanguages       api.Function // optional; nil in builds without language support
 	fnRender             api.Function
 	fnResultPtr          api.Function
 	fnResultLen          api.Function
 	fnErrorPtr           api.Function
 	fnErrorLen           api.Function
+
+	// aliases rewrites generic families in the SVG before rendering, for
+	// mappings the embedded WASM has no setter for.
+	aliases map[string]string
 }
 
 // New creates a Renderer, initializes the font database, loads the given fonts,
 // and configures generic font family mappings.
-func New(ctx context.Context, fonts []Font, families FamilyMapping) (*Renderer, error) {
+//
+// If the embedded WASM has no font_db_set_serif export, the serif mapping is
+// applied by rewriting generic serif font-family entries in each SVG.
+//
+// languages lists BCP 47 tags (e.g. "ja", "zh-Hans"), in priority order,
+// that resvg matches against systemLanguage attributes to pick a <switch>
+// child; nil keeps resvg's default ("en"). New fails with an error wrapping
+// errors.ErrUnsupported if languages are given but the embedded WASM
+// predates the set_languages export.
+func New(ctx context.Context, fonts []Font, families FamilyMapping, languages []string) (*Renderer, error) {
 	rt := wazero.NewRuntime(ctx)
 
 	if _, err := wasi_snapshot_preview1.Instantiate(ctx, rt); err != nil {
@@ -74,7 +97,9 @@ func New(ctx context.Context, fonts []Font, families FamilyMapping) (*Renderer,
 		fnFontDBInit:         mod.ExportedFunction("font_db_init"),
 		fnFontDBAdd:          mod.ExportedFunction("font_db_add"),
 		fnFontDBSetSansSerif: mod.ExportedFunction("font_db_set_sans_serif"),
+		fnFontDBSetSerif:     mod.ExportedFunction("font_db_set_serif"),
 		fnFontDBSetMonospace: mod.ExportedFunction("font_db_set_monospace"),
+		fnSetLanguages:       mod.ExportedFunction("set_languages"),
 		fnRender:             mod.ExportedFunction("render"),
 		fnResultPtr:          mod.ExportedFunction("result_ptr"),
 		fnResultLen:          mod.ExportedFunction("result_len"),
@@ -119,24 +144,45 @@ func New(ctx context.Context, fonts []Font, families FamilyMapping) (*Renderer,
 
 	// Configure generic font family mappings.
 	if families.SansSerif != "" {
-		if err := r.setFamily(ctx, r.fnFontDBSetSansSerif, families.SansSerif); err != nil {
+		if err := r.callWithString(ctx, r.fnFontDBSetSansSerif, families.SansSerif); err != nil {
 			_ = rt.Close(ctx)
 			return nil, fmt.Errorf("resvg: set sans-serif family: %w", err)
 		}
 	}
+	if families.Serif != "" && r.fnFontDBSetSerif == nil {
+		r.aliases = map[string]string{"serif": families.Serif}
+	} else if families.Serif != "" {
+		if err := r.callWithString(ctx, r.fnFontDBSetSerif, families.Serif); err != nil {
+			_ = rt.Close(ctx)
+			return nil, fmt.Errorf("resvg: set serif family: %w", err)
+		}
+	}
 	if families.Monospace != "" {
-		if err := r.setFamily(ctx, r.fnFontDBSetMonospace, families.Monospace); err != nil {
+		if err := r.callWithString(ctx, r.fnFontDBSetMonospace, families.Monospace); err != nil {
 			_ = rt.Close(ctx)
 			return nil, fmt.Errorf("resvg: set monospace family: %w", err)
 		}
 	}
 
+	// Configure the languages <switch> elements are resolved for.
+	if len(languages) > 0 && r.fnSetLanguages == nil {
+		_ = rt.Close(ctx)
+		return nil, fmt.Errorf("resvg: languages need the set_languages export, which the embedded resvg.wasm lacks (rebuild it with make vendor-resvg): %w", errors.ErrUnsupported)
+	}
+	if len(languages) > 0 {
+		if err := r.callWithString(ctx, r.fnSetLanguages, strings.Join(languages, "\n")); err != nil {
+			_ = rt.Close(ctx)
+			return nil, fmt.Errorf("resvg: set languages: %w", err)
+		}
+	}
+
 	return r, nil
 }
 
-// setFamily writes a family name into WASM memory and calls the given setter function.
-func (r *Renderer) setFamily(ctx context.Context, fn api.Function, name string) error {
-	data := []byte(name)
+// callWithString writes a string into WASM memory and calls the given setter
+// function with its pointer and length.
+func (r *Renderer) callWithString(ctx context.Context, fn api.Function, value string) error {
+	data := []byte(value)
 	size := uint64(len(data))
 
 	results, err := r.fnAllocMem.Call(ctx, size)
@@ -147,19 +193,19 @@ func (r *Renderer) setFamily(ctx context.Context, fn api.Function, name string)
 
 	if !r.module.Memory().Write(uint32(ptr), data) {
 		_, _ = r.fnDeallocMem.Call(ctx, ptr, size)
-		return fmt.Errorf("write family name: out of bounds")
+		return fmt.Errorf("write string: out of bounds")
 	}
 
 	results, err = fn.Call(ctx, ptr, size)
 	if err != nil {
 		_, _ = r.fnDeallocMem.Call(ctx, ptr, size)
-		return fmt.Errorf("set family: %w", err)
+		return fmt.Errorf("call: %w", err)
 	}
 
 	_, _ = r.fnDeallocMem.Call(ctx, ptr, size)
 
 	if int32(results[0]) < 0 {
-		return fmt.Errorf("set family: %s", r.readError(ctx))
+		return errors.New(r.readError(ctx))
 	}
 
 	return nil
@@ -195,8 +241,25 @@ func (r *Renderer) addFont(ctx context.Context, data []byte) error {
 	return nil
 }
 
-// Render converts SVG bytes to PNG at the given scale factor.
+// Render converts SVG bytes to PNG at the given scale factor. The output is
+// the SVG's pixel size times scale with each dimension rounded half up, so
+// fractional scales give deterministic sizes: 100px at 1.5 is 150px.
 func (r *Renderer) Render(ctx context.Context, svg []byte, scale float64) ([]byte, error) {
+	// Apply the scale to the root size here so the rounding does not depend
+	// on the embedded module, older builds of which round the scaled size up
+	// and so turn float error (100 * 1.1) into an extra pixel.
+	if r.aliases != nil {
+		svg = []byte(svgdoc.ReplaceFontFamilies(string(svg), r.aliases))
+	}
+	if scale != 1 {
+		if scaled, ok := svgdoc.ScaleRoot(string(svg), scale); ok {
+			svg, scale = []byte(scaled), 1
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	size := uint64(len(svg))
 
 	results, err := r.fnAllocMem.Call(ctx, size)
@@ -220,12 +283,21 @@ func (r *Renderer) Render(ctx context.Context, svg []byte, scale float64) ([]byt
 	_, _ = r.fnDeallocMem.Call(ctx, svgPtr, size)
 
 	if int32(results[0]) < 0 {
-		return nil, fmt.Errorf("resvg: %s", r.readError(ctx))
+		return nil, &Error{Message: r.readError(ctx)}
 	}
 
 	return r.readResult(ctx)
 }
 
+// Error is a render failure reported by resvg itself, such as an SVG parse
+// error. Message is resvg's message verbatim, which names the offending
+// element and its position.
+type Error struct {
+	Message string
+}
+
+func (e *Error) Error() string { return "resvg: " + e.Message }
+
 // readResult reads the PNG result buffer from WASM memory.
 func (r *Renderer) readResult(ctx context.Context) ([]byte, error) {
 	ptrResults, err := r.fnResultPtr.Call(ctx)
@@ -255,7 +327,8 @@ func (r *Renderer) readResult(ctx context.Context) ([]byte, error) {
 	return out, nil
 }
 
-// readError reads the error message from WASM memory.
+// readError reads the error message from WASM memory, in full: the module
+// reports its length, so nothing is cut at a fixed size or a NUL byte.
 func (r *Renderer) readError(ctx context.Context) string {
 	ptrResults, err := r.fnErrorPtr.Call(ctx)
 	if err != nil {
@@ -283,6 +356,9 @@ func (r *Renderer) readError(ctx context.Context) string {
 
 // Close releases all resources held by the Renderer.
 func (r *Renderer) Close(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	if r.runtime != nil {
 		return r.runtime.Close(ctx)
 	}
@@ -0,0 +1,249 @@
+package resvg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mgilbir/aster/internal/textmeasure/fonts/liberation"
+	"github.com/tetratelabs/wazero"
+)
+
+// hasExport reports whether the embedded WASM exports the named function.
+func hasExport(t *testing.T, name string) bool {
+	t.Helper()
+	ctx := context.Background()
+	rt := wazero.NewRuntime(ctx)
+	defer func() { _ = rt.Close(ctx) }()
+	compiled, err := rt.CompileModule(ctx, wasmBytes)
+	if err != nil {
+		t.Fatalf("CompileModule: %v", err)
+	}
+	_, ok := compiled.ExportedFunctions()[name]
+	return ok
+}
+
+// The language list picks the <switch> child whose systemLanguage matches,
+// so a "ja" renderer draws the Japanese branch and the default one the
+// fallback.
+func TestRenderWithLanguages(t *testing.T) {
+	ctx := context.Background()
+	if !hasExport(t, "set_languages") {
+		_, err := New(ctx, nil, FamilyMapping{}, []string{"ja", "en"})
+		if !errors.Is(err, errors.ErrUnsupported) {
+			t.Fatalf("New without set_languages = %v; want an error wrapping errors.ErrUnsupported", err)
+		}
+		t.Skip("embedded resvg.wasm predates set_languages; rebuild it with make vendor-resvg")
+	}
+
+	svg := []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="4" height="4"><switch>` +
+		`<rect systemLanguage="ja" width="4" height="4" fill="#ff0000"/>` +
+		`<rect width="4" height="4" fill="#0000ff"/></switch></svg>`)
+	center := func(languages []string) color.NRGBA {
+		r, err := New(ctx, nil, FamilyMapping{}, languages)
+		if err != nil {
+			t.Fatalf("New(%v): %v", languages, err)
+		}
+		defer func() { _ = r.Close(ctx) }()
+		data, err := r.Render(ctx, svg, 1)
+		if err != nil {
+			t.Fatalf("Render(%v): %v", languages, err)
+		}
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("png.Decode: %v", err)
+		}
+		return color.NRGBAModel.Convert(img.At(2, 2)).(color.NRGBA)
+	}
+	if got, want := center([]string{"ja", "en"}), (color.NRGBA{0xff, 0, 0, 0xff}); got != want {
+		t.Errorf("ja renderer drew %v; want the systemLanguage=ja branch %v", got, want)
+	}
+	if got, want := center(nil), (color.NRGBA{0, 0, 0xff, 0xff}); got != want {
+		t.Errorf("default renderer drew %v; want the fallback branch %v", got, want)
+	}
+}
+
+// Remapping sans-serif to Liberation Mono must make generic sans-serif text
+// rasterize exactly like monospace text.
+func TestRenderFamilyMapping(t *testing.T) {
+	ctx := context.Background()
+	fonts := []Font{{Data: liberation.SansRegular}, {Data: liberation.MonoRegular}}
+	r, err := New(ctx, fonts, FamilyMapping{
+		SansSerif: "Liberation Mono",
+		Serif:     "Liberation Sans",
+		Monospace: "Liberation Mono",
+	}, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = r.Close(ctx) }()
+
+	render := func(family string) []byte {
+		svg := fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="80" height="20"><text x="0" y="15" font-family="%s" font-size="12">Wiggle</text></svg>`, family)
+		data, err := r.Render(ctx, []byte(svg), 1)
+		if err != nil {
+			t.Fatalf("Render %s: %v", family, err)
+		}
+		return data
+	}
+	if !bytes.Equal(render("sans-serif"), render("monospace")) {
+		t.Error("sans-serif text should render with the mapped monospace face")
+	}
+}
+
+// Remapping serif to Liberation Mono must make generic serif text rasterize
+// like monospace text, whether or not the WASM has a serif setter.
+func TestRenderSerifMapping(t *testing.T) {
+	ctx := context.Background()
+	fonts := []Font{{Data: liberation.SansRegular}, {Data: liberation.MonoRegular}}
+	r, err := New(ctx, fonts, FamilyMapping{
+		SansSerif: "Liberation Sans",
+		Serif:     "Liberation Mono",
+		Monospace: "Liberation Mono",
+	}, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = r.Close(ctx) }()
+
+	render := func(family string) []byte {
+		svg := fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="80" height="20"><text x="0" y="15" font-family="%s" font-size="12">Wiggle</text></svg>`, family)
+		data, err := r.Render(ctx, []byte(svg), 1)
+		if err != nil {
+			t.Fatalf("Render %s: %v", family, err)
+		}
+		return data
+	}
+	serif, mono := render("serif"), render("monospace")
+	if !bytes.Equal(serif, mono) {
+		t.Error("serif text should render with the mapped monospace face")
+	}
+	if bytes.Equal(serif, render("sans-serif")) {
+		t.Error("serif text should not render with the sans-serif face")
+	}
+}
+
+// Vega emits a spec's background as a full-size <rect> ahead of the marks;
+// resvg must paint it rather than leaving the canvas transparent.
+func TestRenderBackgroundRect(t *testing.T) {
+	ctx := context.Background()
+	r, err := New(ctx, nil, FamilyMapping{}, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = r.Close(ctx) }()
+
+	svg := `<svg xmlns="http://www.w3.org/2000/svg" version="1.1" class="marks" width="20" height="10" viewBox="0 0 20 10"><rect width="20" height="10" fill="#eee"/><g fill="none"></g></svg>`
+	data, err := r.Render(ctx, []byte(svg), 1)
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("png.Decode: %v", err)
+	}
+	b := img.Bounds()
+	for _, p := range []image.Point{b.Min, {b.Max.X - 1, b.Max.Y - 1}} {
+		got := color.NRGBAModel.Convert(img.At(p.X, p.Y)).(color.NRGBA)
+		if want := (color.NRGBA{0xee, 0xee, 0xee, 0xff}); got != want {
+			t.Errorf("pixel %v = %v, want %v", p, got, want)
+		}
+	}
+}
+
+func TestRenderConcurrent(t *testing.T) {
+	ctx := context.Background()
+	r, err := New(ctx, nil, FamilyMapping{}, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = r.Close(ctx) }()
+
+	// Each goroutine renders a different size; a shared-buffer race would
+	// hand one goroutine another's image.
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 1; i <= 8; i++ {
+		wg.Add(1)
+		go func(size int) {
+			defer wg.Done()
+			svg := fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d"><rect width="%d" height="%d" fill="red"/></svg>`, size, size, size, size)
+			data, err := r.Render(ctx, []byte(svg), 1)
+			if err != nil {
+				errs <- err
+				return
+			}
+			img, err := png.Decode(bytes.NewReader(data))
+			if err != nil {
+				errs <- err
+				return
+			}
+			if b := img.Bounds(); b.Dx() != size || b.Dy() != size {
+				errs <- fmt.Errorf("size %d: got %dx%d image", size, b.Dx(), b.Dy())
+			}
+		}(i * 3)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+// Fractional scales must round each dimension half up to a whole pixel,
+// whatever the floating-point error in the scaled size.
+func TestRenderFractionalScale(t *testing.T) {
+	ctx := context.Background()
+	r, err := New(ctx, nil, FamilyMapping{}, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = r.Close(ctx) }()
+
+	svg := []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="100" height="50"><rect width="100" height="50" fill="#000"/></svg>`)
+	for _, tc := range []struct {
+		scale float64
+		w, h  int
+	}{
+		{1.5, 150, 75},
+		{0.75, 75, 38},
+		{1.1, 110, 55},
+	} {
+		data, err := r.Render(ctx, svg, tc.scale)
+		if err != nil {
+			t.Fatalf("Render at %v: %v", tc.scale, err)
+		}
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("png.Decode at %v: %v", tc.scale, err)
+		}
+		if b := img.Bounds(); b.Dx() != tc.w || b.Dy() != tc.h {
+			t.Errorf("scale %v: got %dx%d, want %dx%d", tc.scale, b.Dx(), b.Dy(), tc.w, tc.h)
+		}
+	}
+}
+
+func TestRenderError(t *testing.T) {
+	ctx := context.Background()
+	r, err := New(ctx, nil, FamilyMapping{}, nil)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = r.Close(ctx) }()
+
+	_, err = r.Render(ctx, []byte(`<svg xmlns="http://www.w3.org/2000/svg" width="4" height="4"><g><rect/></circle></g></svg>`), 1)
+	var re *Error
+	if !errors.As(err, &re) {
+		t.Fatalf("expected an *Error, got %T: %v", err, err)
+	}
+	if !strings.Contains(re.Message, "'circle'") {
+		t.Errorf("expected the offending element in the message, got %q", re.Message)
+	}
+}
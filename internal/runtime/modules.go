@@ -0,0 +1,64 @@
+package runtime
+
+import (
+	"fmt"
+
+	"github.com/fastschema/qjs"
+)
+
+// Modules holds one version set's vendored modules compiled to QuickJS
+// bytecode. Parsing the Vega and Vega-Lite bundles is a large part of
+// creating a Runtime; Runtimes given the same Modules in Config load the
+// bytecode instead. A Modules is immutable and safe to share.
+type Modules struct {
+	version string
+	modules []compiledModule
+}
+
+type compiledModule struct {
+	name     string
+	bytecode []byte
+}
+
+// CompileModules compiles the vendored modules of version set ver (the
+// default version if empty) to bytecode, without evaluating them.
+func CompileModules(ver string) (*Modules, error) {
+	if ver == "" {
+		def, err := readDefaultVersion()
+		if err != nil {
+			return nil, err
+		}
+		ver = def
+	}
+	sources, err := readModules(ver)
+	if err != nil {
+		return nil, err
+	}
+	compiled, err := compileModules(sources)
+	if err != nil {
+		return nil, err
+	}
+	return &Modules{version: ver, modules: compiled}, nil
+}
+
+// compileModules compiles each source as an ES module in a scratch QuickJS
+// runtime. Imports are resolved only when the bytecode is loaded, so the
+// modules need not be loaded here.
+func compileModules(sources []moduleSource) ([]compiledModule, error) {
+	rt, err := qjs.New()
+	if err != nil {
+		return nil, fmt.Errorf("aster/runtime: creating QuickJS runtime: %w", err)
+	}
+	defer rt.Close()
+
+	ctx := rt.Context()
+	compiled := make([]compiledModule, 0, len(sources))
+	for _, mod := range sources {
+		bytecode, err := ctx.Compile(mod.name, qjs.Code(mod.src), qjs.TypeModule())
+		if err != nil {
+			return nil, fmt.Errorf("aster/runtime: compiling module %s: %w", mod.name, err)
+		}
+		compiled = append(compiled, compiledModule{name: mod.name, bytecode: bytecode})
+	}
+	return compiled, nil
+}
@@ -3,11 +3,17 @@
 package runtime
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io/fs"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/fastschema/qjs"
@@ -26,22 +32,169 @@ type TextMeasurer interface {
 	MeasureText(text, cssFont string) float64
 }
 
+// FontChecker is implemented by TextMeasurers that can report, for
+// Config.StrictFonts, the named font families of a CSS font descriptor that
+// are not available and the runes of a text that no font has a glyph for.
+type FontChecker interface {
+	MissingFonts(text, cssFont string) (families []string, runes []rune)
+}
+
 // Config holds runtime configuration.
 type Config struct {
 	Loader       Loader
 	TextMeasurer TextMeasurer
 	Theme        string
 	MemoryLimit  int
+	StackSize    int // QuickJS stack limit in bytes (default: QuickJS's)
 	Timeout      time.Duration
-	Version      string // version set key, e.g. "vl6_4" (default)
-	Timezone     string // IANA timezone name or "UTC" (default: "UTC")
+	Version      string    // version set key, e.g. "vl6_4" (default)
+	Timezone     string    // IANA timezone name or "UTC" (default: "UTC")
+	LogLevel     string    // none, error, warn, info or debug (default: Vega's, warn)
+	Now          time.Time // fixed value for Date.now() and new Date(); zero uses the clock
+	// MaxTimerDepth caps chains of setTimeout callbacks that schedule
+	// further timers (default: DefaultMaxTimerDepth).
+	MaxTimerDepth int
+	// FullPrecision disables d3's 3-decimal rounding of SVG path data.
+	FullPrecision bool
+	// Modules, if set for Version, supplies the vendored modules as
+	// precompiled bytecode (see CompileModules).
+	Modules *Modules
+	// StrictWarnings makes an eval that logs any Vega or Vega-Lite warning
+	// fail with a *WarningsError.
+	StrictWarnings bool
+	// StrictFonts makes an eval that measures text in an unavailable font
+	// family, or with characters no font covers, fail with a *FontsError.
+	// It needs a TextMeasurer that implements FontChecker.
+	StrictFonts bool
+	// NonFinite makes bridge.js read the placeholder strings
+	// "__aster_NaN__", "__aster_Infinity__" and "__aster_-Infinity__" in
+	// specs as non-finite numbers, and write compiled specs the same way.
+	NonFinite bool
+	// NativeTooltips makes bridge.js give every SVG item element with a
+	// tooltip a <title> child holding the tooltip text.
+	NativeTooltips bool
+	// MaxRequests caps the distinct URIs one eval may ask the Loader to
+	// sanitize, data and images alike, such as the tiles of a map; past
+	// it, the eval fails with an error wrapping ErrLimitExceeded. Zero
+	// means unlimited.
+	MaxRequests int
+	// MaxMarks caps the scenegraph items (marks, including groups) one
+	// SVG render may produce; past it, the render fails before the SVG is
+	// serialized with an error wrapping ErrLimitExceeded. Zero means
+	// unlimited.
+	MaxMarks int
+	// DataBaseURL is prefixed to relative data URLs, as Vega's loader
+	// baseURL option does, before the Loader sees them.
+	DataBaseURL string
 }
 
+// DefaultMaxTimerDepth is the length of a setTimeout chain after which
+// further scheduling is ignored when Config.MaxTimerDepth is unset.
+const DefaultMaxTimerDepth = 100
+
 // Runtime wraps a QuickJS engine with Vega/Vega-Lite loaded.
 type Runtime struct {
-	rt      *qjs.Runtime
-	config  Config
-	crashed bool // set after a WASM panic; further calls return errors
+	rt       *qjs.Runtime
+	config   Config
+	crashed  bool  // set after a WASM panic; further calls return errors
+	loadErr  error // first loader error seen during the current eval
+	timings  Timings
+	warnings []string // warnings logged during the current eval
+	scope    *Scope   // render-scoped state for the current eval's loads
+	next     *Scope   // reserved by ReserveScope for the next eval
+
+	requests   map[string]bool // URIs sanitized during the current eval
+	requestErr error           // set once the current eval passes MaxRequests
+
+	missingFamilies []string // unavailable families measured in the current eval
+	missingRunes    []rune   // uncovered runes measured in the current eval
+	measuredFonts   []string // CSS fonts measured in the current eval, once each
+}
+
+// Scope holds state shared by the Loader calls of one eval (one render or
+// compile), such as a byte count for a per-render budget. Each eval gets a
+// fresh Scope, reachable from the Load context through ScopeFromContext.
+type Scope struct {
+	start time.Time // when the render began, for Config.Timeout
+
+	mu       sync.Mutex
+	counters map[any]int64
+	formats  map[string]string // URI → data format reported or sniffed
+}
+
+// Add adds n to the counter for key and returns the new total.
+func (s *Scope) Add(key any, n int64) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.counters == nil {
+		s.counters = make(map[any]int64)
+	}
+	s.counters[key] += n
+	return s.counters[key]
+}
+
+// SetFormat records that the data loaded from uri is in format, such as
+// "json", "csv" or "tsv".
+func (s *Scope) SetFormat(uri, format string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.formats == nil {
+		s.formats = make(map[string]string)
+	}
+	s.formats[uri] = format
+}
+
+// Format returns the format recorded for uri with SetFormat, or "".
+func (s *Scope) Format(uri string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.formats[uri]
+}
+
+// sniffFormat guesses the format of loaded data from its first bytes: JSON
+// if it opens an array or object, otherwise TSV or CSV if its first line has
+// a tab or comma. It returns "" when it cannot tell.
+func sniffFormat(data []byte) string {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	data = bytes.TrimLeft(data, " \t\r\n")
+	if len(data) == 0 {
+		return ""
+	}
+	if data[0] == '[' || data[0] == '{' {
+		return "json"
+	}
+	line, _, _ := bytes.Cut(data, []byte("\n"))
+	switch {
+	case bytes.IndexByte(line, '\t') >= 0:
+		return "tsv"
+	case bytes.IndexByte(line, ',') >= 0:
+		return "csv"
+	}
+	return ""
+}
+
+type scopeKey struct{}
+
+// ContextWithScope returns a copy of ctx carrying s, for Loader calls made
+// on an eval's behalf outside it (see Runtime.ReserveScope).
+func ContextWithScope(ctx context.Context, s *Scope) context.Context {
+	return context.WithValue(ctx, scopeKey{}, s)
+}
+
+// ScopeFromContext returns the Scope of the eval whose Loader call received
+// ctx, or nil if ctx does not come from an eval.
+func ScopeFromContext(ctx context.Context) *Scope {
+	s, _ := ctx.Value(scopeKey{}).(*Scope)
+	return s
+}
+
+// Timings holds the per-phase durations bridge.js reported during the most
+// recent eval. Phases that did not run are zero.
+type Timings struct {
+	Compile   time.Duration // Vega-Lite → Vega compilation
+	Parse     time.Duration // vega.parse into a dataflow runtime
+	Dataflow  time.Duration // running the dataflow, including data loads
+	Serialize time.Duration // scenegraph → SVG string
 }
 
 // versionIndex matches the top-level versions.json from the vendoring tool.
@@ -72,11 +225,22 @@ type manifestModule struct {
 // New creates a new Runtime, loading all vendored JS modules and registering
 // Go bridge functions.
 func New(cfg Config) (*Runtime, error) {
+	switch cfg.LogLevel {
+	case "", "none", "error", "warn", "info", "debug":
+	default:
+		return nil, fmt.Errorf("aster/runtime: unknown log level %q", cfg.LogLevel)
+	}
+
 	opts := qjs.Option{}
 	if cfg.MemoryLimit > 0 {
 		opts.MemoryLimit = cfg.MemoryLimit
 	}
+	if cfg.StackSize > 0 {
+		opts.MaxStackSize = cfg.StackSize
+	}
 	if cfg.Timeout > 0 {
+		// qjs does not enforce MaxExecutionTime yet, so evalModule also
+		// checks each eval against the timeout itself.
 		opts.MaxExecutionTime = int(cfg.Timeout / time.Millisecond)
 	}
 
@@ -147,20 +311,37 @@ func (r *Runtime) registerBridgeFunctions() error {
 
 			// Resolve synchronously — the WASM runtime is not thread-safe,
 			// so we cannot call back from a goroutine.
-			loadCtx := context.Background()
+			loadCtx := ContextWithScope(context.Background(), r.scope)
 			if r.config.Timeout > 0 {
 				var cancel context.CancelFunc
-				loadCtx, cancel = context.WithTimeout(loadCtx, r.config.Timeout)
+				loadCtx, cancel = context.WithDeadline(loadCtx, r.scope.start.Add(r.config.Timeout))
 				defer cancel()
 			}
 			data, err := r.config.Loader.Load(loadCtx, url)
 			if err != nil {
+				if errors.Is(loadCtx.Err(), context.DeadlineExceeded) {
+					err = fmt.Errorf("%w: %w", ErrTimeout, err)
+				}
+				r.recordLoadErr(err)
 				_ = this.Promise().Reject(this.Context().NewError(err))
 				return
 			}
+			if r.scope.Format(url) == "" {
+				r.scope.SetFormat(url, sniffFormat(data))
+			}
 			_ = this.Promise().Resolve(this.Context().NewString(string(data)))
 		})
 
+		// __aster_load_format(url) → sync, returns the format of the data
+		// last loaded from url: as reported by the Loader, else sniffed.
+		ctx.SetFunc("__aster_load_format", func(this *qjs.This) (*qjs.Value, error) {
+			args := this.Args()
+			if len(args) == 0 {
+				return nil, fmt.Errorf("__aster_load_format: missing url argument")
+			}
+			return this.Context().NewString(r.scope.Format(args[0].String())), nil
+		})
+
 		// __aster_sanitize(uri) → sync, returns sanitized string
 		ctx.SetFunc("__aster_sanitize", func(this *qjs.This) (*qjs.Value, error) {
 			args := this.Args()
@@ -168,16 +349,66 @@ func (r *Runtime) registerBridgeFunctions() error {
 				return nil, fmt.Errorf("__aster_sanitize: missing uri argument")
 			}
 			uri := args[0].String()
+			if err := r.countRequest(uri); err != nil {
+				r.recordLoadErr(err)
+				return nil, err
+			}
 
-			sanitizeCtx := context.Background()
+			sanitizeCtx := ContextWithScope(context.Background(), r.scope)
 			sanitized, err := r.config.Loader.Sanitize(sanitizeCtx, uri)
 			if err != nil {
+				r.recordLoadErr(err)
 				return nil, err
 			}
 			return this.Context().NewString(sanitized), nil
 		})
 	}
 
+	// __aster_check_marks(count) → sync, throws past MaxMarks
+	if r.config.MaxMarks > 0 {
+		ctx.SetFunc("__aster_check_marks", func(this *qjs.This) (*qjs.Value, error) {
+			args := this.Args()
+			if len(args) < 1 {
+				return nil, fmt.Errorf("__aster_check_marks: missing count argument")
+			}
+			if err := r.checkMarks(args[0].Int64()); err != nil {
+				r.recordLoadErr(err)
+				return nil, err
+			}
+			return this.Context().NewUndefined(), nil
+		})
+	}
+
+	// __aster_metric(phase, ms) → sync, records a phase duration
+	ctx.SetFunc("__aster_metric", func(this *qjs.This) (*qjs.Value, error) {
+		args := this.Args()
+		if len(args) < 2 {
+			return nil, fmt.Errorf("__aster_metric: expected 2 arguments")
+		}
+		d := time.Duration(args[1].Float64() * float64(time.Millisecond))
+		switch args[0].String() {
+		case "compile":
+			r.timings.Compile += d
+		case "parse":
+			r.timings.Parse += d
+		case "dataflow":
+			r.timings.Dataflow += d
+		case "serialize":
+			r.timings.Serialize += d
+		}
+		return this.Context().NewUndefined(), nil
+	})
+
+	// __aster_warn(message) → sync, records a Vega/Vega-Lite warning
+	ctx.SetFunc("__aster_warn", func(this *qjs.This) (*qjs.Value, error) {
+		args := this.Args()
+		if len(args) < 1 {
+			return nil, fmt.Errorf("__aster_warn: missing message argument")
+		}
+		r.warnings = append(r.warnings, args[0].String())
+		return this.Context().NewUndefined(), nil
+	})
+
 	// __aster_measure_text(text, cssFont) → sync, returns number
 	if r.config.TextMeasurer != nil {
 		ctx.SetFunc("__aster_measure_text", func(this *qjs.This) (*qjs.Value, error) {
@@ -189,6 +420,12 @@ func (r *Runtime) registerBridgeFunctions() error {
 			cssFont := args[1].String()
 
 			width := r.config.TextMeasurer.MeasureText(text, cssFont)
+			if !slices.Contains(r.measuredFonts, cssFont) {
+				r.measuredFonts = append(r.measuredFonts, cssFont)
+			}
+			if fc, ok := r.config.TextMeasurer.(FontChecker); ok && r.config.StrictFonts {
+				r.recordMissingFonts(fc.MissingFonts(text, cssFont))
+			}
 			return this.Context().NewFloat64(width), nil
 		})
 	}
@@ -241,18 +478,121 @@ func (r *Runtime) installPolyfills() error {
 			};
 		}
 
+		// Cap timer chains. A callback that schedules another timer (as
+		// d3-timer does while animating) would otherwise keep the render
+		// alive forever under QuickJS's event loop, or overflow the stack
+		// under the inline stub above. Each callback runs one level deeper
+		// than the callback that scheduled it; past the limit, scheduling
+		// is a no-op.
+		(function() {
+			const maxDepth = %d;
+			const baseSetTimeout = globalThis.setTimeout;
+			let depth = 0;
+			globalThis.setTimeout = function(fn, delay, ...args) {
+				const next = depth + 1;
+				if (next > maxDepth) return 0;
+				return baseSetTimeout(function() {
+					const prev = depth;
+					depth = next;
+					try { return fn(...args); } finally { depth = prev; }
+				}, delay);
+			};
+		})();
+
 		// performance.now — some modules may reference this.
+		// Keep the real clock: Date.now may be pinned below.
 		if (typeof globalThis.performance === 'undefined') {
-			globalThis.performance = { now: function() { return Date.now(); } };
+			const _now = Date.now;
+			globalThis.performance = { now: function() { return _now(); } };
 		}
 	`
 
+	maxTimerDepth := r.config.MaxTimerDepth
+	if maxTimerDepth <= 0 {
+		maxTimerDepth = DefaultMaxTimerDepth
+	}
+	polyfills = fmt.Sprintf(polyfills, maxTimerDepth)
+
 	val, err := ctx.Eval("__aster_polyfills__.js", qjs.Code(polyfills))
 	if err != nil {
 		return fmt.Errorf("aster/runtime: installing polyfills: %w", err)
 	}
 	val.Free()
 
+	// Pin the clock so specs using now() render deterministically. Date
+	// called without arguments (directly or via new) sees the fixed time;
+	// every other use behaves like the real Date.
+	if !r.config.Now.IsZero() {
+		fixedNow := fmt.Sprintf(`
+			(function() {
+				const fixed = %d;
+				const RealDate = Date;
+				function FixedDate(...args) {
+					if (!new.target) return new RealDate(fixed).toString();
+					return args.length === 0 ? new RealDate(fixed) : new RealDate(...args);
+				}
+				FixedDate.prototype = RealDate.prototype;
+				FixedDate.now = function() { return fixed; };
+				FixedDate.parse = RealDate.parse;
+				FixedDate.UTC = RealDate.UTC;
+				globalThis.Date = FixedDate;
+			})();
+		`, r.config.Now.UnixMilli())
+		val, err := ctx.Eval("__aster_now__.js", qjs.Code(fixedNow))
+		if err != nil {
+			return fmt.Errorf("aster/runtime: installing fixed clock: %w", err)
+		}
+		val.Free()
+	}
+
+	// __aster_log_level tells bridge.js which Vega log level to apply to
+	// each view and Vega-Lite compilation. Unset keeps Vega's default.
+	if r.config.LogLevel != "" {
+		level := fmt.Sprintf("globalThis.__aster_log_level = %q;", r.config.LogLevel)
+		val, err := ctx.Eval("__aster_log_level__.js", qjs.Code(level))
+		if err != nil {
+			return fmt.Errorf("aster/runtime: setting log level: %w", err)
+		}
+		val.Free()
+	}
+
+	// __aster_full_precision tells bridge.js to stop d3 rounding path data.
+	if r.config.FullPrecision {
+		val, err := ctx.Eval("__aster_full_precision__.js", qjs.Code("globalThis.__aster_full_precision = true;"))
+		if err != nil {
+			return fmt.Errorf("aster/runtime: setting full precision: %w", err)
+		}
+		val.Free()
+	}
+
+	// __aster_non_finite tells bridge.js to revive non-finite placeholders.
+	if r.config.NonFinite {
+		val, err := ctx.Eval("__aster_non_finite__.js", qjs.Code("globalThis.__aster_non_finite = true;"))
+		if err != nil {
+			return fmt.Errorf("aster/runtime: setting non-finite numbers: %w", err)
+		}
+		val.Free()
+	}
+
+	// __aster_native_tooltips tells bridge.js to write tooltips as <title>s.
+	if r.config.NativeTooltips {
+		val, err := ctx.Eval("__aster_native_tooltips__.js", qjs.Code("globalThis.__aster_native_tooltips = true;"))
+		if err != nil {
+			return fmt.Errorf("aster/runtime: setting native tooltips: %w", err)
+		}
+		val.Free()
+	}
+
+	// __aster_base_url gives bridge.js the base for relative data URLs.
+	if r.config.DataBaseURL != "" {
+		base := fmt.Sprintf("globalThis.__aster_base_url = %s;", strconv.Quote(r.config.DataBaseURL))
+		val, err := ctx.Eval("__aster_base_url__.js", qjs.Code(base))
+		if err != nil {
+			return fmt.Errorf("aster/runtime: setting data base URL: %w", err)
+		}
+		val.Free()
+	}
+
 	// Force UTC timezone by redirecting local Date methods to UTC equivalents.
 	// QuickJS in WASM has no timezone configuration, so we polyfill it.
 	tz := r.config.Timezone
@@ -288,6 +628,18 @@ func (r *Runtime) installPolyfills() error {
 	return nil
 }
 
+// Version returns the key of the version set the runtime loaded, such as
+// "vl6_4", with the default resolved.
+func (r *Runtime) Version() string {
+	return r.config.Version
+}
+
+// DefaultVersion returns the version set key used when Config.Version is
+// empty.
+func DefaultVersion() (string, error) {
+	return readDefaultVersion()
+}
+
 // readDefaultVersion reads the default version key from versions.json.
 func readDefaultVersion() (string, error) {
 	idx, err := readVersionIndex()
@@ -323,73 +675,192 @@ func AvailableVersions() (map[string]struct{ VegaVersion, VegaLiteVersion string
 	return result, nil
 }
 
-// loadModules reads the manifest and loads all vendored JS modules in order.
-func (r *Runtime) loadModules() error {
+// moduleSource is the source of one vendored JS module.
+type moduleSource struct {
+	name string
+	src  string
+}
+
+// readModules reads the manifest of version set ver and returns its
+// modules' sources in load (topological) order.
+func readModules(ver string) ([]moduleSource, error) {
 	// Read manifest from the versioned subdirectory.
-	ver := r.config.Version
 	manifestPath := "modules/" + ver + "/manifest.json"
 	manifestData, err := fs.ReadFile(asterjs.Modules, manifestPath)
 	if err != nil {
-		return fmt.Errorf("aster/runtime: reading manifest for %s: %w", ver, err)
+		return nil, fmt.Errorf("aster/runtime: reading manifest for %s: %w", ver, err)
 	}
 
 	var m manifest
 	if err := json.Unmarshal(manifestData, &m); err != nil {
-		return fmt.Errorf("aster/runtime: parsing manifest: %w", err)
+		return nil, fmt.Errorf("aster/runtime: parsing manifest: %w", err)
 	}
 
-	ctx := r.rt.Context()
-
-	// Load each module in topological order.
+	sources := make([]moduleSource, 0, len(m.Modules))
 	for _, mod := range m.Modules {
 		src, err := fs.ReadFile(asterjs.Modules, "modules/"+ver+"/"+mod.Filename)
 		if err != nil {
-			return fmt.Errorf("aster/runtime: reading module %s: %w", mod.Name, err)
+			return nil, fmt.Errorf("aster/runtime: reading module %s: %w", mod.Name, err)
 		}
+		sources = append(sources, moduleSource{name: mod.Name, src: string(src)})
+	}
+	return sources, nil
+}
 
-		val, err := ctx.Load(mod.Name, qjs.Code(string(src)))
+// loadModules loads all vendored JS modules in order, from
+// Config.Modules bytecode when it holds the configured version.
+func (r *Runtime) loadModules() error {
+	ctx := r.rt.Context()
+
+	if mods := r.config.Modules; mods != nil && mods.version == r.config.Version {
+		for _, mod := range mods.modules {
+			val, err := ctx.Load(mod.name, qjs.Bytecode(mod.bytecode))
+			if err != nil {
+				return newModuleError(mod.name, "", err)
+			}
+			val.Free()
+		}
+	} else {
+		sources, err := readModules(r.config.Version)
 		if err != nil {
-			return fmt.Errorf("aster/runtime: loading module %s: %w", mod.Name, err)
+			return err
+		}
+		for _, mod := range sources {
+			val, err := ctx.Load(mod.name, qjs.Code(mod.src))
+			if err != nil {
+				return newModuleError(mod.name, mod.src, err)
+			}
+			val.Free()
 		}
-		val.Free()
 	}
 
 	// Load the bridge module.
 	val, err := ctx.Load("bridge", qjs.Code(asterjs.BridgeJS))
 	if err != nil {
-		return fmt.Errorf("aster/runtime: loading bridge module: %w", err)
+		return newModuleError("bridge", asterjs.BridgeJS, err)
 	}
 	val.Free()
 
 	return nil
 }
 
-// VegaToSVG renders a Vega spec to SVG.
-func (r *Runtime) VegaToSVG(specJSON string) (string, error) {
-	theme := "undefined"
-	if r.config.Theme != "" {
-		theme = "`" + r.config.Theme + "`"
+// moduleError reports a vendored module that failed to load. QuickJS
+// errors carry the exception message followed by a stack; moduleError keeps
+// the message, the position in the module and an excerpt of the offending
+// source line, which is what points at a bad vendoring rewrite.
+type moduleError struct {
+	module  string
+	message string // first line of the QuickJS error
+	line    int    // 1-based; 0 if QuickJS reported no position
+	col     int
+	excerpt string
+	err     error
+}
+
+// moduleErrorPos matches a QuickJS stack frame such as "at vega:3:17".
+var moduleErrorPos = regexp.MustCompile(`\bat ([^\s:]+):(\d+):(\d+)`)
+
+// excerptWidth is the number of source bytes shown on each side of the
+// error column; vendored modules are minified into very long lines.
+const excerptWidth = 40
+
+func newModuleError(module, src string, err error) error {
+	msg, _, _ := strings.Cut(strings.TrimSpace(err.Error()), "\n")
+	e := &moduleError{module: module, message: msg, err: err}
+
+	for _, m := range moduleErrorPos.FindAllStringSubmatch(err.Error(), -1) {
+		if m[1] != module {
+			continue
+		}
+		e.line, _ = strconv.Atoi(m[2])
+		e.col, _ = strconv.Atoi(m[3])
+		lines := strings.Split(src, "\n")
+		if e.line >= 1 && e.line <= len(lines) {
+			e.excerpt = sourceExcerpt(lines[e.line-1], e.col)
+		}
+		break
+	}
+	return e
+}
+
+// sourceExcerpt returns up to excerptWidth bytes either side of the 1-based
+// column col in line, marking truncated ends with "...".
+func sourceExcerpt(line string, col int) string {
+	line = strings.TrimRight(line, "\r")
+	start := max(0, col-1-excerptWidth)
+	end := min(len(line), col-1+excerptWidth)
+	if start >= end {
+		return strings.TrimSpace(line)
+	}
+	excerpt := strings.TrimSpace(line[start:end])
+	if start > 0 {
+		excerpt = "..." + excerpt
+	}
+	if end < len(line) {
+		excerpt += "..."
+	}
+	return excerpt
+}
+
+func (e *moduleError) Error() string {
+	if e.line == 0 {
+		return fmt.Sprintf("aster/runtime: loading module %s: %s", e.module, e.message)
 	}
+	return fmt.Sprintf("aster/runtime: loading module %s: %s (at %s:%d:%d: %s)",
+		e.module, e.message, e.module, e.line, e.col, e.excerpt)
+}
+
+func (e *moduleError) Unwrap() error { return e.err }
 
+// themeArg returns the Config.Theme argument of a bridge.js export: the
+// theme JSON as a template literal, or undefined when there is none. The
+// theme may come from a user's file, so it is escaped like a spec.
+func (r *Runtime) themeArg() string {
+	if r.config.Theme == "" {
+		return "undefined"
+	}
+	return "`" + escapeBackticks(r.config.Theme) + "`"
+}
+
+// VegaToSVG renders a Vega spec to SVG.
+func (r *Runtime) VegaToSVG(specJSON string) (string, error) {
 	script := fmt.Sprintf(`
 		import { vegaToSvg } from 'bridge';
 		export default await vegaToSvg(%s, %s);
-	`, "`"+escapeBackticks(specJSON)+"`", theme)
+	`, "`"+escapeBackticks(specJSON)+"`", r.themeArg())
 
 	return r.evalModule(script)
 }
 
 // VegaLiteToSVG renders a Vega-Lite spec to SVG.
 func (r *Runtime) VegaLiteToSVG(specJSON string) (string, error) {
-	theme := "undefined"
-	if r.config.Theme != "" {
-		theme = "`" + r.config.Theme + "`"
-	}
-
 	script := fmt.Sprintf(`
 		import { vegaLiteToSvg } from 'bridge';
 		export default await vegaLiteToSvg(%s, %s);
-	`, "`"+escapeBackticks(specJSON)+"`", theme)
+	`, "`"+escapeBackticks(specJSON)+"`", r.themeArg())
+
+	return r.evalModule(script)
+}
+
+// VegaLiteToLegendSVG renders only the legends of a Vega-Lite spec, returning
+// JSON of the form {"svg", "bounds"} where bounds holds the legends' x1, y1,
+// x2 and y2 in the SVG's user coordinates.
+func (r *Runtime) VegaLiteToLegendSVG(specJSON string) (string, error) {
+	script := fmt.Sprintf(`
+		import { vegaLiteToLegendSvg } from 'bridge';
+		export default await vegaLiteToLegendSvg(%s, %s);
+	`, "`"+escapeBackticks(specJSON)+"`", r.themeArg())
+
+	return r.evalModule(script)
+}
+
+// ContentBounds runs a Vega spec's dataflow and returns the bounds of its
+// scenegraph as a JSON array [x1, y1, x2, y2] in the SVG's user coordinates.
+func (r *Runtime) ContentBounds(specJSON string) (string, error) {
+	script := fmt.Sprintf(`
+		import { vegaContentBounds } from 'bridge';
+		export default await vegaContentBounds(%s, %s);
+	`, "`"+escapeBackticks(specJSON)+"`", r.themeArg())
 
 	return r.evalModule(script)
 }
@@ -404,8 +875,196 @@ func (r *Runtime) VegaLiteToVega(specJSON string) (string, error) {
 	return r.evalModule(script)
 }
 
+// VegaLiteToData compiles a Vega-Lite spec, runs its dataflow, and returns the
+// primary dataset as JSON of the form {"name", "columns", "values"}.
+func (r *Runtime) VegaLiteToData(specJSON string) (string, error) {
+	script := fmt.Sprintf(`
+		import { vegaLiteToData } from 'bridge';
+		export default await vegaLiteToData(%s, %s);
+	`, "`"+escapeBackticks(specJSON)+"`", r.themeArg())
+
+	return r.evalModule(script)
+}
+
+// Signals runs a Vega spec's dataflow and returns its signals as a JSON
+// object of name → value.
+func (r *Runtime) Signals(specJSON string) (string, error) {
+	script := fmt.Sprintf(`
+		import { vegaSignals } from 'bridge';
+		export default await vegaSignals(%s, %s);
+	`, "`"+escapeBackticks(specJSON)+"`", r.themeArg())
+
+	return r.evalModule(script)
+}
+
+// State runs a Vega spec's dataflow and returns the values of the signals
+// and datasets named in namesJSON, a JSON object {"signals": [...], "data":
+// [...]}, as JSON of the same shape with name → value objects.
+func (r *Runtime) State(specJSON, namesJSON string) (string, error) {
+	script := fmt.Sprintf(`
+		import { vegaState } from 'bridge';
+		export default await vegaState(%s, %s, %s);
+	`, "`"+escapeBackticks(specJSON)+"`", "`"+escapeBackticks(namesJSON)+"`", r.themeArg())
+
+	return r.evalModule(script)
+}
+
+// ReserveScope returns the Scope the next eval will use, so that loads made
+// for it beforehand, such as prefetched data, share its per-render state.
+// Config.Timeout runs from the first ReserveScope, so the time those loads
+// take counts against the eval's deadline.
+func (r *Runtime) ReserveScope() *Scope {
+	if r.next == nil {
+		r.next = &Scope{start: time.Now()}
+	}
+	return r.next
+}
+
+// Timings returns the phase durations recorded by the most recent call.
+func (r *Runtime) Timings() Timings {
+	return r.timings
+}
+
+// Warnings returns the Vega and Vega-Lite warnings logged by the most recent
+// call, in order, whatever the log level.
+func (r *Runtime) Warnings() []string {
+	return r.warnings
+}
+
+// SetTextMeasurement switches bridge.js between measuring text with the
+// TextMeasurer and Vega's own width estimation, for the evals that follow
+// until it is switched back. Measurement is on by default when a
+// TextMeasurer is configured.
+func (r *Runtime) SetTextMeasurement(enabled bool) error {
+	code := fmt.Sprintf("globalThis.__aster_skip_measure = %t;", !enabled)
+	val, err := r.rt.Context().Eval("__aster_skip_measure__.js", qjs.Code(code))
+	if err != nil {
+		return fmt.Errorf("aster/runtime: setting text measurement: %w", err)
+	}
+	val.Free()
+	return nil
+}
+
+// MeasuredFonts returns the distinct CSS font strings, such as
+// "normal bold 11px sans-serif", the most recent call passed to the
+// TextMeasurer, in the order first measured.
+func (r *Runtime) MeasuredFonts() []string {
+	return r.measuredFonts
+}
+
+// WarningsError is returned in place of a result by an eval that logged
+// warnings when Config.StrictWarnings is set.
+type WarningsError struct {
+	Warnings []string
+}
+
+func (e *WarningsError) Error() string {
+	return fmt.Sprintf("aster/runtime: %d warning(s): %s", len(e.Warnings), strings.Join(e.Warnings, "; "))
+}
+
+// FontsError is returned in place of a result by an eval that measured text
+// it could not render faithfully when Config.StrictFonts is set: Families
+// lists the requested font families that are not available, and Glyphs the
+// characters no font has a glyph for, each in the order first measured.
+type FontsError struct {
+	Families []string
+	Glyphs   string
+}
+
+func (e *FontsError) Error() string {
+	var parts []string
+	if len(e.Families) > 0 {
+		quoted := make([]string, len(e.Families))
+		for i, f := range e.Families {
+			quoted[i] = strconv.Quote(f)
+		}
+		parts = append(parts, "font families not available: "+strings.Join(quoted, ", "))
+	}
+	if e.Glyphs != "" {
+		parts = append(parts, "no font has glyphs for "+strconv.Quote(e.Glyphs))
+	}
+	return "aster/runtime: " + strings.Join(parts, "; ")
+}
+
+// recordMissingFonts adds a measurement's missing families and runes to the
+// current eval's, once each.
+func (r *Runtime) recordMissingFonts(families []string, runes []rune) {
+	for _, f := range families {
+		if !slices.Contains(r.missingFamilies, f) {
+			r.missingFamilies = append(r.missingFamilies, f)
+		}
+	}
+	for _, c := range runes {
+		if !slices.Contains(r.missingRunes, c) {
+			r.missingRunes = append(r.missingRunes, c)
+		}
+	}
+}
+
+// ErrTimeout is wrapped by the error of an eval that ran past
+// Config.Timeout, whether QuickJS interrupted the script or a loader call
+// outlived the render deadline.
+var ErrTimeout = errors.New("aster/runtime: render timed out")
+
+// ErrLimitExceeded is wrapped by the errors of evals that trip a
+// configured limit other than the timeout and memory limit, such as
+// Config.MaxRequests. The aster package reports its own guards with it too.
+var ErrLimitExceeded = errors.New("aster: limit exceeded")
+
+// ErrMemoryLimit is wrapped by the error of an eval that ran out of memory
+// under Config.MemoryLimit.
+var ErrMemoryLimit = errors.New("aster/runtime: memory limit exceeded")
+
 var errRuntimeCrashed = errors.New("aster/runtime: WASM runtime has crashed; create a new Converter")
 
+// evalError is a JS exception that was triggered by a Go loader failure.
+// It keeps the JS message but also unwraps to the loader's error, so callers
+// can use errors.As to recover typed errors such as aster.LoadError.
+type evalError struct {
+	err     error
+	loadErr error
+}
+
+func (e *evalError) Error() string   { return e.err.Error() }
+func (e *evalError) Unwrap() []error { return []error{e.err, e.loadErr} }
+
+// countRequest enforces Config.MaxRequests for a URI about to be sanitized.
+// Vega ignores failed image loads, so the first error is also kept to fail
+// the eval once it completes.
+func (r *Runtime) countRequest(uri string) error {
+	if r.config.MaxRequests <= 0 || r.requests[uri] {
+		return nil
+	}
+	if len(r.requests) >= r.config.MaxRequests {
+		err := fmt.Errorf("%w: render requested more than %d distinct resources (WithMaxRequests)", ErrLimitExceeded, r.config.MaxRequests)
+		if r.requestErr == nil {
+			r.requestErr = err
+		}
+		return err
+	}
+	if r.requests == nil {
+		r.requests = make(map[string]bool)
+	}
+	r.requests[uri] = true
+	return nil
+}
+
+// checkMarks enforces Config.MaxMarks for a scenegraph of count items.
+func (r *Runtime) checkMarks(count int64) error {
+	if r.config.MaxMarks <= 0 || count <= int64(r.config.MaxMarks) {
+		return nil
+	}
+	return fmt.Errorf("%w: render has %d marks, over the %d maximum (WithMaxMarks); sample or aggregate the data to draw fewer",
+		ErrLimitExceeded, count, r.config.MaxMarks)
+}
+
+// recordLoadErr remembers the first loader error of the current eval.
+func (r *Runtime) recordLoadErr(err error) {
+	if r.loadErr == nil {
+		r.loadErr = err
+	}
+}
+
 // evalModule evaluates an inline ES module and returns its default export as a string.
 // It recovers from panics in the WASM runtime and converts them to errors.
 func (r *Runtime) evalModule(script string) (result string, err error) {
@@ -420,25 +1079,67 @@ func (r *Runtime) evalModule(script string) (result string, err error) {
 		}
 	}()
 
+	r.loadErr = nil
+	r.requests = nil
+	r.requestErr = nil
+	r.missingFamilies = nil
+	r.missingRunes = nil
+	r.measuredFonts = nil
+	r.timings = Timings{}
+	r.warnings = nil
+	r.scope = r.next
+	r.next = nil
+	if r.scope == nil {
+		r.scope = &Scope{start: time.Now()}
+	}
 	ctx := r.rt.Context()
 	val, err := ctx.Eval("__aster_eval__.js", qjs.Code(script), qjs.TypeModule())
+	if elapsed := time.Since(r.scope.start); r.config.Timeout > 0 && elapsed > r.config.Timeout {
+		// QuickJS cannot interrupt a running script, so a CPU-bound eval
+		// runs to completion and is failed here, whatever its outcome.
+		if err == nil {
+			val.Free()
+			err = errors.New("eval completed after the deadline")
+		} else if r.loadErr != nil {
+			err = &evalError{err: err, loadErr: r.loadErr}
+		}
+		if errors.Is(err, ErrTimeout) {
+			return "", fmt.Errorf("aster/runtime: eval: %w", err)
+		}
+		return "", fmt.Errorf("%w: eval took %v, over the %v limit: %w", ErrTimeout, elapsed.Round(time.Millisecond), r.config.Timeout, err)
+	}
 	if err != nil {
+		if r.config.MemoryLimit > 0 && strings.Contains(err.Error(), "out of memory") {
+			// QuickJS throws "InternalError: out of memory" when an
+			// allocation would pass the limit; the runtime stays usable.
+			return "", fmt.Errorf("%w: %w", ErrMemoryLimit, err)
+		}
+		if r.loadErr != nil {
+			err = &evalError{err: err, loadErr: r.loadErr}
+		}
 		return "", fmt.Errorf("aster/runtime: eval: %w", err)
 	}
 	defer val.Free()
 
+	if r.requestErr != nil {
+		return "", r.requestErr
+	}
+
+	if r.config.StrictWarnings && len(r.warnings) > 0 {
+		return "", &WarningsError{Warnings: r.warnings}
+	}
+	if len(r.missingFamilies) > 0 || len(r.missingRunes) > 0 {
+		return "", &FontsError{Families: r.missingFamilies, Glyphs: string(r.missingRunes)}
+	}
 	return val.String(), nil
 }
 
-// escapeBackticks escapes backtick characters in a string for use inside
-// JS template literals.
+// escapeBackticks escapes a string for use inside a JS template literal:
+// backticks and backslashes, and '$' so that "${" starts no substitution.
 func escapeBackticks(s string) string {
 	result := make([]byte, 0, len(s))
 	for i := 0; i < len(s); i++ {
-		if s[i] == '`' {
-			result = append(result, '\\')
-		}
-		if s[i] == '\\' {
+		if s[i] == '`' || s[i] == '\\' || s[i] == '$' {
 			result = append(result, '\\')
 		}
 		result = append(result, s[i])
@@ -0,0 +1,436 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fastschema/qjs"
+)
+
+func TestModuleErrorDetail(t *testing.T) {
+	rt, err := qjs.New(qjs.Option{})
+	if err != nil {
+		t.Fatalf("qjs.New: %v", err)
+	}
+	defer rt.Close()
+
+	src := "export const a = 1;\nexport function f() {\n  return a +;\n}\n"
+	_, loadErr := rt.Context().Load("broken", qjs.Code(src))
+	if loadErr == nil {
+		t.Fatal("expected a syntax error")
+	}
+
+	err = newModuleError("broken", src, loadErr)
+	msg := err.Error()
+	for _, want := range []string{"loading module broken: SyntaxError", "broken:3:", "return a +;"} {
+		if !strings.Contains(msg, want) {
+			t.Errorf("error %q does not contain %q", msg, want)
+		}
+	}
+	if strings.Contains(msg, "\n") {
+		t.Errorf("error should be a single line, got %q", msg)
+	}
+}
+
+func TestSourceExcerpt(t *testing.T) {
+	line := strings.Repeat("a", 100) + "BAD" + strings.Repeat("b", 100)
+	got := sourceExcerpt(line, 101)
+	if !strings.HasPrefix(got, "...") || !strings.HasSuffix(got, "...") || !strings.Contains(got, "BAD") {
+		t.Errorf("unexpected excerpt %q", got)
+	}
+	if got := sourceExcerpt("  short line ", 3); got != "short line" {
+		t.Errorf("short line excerpt = %q", got)
+	}
+}
+
+func TestSetTimeoutDepthGuard(t *testing.T) {
+	rt, err := qjs.New(qjs.Option{})
+	if err != nil {
+		t.Fatalf("qjs.New: %v", err)
+	}
+	defer rt.Close()
+
+	r := &Runtime{rt: rt, config: Config{MaxTimerDepth: 10}}
+	if err := r.installPolyfills(); err != nil {
+		t.Fatalf("installPolyfills: %v", err)
+	}
+
+	// A self-rescheduling timer: one direct call plus a chain of ten
+	// callbacks. Without the guard this never finishes.
+	val, err := rt.Context().Eval("timer.js", qjs.Code(`
+		let n = 0;
+		function tick() { n++; setTimeout(tick, 0); }
+		tick();
+		await new Promise((resolve) => setTimeout(resolve, 20));
+		export default n;
+	`), qjs.TypeModule())
+	if err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	defer val.Free()
+	if got := val.Int32(); got != 11 {
+		t.Errorf("expected 11 ticks, got %d", got)
+	}
+}
+
+func TestStrictWarnings(t *testing.T) {
+	rt, err := qjs.New(qjs.Option{})
+	if err != nil {
+		t.Fatalf("qjs.New: %v", err)
+	}
+	defer rt.Close()
+
+	r := &Runtime{rt: rt, config: Config{StrictWarnings: true}}
+	if err := r.registerBridgeFunctions(); err != nil {
+		t.Fatalf("registerBridgeFunctions: %v", err)
+	}
+
+	_, err = r.evalModule("__aster_warn('first'); __aster_warn('second'); export default 'ok';")
+	var warnErr *WarningsError
+	if !errors.As(err, &warnErr) || strings.Join(warnErr.Warnings, ",") != "first,second" {
+		t.Fatalf("expected a WarningsError with both warnings, got %v", err)
+	}
+
+	// Warnings are per eval: a clean eval succeeds and reports none.
+	got, err := r.evalModule("export default 'ok';")
+	if err != nil || got != "ok" {
+		t.Fatalf("evalModule = %q, %v", got, err)
+	}
+	if w := r.Warnings(); w != nil {
+		t.Errorf("expected no warnings, got %q", w)
+	}
+}
+
+// checkingMeasurer reports every family named "Missing" and every rune "?"
+// as unavailable.
+type checkingMeasurer struct{}
+
+func (checkingMeasurer) MeasureText(text, _ string) float64 { return float64(len(text)) }
+
+func (checkingMeasurer) MissingFonts(text, cssFont string) ([]string, []rune) {
+	var families []string
+	if strings.Contains(cssFont, "Missing") {
+		families = []string{"Missing"}
+	}
+	var runes []rune
+	if strings.Contains(text, "?") {
+		runes = []rune{'?'}
+	}
+	return families, runes
+}
+
+func TestStrictFonts(t *testing.T) {
+	rt, err := qjs.New(qjs.Option{})
+	if err != nil {
+		t.Fatalf("qjs.New: %v", err)
+	}
+	defer rt.Close()
+
+	r := &Runtime{rt: rt, config: Config{TextMeasurer: checkingMeasurer{}, StrictFonts: true}}
+	if err := r.registerBridgeFunctions(); err != nil {
+		t.Fatalf("registerBridgeFunctions: %v", err)
+	}
+
+	_, err = r.evalModule(`
+		__aster_measure_text("a?", "12px Missing");
+		__aster_measure_text("b?", "12px Missing, sans-serif");
+		export default 'ok';
+	`)
+	var fontsErr *FontsError
+	if !errors.As(err, &fontsErr) || strings.Join(fontsErr.Families, ",") != "Missing" || fontsErr.Glyphs != "?" {
+		t.Fatalf("expected a FontsError for Missing and ?, got %v", err)
+	}
+
+	// Missing fonts are per eval: a clean eval succeeds.
+	got, err := r.evalModule(`export default String(__aster_measure_text("abc", "12px sans-serif"));`)
+	if err != nil || got != "3" {
+		t.Fatalf("evalModule = %q, %v", got, err)
+	}
+}
+
+// scopeLoader records the Scope each Load call sees.
+type scopeLoader struct {
+	scopes []*Scope
+}
+
+func (l *scopeLoader) Load(ctx context.Context, _ string) ([]byte, error) {
+	l.scopes = append(l.scopes, ScopeFromContext(ctx))
+	return []byte("x"), nil
+}
+
+func (l *scopeLoader) Sanitize(_ context.Context, uri string) (string, error) {
+	return uri, nil
+}
+
+func TestLoadScopePerEval(t *testing.T) {
+	rt, err := qjs.New(qjs.Option{})
+	if err != nil {
+		t.Fatalf("qjs.New: %v", err)
+	}
+	defer rt.Close()
+
+	loader := &scopeLoader{}
+	r := &Runtime{rt: rt, config: Config{Loader: loader}}
+	if err := r.registerBridgeFunctions(); err != nil {
+		t.Fatalf("registerBridgeFunctions: %v", err)
+	}
+
+	twoLoads := "await __aster_load('a'); await __aster_load('b'); export default 'ok';"
+	for i := range 2 {
+		if _, err := r.evalModule(twoLoads); err != nil {
+			t.Fatalf("eval %d: %v", i, err)
+		}
+	}
+	s := loader.scopes
+	if len(s) != 4 || s[0] == nil || s[0] != s[1] || s[2] != s[3] || s[1] == s[2] {
+		t.Errorf("expected one shared scope per eval, got %v", s)
+	}
+	if ScopeFromContext(context.Background()) != nil {
+		t.Error("a context outside an eval should have no scope")
+	}
+}
+
+func TestReserveScope(t *testing.T) {
+	rt, err := qjs.New(qjs.Option{})
+	if err != nil {
+		t.Fatalf("qjs.New: %v", err)
+	}
+	defer rt.Close()
+
+	loader := &scopeLoader{}
+	r := &Runtime{rt: rt, config: Config{Loader: loader}}
+	if err := r.registerBridgeFunctions(); err != nil {
+		t.Fatalf("registerBridgeFunctions: %v", err)
+	}
+
+	reserved := r.ReserveScope()
+	if r.ReserveScope() != reserved {
+		t.Error("reserving twice before an eval should return the same scope")
+	}
+	if ScopeFromContext(ContextWithScope(context.Background(), reserved)) != reserved {
+		t.Error("ContextWithScope should carry the scope")
+	}
+
+	oneLoad := "await __aster_load('a'); export default 'ok';"
+	for i := range 2 {
+		if _, err := r.evalModule(oneLoad); err != nil {
+			t.Fatalf("eval %d: %v", i, err)
+		}
+	}
+	s := loader.scopes
+	if len(s) != 2 || s[0] != reserved || s[1] == reserved {
+		t.Errorf("expected only the next eval to use the reserved scope, got %v", s)
+	}
+}
+
+// Time spent on a reserved scope's loads before the eval, such as a
+// prefetch, counts against the eval's timeout.
+func TestReserveScopeSharesTimeout(t *testing.T) {
+	rt, err := qjs.New(qjs.Option{})
+	if err != nil {
+		t.Fatalf("qjs.New: %v", err)
+	}
+	defer rt.Close()
+
+	r := &Runtime{rt: rt, config: Config{Timeout: 50 * time.Millisecond}}
+	r.ReserveScope()
+	time.Sleep(60 * time.Millisecond)
+	if _, err := r.evalModule("export default 'ok';"); !errors.Is(err, ErrTimeout) {
+		t.Errorf("eval after its reserved scope's budget was spent: expected ErrTimeout, got %v", err)
+	}
+	if got, err := r.evalModule("export default 'ok';"); err != nil || got != "ok" {
+		t.Errorf("eval with a fresh scope = %q, %v", got, err)
+	}
+}
+
+// QuickJS cannot interrupt a script, so the timeout is judged from the
+// eval's duration, not from the wording of its error.
+func TestEvalTimeout(t *testing.T) {
+	rt, err := qjs.New(qjs.Option{})
+	if err != nil {
+		t.Fatalf("qjs.New: %v", err)
+	}
+	defer rt.Close()
+
+	r := &Runtime{rt: rt, config: Config{Timeout: time.Millisecond}}
+	busy := "let n = 0; for (let i = 0; i < 1e6; i++) n += i; export default String(n);"
+	if _, err := r.evalModule(busy); !errors.Is(err, ErrTimeout) {
+		t.Errorf("CPU-bound eval: expected ErrTimeout, got %v", err)
+	}
+	_, err = r.evalModule("for (let i = 0; i < 1e6; i++) {} throw new Error('boom');")
+	if !errors.Is(err, ErrTimeout) || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("failing eval past the deadline: expected ErrTimeout wrapping the JS error, got %v", err)
+	}
+
+	r.config.Timeout = time.Minute
+	if _, err := r.evalModule("throw new Error('interrupted by the user');"); err == nil || errors.Is(err, ErrTimeout) {
+		t.Errorf("a fast error mentioning interruption is not a timeout, got %v", err)
+	}
+	if got, err := r.evalModule(busy); err != nil || got == "" {
+		t.Errorf("eval within the timeout = %q, %v", got, err)
+	}
+}
+
+func TestMemoryLimitError(t *testing.T) {
+	rt, err := qjs.New(qjs.Option{MemoryLimit: 8 << 20})
+	if err != nil {
+		t.Fatalf("qjs.New: %v", err)
+	}
+	defer rt.Close()
+
+	r := &Runtime{rt: rt, config: Config{MemoryLimit: 8 << 20}}
+	_, err = r.evalModule("const a = []; for (let i = 0; i < 1e7; i++) a.push({i}); export default a.length;")
+	if !errors.Is(err, ErrMemoryLimit) {
+		t.Fatalf("expected ErrMemoryLimit, got %v", err)
+	}
+
+	// The allocation is rolled back, so the runtime remains usable.
+	if got, err := r.evalModule("export default 'ok';"); err != nil || got != "ok" {
+		t.Errorf("evalModule after OOM = %q, %v", got, err)
+	}
+}
+
+func TestCompileModulesLoadInAnotherRuntime(t *testing.T) {
+	compiled, err := compileModules([]moduleSource{
+		{name: "a", src: "export const x = 41;"},
+		{name: "b", src: "import { x } from 'a'; export const y = x + 1;"},
+	})
+	if err != nil {
+		t.Fatalf("compileModules: %v", err)
+	}
+
+	rt, err := qjs.New(qjs.Option{})
+	if err != nil {
+		t.Fatalf("qjs.New: %v", err)
+	}
+	defer rt.Close()
+	ctx := rt.Context()
+	for _, mod := range compiled {
+		val, err := ctx.Load(mod.name, qjs.Bytecode(mod.bytecode))
+		if err != nil {
+			t.Fatalf("loading %s bytecode: %v", mod.name, err)
+		}
+		val.Free()
+	}
+	val, err := ctx.Eval("check.js", qjs.Code("import { y } from 'b'; export default y;"), qjs.TypeModule())
+	if err != nil {
+		t.Fatalf("Eval: %v", err)
+	}
+	defer val.Free()
+	if got := val.Int32(); got != 42 {
+		t.Errorf("expected 42 from the compiled modules, got %d", got)
+	}
+}
+
+func TestCompileModulesSyntaxError(t *testing.T) {
+	_, err := compileModules([]moduleSource{{name: "broken", src: "export const = ;"}})
+	if err == nil || !strings.Contains(err.Error(), "compiling module broken") {
+		t.Errorf("expected a compile error naming the module, got %v", err)
+	}
+}
+
+func TestSniffFormat(t *testing.T) {
+	for _, tc := range []struct {
+		data, want string
+	}{
+		{`[{"a": 1}]`, "json"},
+		{"\xef\xbb\xbf\n  {\"values\": []}", "json"},
+		{"a,b\n1,2\n", "csv"},
+		{"a\tb,c\n1\t2\n", "tsv"},
+		{"just text", ""},
+		{"   ", ""},
+	} {
+		if got := sniffFormat([]byte(tc.data)); got != tc.want {
+			t.Errorf("sniffFormat(%q) = %q; want %q", tc.data, got, tc.want)
+		}
+	}
+}
+
+func TestCountRequest(t *testing.T) {
+	r := &Runtime{config: Config{MaxRequests: 2}}
+	for _, uri := range []string{"a", "a", "b", "b"} {
+		if err := r.countRequest(uri); err != nil {
+			t.Fatalf("countRequest(%q): %v", uri, err)
+		}
+	}
+	if err := r.countRequest("c"); !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expected ErrLimitExceeded for a third URI, got %v", err)
+	}
+	if !errors.Is(r.requestErr, ErrLimitExceeded) {
+		t.Errorf("expected the limit error to be kept for the eval, got %v", r.requestErr)
+	}
+}
+
+func TestCheckMarks(t *testing.T) {
+	r := &Runtime{config: Config{MaxMarks: 10}}
+	if err := r.checkMarks(10); err != nil {
+		t.Fatalf("checkMarks(10): %v", err)
+	}
+	err := r.checkMarks(11)
+	if !errors.Is(err, ErrLimitExceeded) {
+		t.Fatalf("expected ErrLimitExceeded past the limit, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "sample") {
+		t.Errorf("expected the error to suggest sampling, got %v", err)
+	}
+
+	unlimited := &Runtime{}
+	if err := unlimited.checkMarks(1 << 40); err != nil {
+		t.Errorf("zero MaxMarks should be unlimited: %v", err)
+	}
+}
+
+func TestMeasuredFonts(t *testing.T) {
+	rt, err := qjs.New(qjs.Option{})
+	if err != nil {
+		t.Fatalf("qjs.New: %v", err)
+	}
+	defer rt.Close()
+
+	r := &Runtime{rt: rt, config: Config{TextMeasurer: checkingMeasurer{}}}
+	if err := r.registerBridgeFunctions(); err != nil {
+		t.Fatalf("registerBridgeFunctions: %v", err)
+	}
+
+	if _, err := r.evalModule(`
+		__aster_measure_text("a", "12px Caveat, serif");
+		__aster_measure_text("b", "bold 11px sans-serif");
+		__aster_measure_text("c", "12px Caveat, serif");
+		export default 'ok';
+	`); err != nil {
+		t.Fatalf("evalModule: %v", err)
+	}
+	if got := strings.Join(r.MeasuredFonts(), "|"); got != "12px Caveat, serif|bold 11px sans-serif" {
+		t.Errorf("MeasuredFonts = %q", got)
+	}
+
+	if _, err := r.evalModule(`export default 'ok';`); err != nil {
+		t.Fatalf("evalModule: %v", err)
+	}
+	if got := r.MeasuredFonts(); len(got) != 0 {
+		t.Errorf("MeasuredFonts should reset per eval, got %q", got)
+	}
+}
+
+// A theme read from a file reaches the bridge verbatim, whatever template
+// literal syntax it contains.
+func TestThemeArgEscapes(t *testing.T) {
+	rt, err := qjs.New(qjs.Option{})
+	if err != nil {
+		t.Fatalf("qjs.New: %v", err)
+	}
+	defer rt.Close()
+
+	theme := `{"title": {"text": "` + "`" + `a${globalThis.x = 1}bé \\` + "`" + `"}}`
+	r := &Runtime{rt: rt, config: Config{Theme: theme}}
+	got, err := r.evalModule("export default " + r.themeArg() + " + (globalThis.x === undefined ? '' : ' evaluated');")
+	if err != nil {
+		t.Fatalf("eval: %v", err)
+	}
+	if got != theme {
+		t.Errorf("theme changed on the way to JS:\n got %s\nwant %s", got, theme)
+	}
+}
@@ -0,0 +1,542 @@
+// Package svgdoc implements string-level transforms on the SVG documents
+// produced by Vega's SVG renderer. The transforms rely on the regular shape
+// of Vega's output (a single root <svg> element, escaped text content and
+// attribute values) rather than a full XML parser.
+package svgdoc
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// dangerousElemRes match <script> and <foreignObject> elements in any
+	// case and with any namespace prefix (<svg:script>), either
+	// self-closing or with content. Scripts are removed first so a script
+	// nested in a foreignObject cannot end the foreignObject match early.
+	dangerousElemRes = []*regexp.Regexp{
+		regexp.MustCompile(`(?is)<(?:[a-z][\w.-]*:)?script\b[^>]*?/>|<(?:[a-z][\w.-]*:)?script\b.*?</(?:[a-z][\w.-]*:)?script\s*>`),
+		regexp.MustCompile(`(?is)<(?:[a-z][\w.-]*:)?foreignObject\b[^>]*?/>|<(?:[a-z][\w.-]*:)?foreignObject\b.*?</(?:[a-z][\w.-]*:)?foreignObject\s*>`),
+	}
+
+	// tagRe matches a single start tag.
+	tagRe = regexp.MustCompile(`<[a-zA-Z][^>]*>`)
+
+	// eventNameRe matches the local name of an on* event handler attribute.
+	eventNameRe = regexp.MustCompile(`(?i)^on[a-z]+$`)
+
+	// rootRe matches the root <svg> start tag.
+	rootRe = regexp.MustCompile(`<svg\b[^>]*>`)
+)
+
+// rootTag returns the root <svg> start tag and its byte offsets, or ok=false
+// if the document has none.
+func rootTag(svg string) (tag string, start, end int, ok bool) {
+	loc := rootRe.FindStringIndex(svg)
+	if loc == nil {
+		return "", 0, 0, false
+	}
+	return svg[loc[0]:loc[1]], loc[0], loc[1], true
+}
+
+// attrRe returns a regexp matching a double-quoted attribute in a tag.
+func attrRe(name string) *regexp.Regexp {
+	return regexp.MustCompile(`\s` + regexp.QuoteMeta(name) + `="([^"]*)"`)
+}
+
+// Attr returns the value of a double-quoted attribute in a start tag.
+func Attr(tag, name string) (string, bool) {
+	m := attrRe(name).FindStringSubmatch(tag)
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// SetAttr sets (or adds) a double-quoted attribute on a start tag.
+func SetAttr(tag, name, value string) string {
+	re := attrRe(name)
+	attr := fmt.Sprintf(` %s="%s"`, name, value)
+	if re.MatchString(tag) {
+		return re.ReplaceAllLiteralString(tag, attr)
+	}
+	closing := ">"
+	if strings.HasSuffix(tag, "/>") {
+		closing = "/>"
+	}
+	return strings.TrimSuffix(tag, closing) + attr + closing
+}
+
+// SetRootAttr sets (or adds) an attribute on the root <svg> start tag.
+func SetRootAttr(svg, name, value string) string {
+	tag, start, end, ok := rootTag(svg)
+	if !ok {
+		return svg
+	}
+	return svg[:start] + SetAttr(tag, name, value) + svg[end:]
+}
+
+// RemoveAttr deletes a double-quoted attribute from a start tag.
+func RemoveAttr(tag, name string) string {
+	return attrRe(name).ReplaceAllLiteralString(tag, "")
+}
+
+// Responsive rewrites the root <svg> so it scales to its container: the
+// viewBox is kept (or derived from the pixel size), width becomes 100%, and
+// the fixed height is removed.
+func Responsive(svg string) string {
+	tag, start, end, ok := rootTag(svg)
+	if !ok {
+		return svg
+	}
+	if _, ok := Attr(tag, "viewBox"); !ok {
+		w, wok := Attr(tag, "width")
+		h, hok := Attr(tag, "height")
+		if wok && hok {
+			tag = SetAttr(tag, "viewBox", "0 0 "+w+" "+h)
+		}
+	}
+	tag = SetAttr(tag, "width", "100%")
+	tag = RemoveAttr(tag, "height")
+	return svg[:start] + tag + svg[end:]
+}
+
+// Size returns the pixel width and height of the root <svg>, or ok=false if
+// either is missing or not a plain pixel length.
+func Size(svg string) (w, h float64, ok bool) {
+	tag, _, _, ok := rootTag(svg)
+	if !ok {
+		return 0, 0, false
+	}
+	w, wok := pixelAttr(tag, "width")
+	h, hok := pixelAttr(tag, "height")
+	return w, h, wok && hok
+}
+
+// ScaleRoot rewrites the root <svg> so it renders at scale times its pixel
+// size, with each dimension rounded half up to a whole pixel. The original
+// size becomes the viewBox when there is none, stretched to the rounded size
+// so no letterboxing appears. It reports false, leaving svg unchanged, when
+// the root has no plain pixel width and height.
+func ScaleRoot(svg string, scale float64) (string, bool) {
+	tag, start, end, ok := rootTag(svg)
+	if !ok {
+		return svg, false
+	}
+	w, wok := pixelAttr(tag, "width")
+	h, hok := pixelAttr(tag, "height")
+	if !wok || !hok {
+		return svg, false
+	}
+	if _, ok := Attr(tag, "viewBox"); !ok {
+		tag = SetAttr(tag, "viewBox", "0 0 "+formatNumber(w)+" "+formatNumber(h))
+		if _, ok := Attr(tag, "preserveAspectRatio"); !ok {
+			tag = SetAttr(tag, "preserveAspectRatio", "none")
+		}
+	}
+	tag = SetAttr(tag, "width", formatNumber(math.Floor(w*scale+0.5)))
+	tag = SetAttr(tag, "height", formatNumber(math.Floor(h*scale+0.5)))
+	return svg[:start] + tag + svg[end:], true
+}
+
+// Crop rewrites the root <svg> to show only the w×h region at (x, y) of its
+// user coordinates, at one pixel per unit.
+func Crop(svg string, x, y, w, h float64) string {
+	tag, start, end, ok := rootTag(svg)
+	if !ok {
+		return svg
+	}
+	tag = SetAttr(tag, "width", formatNumber(w))
+	tag = SetAttr(tag, "height", formatNumber(h))
+	tag = SetAttr(tag, "viewBox", formatNumber(x)+" "+formatNumber(y)+" "+formatNumber(w)+" "+formatNumber(h))
+	return svg[:start] + tag + svg[end:]
+}
+
+// pixelAttr parses a positive length attribute given in pixels, with or
+// without a px suffix.
+func pixelAttr(tag, name string) (float64, bool) {
+	v, ok := Attr(tag, name)
+	if !ok {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(v), "px"), 64)
+	if err != nil || f <= 0 || math.IsInf(f, 0) {
+		return 0, false
+	}
+	return f, true
+}
+
+func formatNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// Sanitize removes <script> and <foreignObject> elements, on* event handler
+// attributes, and attributes whose value is a javascript: URL, such as
+// href="javascript:...". Start tags are split into attributes the way an
+// HTML parser splits them, so text inside a quoted value is never mistaken
+// for an attribute, while "/" or a closing quote is enough to start the
+// next one (<svg/onload=...>). Values are entity-decoded before the URL
+// check, as a browser would.
+func Sanitize(svg string) string {
+	for _, re := range dangerousElemRes {
+		svg = re.ReplaceAllString(svg, "")
+	}
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(svg, '<')
+		if i < 0 || i+1 >= len(svg) {
+			b.WriteString(svg)
+			return b.String()
+		}
+		b.WriteString(svg[:i])
+		svg = svg[i:]
+		if c := svg[1]; !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z') {
+			b.WriteByte('<')
+			svg = svg[1:]
+			continue
+		}
+		n := sanitizeTag(&b, svg)
+		svg = svg[n:]
+	}
+}
+
+// sanitizeTag writes the start tag at the beginning of s to b without its
+// active attributes and returns the length of the tag in s. An unterminated
+// tag runs to the end of s.
+func sanitizeTag(b *strings.Builder, s string) int {
+	i := 1
+	for i < len(s) && !isTagSpace(s[i]) && s[i] != '/' && s[i] != '>' {
+		i++
+	}
+	b.WriteString(s[:i])
+	for i < len(s) {
+		// Whitespace and '/' separate attributes; a quoted value needs no
+		// separator after it.
+		start := i
+		for i < len(s) && (isTagSpace(s[i]) || s[i] == '/') {
+			i++
+		}
+		if i >= len(s) || s[i] == '>' {
+			b.WriteString(s[start:min(i+1, len(s))])
+			return min(i+1, len(s))
+		}
+
+		// The name runs to a separator, '>' or '='; a leading '=' is part
+		// of it, as in HTML.
+		nameStart := i
+		i++
+		for i < len(s) && !isTagSpace(s[i]) && s[i] != '/' && s[i] != '>' && s[i] != '=' {
+			i++
+		}
+		name := s[nameStart:i]
+		if j := strings.LastIndexByte(name, ':'); j >= 0 {
+			name = name[j+1:]
+		}
+		drop := eventNameRe.MatchString(name)
+
+		j := i
+		for j < len(s) && isTagSpace(s[j]) {
+			j++
+		}
+		if j < len(s) && s[j] == '=' {
+			j++
+			for j < len(s) && isTagSpace(s[j]) {
+				j++
+			}
+			valueStart := j
+			if j < len(s) && (s[j] == '"' || s[j] == '\'') {
+				if end := strings.IndexByte(s[j+1:], s[j]); end >= 0 {
+					j += end + 2
+				} else {
+					j = len(s)
+				}
+			} else {
+				for j < len(s) && !isTagSpace(s[j]) && s[j] != '>' {
+					j++
+				}
+			}
+			if isScriptURL(s[valueStart:j]) {
+				drop = true
+			}
+			i = j
+		}
+		if !drop {
+			b.WriteString(s[start:i])
+		}
+	}
+	return i
+}
+
+// isTagSpace reports whether c is whitespace between the parts of a tag.
+func isTagSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// isScriptURL reports whether an attribute value, quoted or not, is a
+// javascript: URL once entities are decoded and the whitespace and control
+// characters browsers ignore in a scheme are removed.
+func isScriptURL(value string) bool {
+	if n := len(value); n >= 2 && (value[0] == '"' || value[0] == '\'') && value[n-1] == value[0] {
+		value = value[1 : n-1]
+	}
+	value = strings.Map(func(r rune) rune {
+		if r <= ' ' {
+			return -1
+		}
+		return r
+	}, html.UnescapeString(value))
+	return strings.HasPrefix(strings.ToLower(value), "javascript:")
+}
+
+var (
+	classAttrRe = regexp.MustCompile(`(\sclass=")([^"]*)"`)
+	idAttrRe    = regexp.MustCompile(`(\sid=")([^"]*)"`)
+	hrefRefRe   = regexp.MustCompile(`(\s(?:xlink:)?href="#)([^"]*)"`)
+	urlRefRe    = regexp.MustCompile(`(url\(\s*['"]?#)([^)'"\s]+)`)
+)
+
+// PrefixNames namespaces the document's class names and element ids with
+// prefix, along with the url(#id) and href="#id" references to those ids,
+// so several documents inlined into one HTML page neither share styling nor
+// resolve each other's clip paths and gradients.
+func PrefixNames(svg, prefix string) string {
+	if prefix == "" {
+		return svg
+	}
+	return tagRe.ReplaceAllStringFunc(svg, func(tag string) string {
+		tag = classAttrRe.ReplaceAllStringFunc(tag, func(attr string) string {
+			m := classAttrRe.FindStringSubmatch(attr)
+			names := strings.Fields(m[2])
+			for i, name := range names {
+				names[i] = prefix + name
+			}
+			return m[1] + strings.Join(names, " ") + `"`
+		})
+		return renameIDs(tag, "${1}"+escapeDollar(prefix)+"${2}")
+	})
+}
+
+// SuffixIDs appends suffix to every element id and to the url(#id) and
+// href="#id" references to them, leaving class names alone.
+func SuffixIDs(svg, suffix string) string {
+	if suffix == "" {
+		return svg
+	}
+	return tagRe.ReplaceAllStringFunc(svg, func(tag string) string {
+		return renameIDs(tag, "${1}${2}"+escapeDollar(suffix))
+	})
+}
+
+// renameIDs rewrites the ids and id references in a start tag with a
+// replacement template, where ${1} is the text before the id and ${2} the id.
+func renameIDs(tag, template string) string {
+	tag = idAttrRe.ReplaceAllString(tag, template+`"`)
+	tag = hrefRefRe.ReplaceAllString(tag, template+`"`)
+	return urlRefRe.ReplaceAllString(tag, template)
+}
+
+// escapeDollar makes s literal in a regexp replacement template.
+func escapeDollar(s string) string {
+	return strings.ReplaceAll(s, "$", "$$")
+}
+
+var fontFamilyAttrRe = regexp.MustCompile(`font-family="([^"]*)"`)
+
+// ReplaceFontFamilies rewrites font-family attributes, substituting each
+// family in the list whose lower-cased, unquoted name is a key of aliases.
+// Attributes without an aliased family are left untouched.
+func ReplaceFontFamilies(svg string, aliases map[string]string) string {
+	if len(aliases) == 0 {
+		return svg
+	}
+	return fontFamilyAttrRe.ReplaceAllStringFunc(svg, func(attr string) string {
+		families := strings.Split(fontFamilyAttrRe.FindStringSubmatch(attr)[1], ",")
+		changed := false
+		for i, f := range families {
+			f = strings.TrimSpace(f)
+			name := strings.Trim(strings.ReplaceAll(f, "&quot;", `"`), `"'`)
+			if to, ok := aliases[strings.ToLower(name)]; ok {
+				if strings.ContainsAny(to, " ,") {
+					to = "'" + to + "'"
+				}
+				f = to
+				changed = true
+			}
+			families[i] = f
+		}
+		if !changed {
+			return attr
+		}
+		return `font-family="` + strings.Join(families, ", ") + `"`
+	})
+}
+
+// PrependChildren inserts markup as the first content of the root <svg>
+// element, expanding a self-closing root if needed.
+func PrependChildren(svg, markup string) string {
+	if markup == "" {
+		return svg
+	}
+	tag, start, end, ok := rootTag(svg)
+	if !ok {
+		return svg
+	}
+	if strings.HasSuffix(tag, "/>") {
+		open := strings.TrimRight(strings.TrimSuffix(tag, "/>"), " \t\r\n") + ">"
+		return svg[:start] + open + markup + "</svg>" + svg[end:]
+	}
+	return svg[:end] + markup + svg[end:]
+}
+
+var (
+	gradientRe  = regexp.MustCompile(`(?s)<(linearGradient|radialGradient)\b([^>]*)>(.*?)</(?:linearGradient|radialGradient)\s*>`)
+	patternRe   = regexp.MustCompile(`(?s)<pattern\b([^>]*)>(.*?)</pattern\s*>`)
+	stopRe      = regexp.MustCompile(`<stop\b[^>]*>`)
+	emptyDefsRe = regexp.MustCompile(`<defs>\s*</defs>`)
+	hexColorRe  = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+	rgbColorRe  = regexp.MustCompile(`^rgba?\(\s*([\d.]+)\s*,\s*([\d.]+)\s*,\s*([\d.]+)\s*(?:,\s*([\d.]+)\s*)?\)$`)
+)
+
+// FlattenGradients replaces every gradient paint with a solid color taken
+// at the gradient's midpoint (offset 0.5), for SVG consumers that mishandle
+// gradient definitions. The gradients, the <pattern> elements Vega wraps
+// radial gradients in, and any <defs> left empty are removed. Colors are
+// interpolated in sRGB when both neighbouring stops are hex or rgb() colors;
+// otherwise the stop nearest the midpoint is used as is.
+func FlattenGradients(svg string) string {
+	colors := make(map[string]string)
+	svg = gradientRe.ReplaceAllStringFunc(svg, func(elem string) string {
+		m := gradientRe.FindStringSubmatch(elem)
+		id, ok := Attr(m[2], "id")
+		color, found := midColor(stopRe.FindAllString(m[3], -1))
+		if !ok || !found {
+			return elem
+		}
+		colors[id] = color
+		return ""
+	})
+	if len(colors) == 0 {
+		return svg
+	}
+	svg = patternRe.ReplaceAllStringFunc(svg, func(elem string) string {
+		m := patternRe.FindStringSubmatch(elem)
+		id, ok := Attr(m[1], "id")
+		ref := urlRefRe.FindStringSubmatch(m[2])
+		if !ok || ref == nil || colors[ref[2]] == "" {
+			return elem
+		}
+		colors[id] = colors[ref[2]]
+		return ""
+	})
+	svg = emptyDefsRe.ReplaceAllString(svg, "")
+	return paintRefRe.ReplaceAllStringFunc(svg, func(ref string) string {
+		if color, ok := colors[paintRefRe.FindStringSubmatch(ref)[1]]; ok {
+			return color
+		}
+		return ref
+	})
+}
+
+// paintRefRe matches a whole url(#id) paint reference.
+var paintRefRe = regexp.MustCompile(`url\(\s*['"]?#([^)'"\s]+)['"]?\s*\)`)
+
+// gradientStop is a parsed <stop>: its offset in [0, 1] and color.
+type gradientStop struct {
+	offset float64
+	color  string
+}
+
+// midColor returns the color of a gradient with the given <stop> tags at
+// offset 0.5, or ok=false if it has no stops.
+func midColor(tags []string) (string, bool) {
+	stops := make([]gradientStop, 0, len(tags))
+	for _, tag := range tags {
+		color, ok := Attr(tag, "stop-color")
+		if !ok {
+			color = "black"
+		}
+		if op, ok := Attr(tag, "stop-opacity"); ok {
+			if a, err := strconv.ParseFloat(op, 64); err == nil && a < 1 {
+				if r, g, b, alpha, ok := parseColor(color); ok {
+					color = formatColor(r, g, b, alpha*a)
+				}
+			}
+		}
+		off, _ := Attr(tag, "offset")
+		f, err := strconv.ParseFloat(strings.TrimSuffix(off, "%"), 64)
+		if err != nil {
+			f = 0
+		} else if strings.HasSuffix(off, "%") {
+			f /= 100
+		}
+		stops = append(stops, gradientStop{offset: math.Max(0, math.Min(1, f)), color: color})
+	}
+	if len(stops) == 0 {
+		return "", false
+	}
+
+	// The stops either side of the midpoint.
+	lo, hi := stops[0], stops[len(stops)-1]
+	for _, s := range stops {
+		if s.offset <= 0.5 {
+			lo = s
+		}
+	}
+	for i := len(stops) - 1; i >= 0; i-- {
+		if stops[i].offset >= 0.5 {
+			hi = stops[i]
+		}
+	}
+	if lo.offset == hi.offset || lo.color == hi.color {
+		return lo.color, true
+	}
+	r1, g1, b1, a1, ok1 := parseColor(lo.color)
+	r2, g2, b2, a2, ok2 := parseColor(hi.color)
+	if !ok1 || !ok2 {
+		if 0.5-lo.offset <= hi.offset-0.5 {
+			return lo.color, true
+		}
+		return hi.color, true
+	}
+	t := (0.5 - lo.offset) / (hi.offset - lo.offset)
+	mix := func(a, b float64) float64 { return a + (b-a)*t }
+	return formatColor(mix(r1, r2), mix(g1, g2), mix(b1, b2), mix(a1, a2)), true
+}
+
+// parseColor parses a hex or rgb()/rgba() color into 0-255 channels and an
+// alpha in [0, 1].
+func parseColor(s string) (r, g, b, a float64, ok bool) {
+	s = strings.TrimSpace(s)
+	if m := hexColorRe.FindStringSubmatch(s); m != nil {
+		hex := m[1]
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		v, _ := strconv.ParseUint(hex, 16, 32)
+		return float64(v >> 16), float64(v >> 8 & 0xff), float64(v & 0xff), 1, true
+	}
+	if m := rgbColorRe.FindStringSubmatch(s); m != nil {
+		r, _ = strconv.ParseFloat(m[1], 64)
+		g, _ = strconv.ParseFloat(m[2], 64)
+		b, _ = strconv.ParseFloat(m[3], 64)
+		a = 1
+		if m[4] != "" {
+			a, _ = strconv.ParseFloat(m[4], 64)
+		}
+		return r, g, b, a, true
+	}
+	return 0, 0, 0, 0, false
+}
+
+// formatColor writes a color as #rrggbb, or as rgba() if it is translucent.
+func formatColor(r, g, b, a float64) string {
+	c := func(v float64) int { return int(math.Round(math.Max(0, math.Min(255, v)))) }
+	if a < 1 {
+		return fmt.Sprintf("rgba(%d,%d,%d,%s)", c(r), c(g), c(b), strconv.FormatFloat(math.Round(a*1000)/1000, 'f', -1, 64))
+	}
+	return fmt.Sprintf("#%02x%02x%02x", c(r), c(g), c(b))
+}
@@ -0,0 +1,221 @@
+package svgdoc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitize(t *testing.T) {
+	in := `<svg xmlns="http://www.w3.org/2000/svg" width="10" height="10" onload="alert(1)">` +
+		`<script>alert(2)</script>` +
+		`<script href="evil.js"/>` +
+		`<foreignObject width="10" height="10"><div><script>alert(3)</script></div></foreignObject>` +
+		`<a xlink:href="javascript:alert(4)"><rect width="10" height="10" ONCLICK='alert(5)' fill="red"/></a>` +
+		`<text>onload=kept &lt;script&gt;</text>` +
+		`</svg>`
+
+	got := Sanitize(in)
+
+	for _, bad := range []string{"<script", "<foreignObject", "</foreignObject", `onload="`, "ONCLICK", "javascript:"} {
+		if strings.Contains(got, bad) {
+			t.Errorf("sanitized SVG still contains %q: %s", bad, got)
+		}
+	}
+	for _, keep := range []string{`<rect width="10" height="10" fill="red"/>`, "<text>onload=kept &lt;script&gt;</text>", "</svg>"} {
+		if !strings.Contains(got, keep) {
+			t.Errorf("sanitized SVG lost %q: %s", keep, got)
+		}
+	}
+	if !strings.HasPrefix(got, "<svg") {
+		t.Errorf("expected output starting with <svg, got: %.100s", got)
+	}
+}
+
+func TestSanitizeTokenizesAttributes(t *testing.T) {
+	tests := map[string]string{
+		// Text inside a quoted value is not an attribute.
+		`<svg><rect aria-label="a; onx=1" width="2"/></svg>`: `<svg><rect aria-label="a; onx=1" width="2"/></svg>`,
+		`<svg><text title='x > onload=y'>t</text></svg>`:     `<svg><text title='x > onload=y'>t</text></svg>`,
+		// Handlers in any case, unquoted or namespaced, are dropped.
+		`<svg><rect OnLoad=alert(1) ev:onclick="x()" width="2"/></svg>`: `<svg><rect width="2"/></svg>`,
+		// javascript: URLs are found through entities, case and whitespace.
+		`<svg><a href="&#106;avascript:alert(1)"><rect/></a></svg>`:           `<svg><a><rect/></a></svg>`,
+		`<svg><a xlink:href=" JaVa&#x09;Script:alert(1)"><rect/></a></svg>`:   `<svg><a><rect/></a></svg>`,
+		`<svg><animate attributeName="href" to="javascript:alert(1)"/></svg>`: `<svg><animate attributeName="href"/></svg>`,
+		`<svg><a href="https://example.com/javascript:x"><rect/></a></svg>`:   `<svg><a href="https://example.com/javascript:x"><rect/></a></svg>`,
+		// A '/' or a closing quote separates attributes, as in HTML.
+		`<svg/onload="alert(1)"><rect/></svg>`:                     `<svg><rect/></svg>`,
+		`<svg x="1"onload="alert(1)"><rect/></svg>`:                `<svg x="1"><rect/></svg>`,
+		`<svg><rect x='1'/onclick=alert(1) width="2"/></svg>`:      `<svg><rect x='1' width="2"/></svg>`,
+		`<svg><rect =x onload=alert(1)/></svg>`:                    `<svg><rect =x></svg>`, // the unquoted value takes the "/"
+		`<svg><!-- <rect onload=x> --><text>a &lt; b</text></svg>`: `<svg><!-- <rect> --><text>a &lt; b</text></svg>`,
+		// Namespaced and upper-case scripts are removed.
+		`<svg:svg><svg:script>alert(1)</svg:script><SCRIPT>alert(2)</SCRIPT><svg:rect/></svg:svg>`: `<svg:svg><svg:rect/></svg:svg>`,
+	}
+	for in, want := range tests {
+		if got := Sanitize(in); got != want {
+			t.Errorf("Sanitize(%s)\n got: %s\nwant: %s", in, got, want)
+		}
+	}
+}
+
+func TestResponsive(t *testing.T) {
+	in := `<svg xmlns="http://www.w3.org/2000/svg" class="marks" width="344" height="200" viewBox="0 0 344 200"><rect width="344" height="200" fill="white"/></svg>`
+	got := Responsive(in)
+	want := `<svg xmlns="http://www.w3.org/2000/svg" class="marks" width="100%" viewBox="0 0 344 200"><rect width="344" height="200" fill="white"/></svg>`
+	if got != want {
+		t.Errorf("Responsive:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestScaleRoot(t *testing.T) {
+	for _, tc := range []struct {
+		in    string
+		scale float64
+		want  string
+	}{
+		{`<svg width="100" height="50"></svg>`, 1.5,
+			`<svg width="150" height="75" viewBox="0 0 100 50" preserveAspectRatio="none"></svg>`},
+		{`<svg width="100" height="50"></svg>`, 0.75,
+			`<svg width="75" height="38" viewBox="0 0 100 50" preserveAspectRatio="none"></svg>`},
+		// 100 * 1.1 is 110.00000000000001 in floating point.
+		{`<svg width="100px" height="10" viewBox="0 0 100 10"></svg>`, 1.1,
+			`<svg width="110" height="11" viewBox="0 0 100 10"></svg>`},
+	} {
+		got, ok := ScaleRoot(tc.in, tc.scale)
+		if !ok || got != tc.want {
+			t.Errorf("ScaleRoot(%s, %v) = %s, %v; want %s", tc.in, tc.scale, got, ok, tc.want)
+		}
+	}
+
+	for _, in := range []string{`<svg width="100%" height="50"></svg>`, `<svg viewBox="0 0 1 1"></svg>`, `<g/>`} {
+		if got, ok := ScaleRoot(in, 2); ok || got != in {
+			t.Errorf("ScaleRoot(%s) should leave non-pixel sizes alone, got %s", in, got)
+		}
+	}
+}
+
+func TestCrop(t *testing.T) {
+	got := Crop(`<svg class="marks" width="300" height="200" viewBox="0 0 300 200"><g/></svg>`, 250, 5, 45, 80.5)
+	want := `<svg class="marks" width="45" height="80.5" viewBox="250 5 45 80.5"><g/></svg>`
+	if got != want {
+		t.Errorf("Crop = %s; want %s", got, want)
+	}
+}
+
+func TestSize(t *testing.T) {
+	if w, h, ok := Size(`<svg class="marks" width="344" height="200.5px">`); !ok || w != 344 || h != 200.5 {
+		t.Errorf("Size = %v, %v, %v; want 344, 200.5, true", w, h, ok)
+	}
+	if _, _, ok := Size(`<svg width="100%" height="200">`); ok {
+		t.Error("Size should reject a percentage width")
+	}
+}
+
+func TestResponsiveAddsViewBox(t *testing.T) {
+	got := Responsive(`<svg width="120" height="80"></svg>`)
+	if v, _ := Attr(got, "viewBox"); v != "0 0 120 80" {
+		t.Errorf("expected viewBox derived from size, got %q in %s", v, got)
+	}
+	if _, ok := Attr(got, "height"); ok {
+		t.Errorf("expected height removed: %s", got)
+	}
+}
+
+func TestReplaceFontFamilies(t *testing.T) {
+	aliases := map[string]string{"helvetica neue": "Liberation Sans", "caveat": "serif"}
+	tests := map[string]string{
+		`<text font-family="Helvetica Neue">a</text>`:                    `<text font-family="'Liberation Sans'">a</text>`,
+		`<text font-family="&quot;Helvetica Neue&quot;, Arial">a</text>`: `<text font-family="'Liberation Sans', Arial">a</text>`,
+		`<text font-family="Caveat,sans-serif">a</text>`:                 `<text font-family="serif, sans-serif">a</text>`,
+		`<text font-family="sans-serif">a</text>`:                        `<text font-family="sans-serif">a</text>`,
+		`<text font-family="Arial,  Helvetica">Helvetica Neue</text>`:    `<text font-family="Arial,  Helvetica">Helvetica Neue</text>`,
+	}
+	for in, want := range tests {
+		if got := ReplaceFontFamilies(in, aliases); got != want {
+			t.Errorf("ReplaceFontFamilies(%s)\n got: %s\nwant: %s", in, got, want)
+		}
+	}
+}
+
+func TestSetRootAttr(t *testing.T) {
+	tests := map[string]string{
+		`<svg width="1"><g shape-rendering="auto"/></svg>`: `<svg width="1" shape-rendering="crispEdges"><g shape-rendering="auto"/></svg>`,
+		`<svg shape-rendering="auto" width="1"/>`:          `<svg shape-rendering="crispEdges" width="1"/>`,
+		`not svg`: `not svg`,
+	}
+	for in, want := range tests {
+		if got := SetRootAttr(in, "shape-rendering", "crispEdges"); got != want {
+			t.Errorf("SetRootAttr(%s) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestPrependChildren(t *testing.T) {
+	tests := map[string]string{
+		`<svg width="1"><g/></svg>`: `<svg width="1"><title>t</title><g/></svg>`,
+		`<svg width="1"/>`:          `<svg width="1"><title>t</title></svg>`,
+		`not svg`:                   `not svg`,
+	}
+	for in, want := range tests {
+		if got := PrependChildren(in, "<title>t</title>"); got != want {
+			t.Errorf("PrependChildren(%s) = %s, want %s", in, got, want)
+		}
+	}
+}
+
+func TestPrefixNames(t *testing.T) {
+	in := `<svg class="marks" width="10" height="10"><defs><clipPath id="clip1"><rect width="10" height="10"/></clipPath></defs>` +
+		`<g class="mark-rect role-mark" clip-path="url(#clip1)"><path style="fill: url('#gradient_0')"/><use href="#clip1"/></g>` +
+		`<text class="x">url(#clip1)</text></svg>`
+	got := PrefixNames(in, "c1-")
+	want := `<svg class="c1-marks" width="10" height="10"><defs><clipPath id="c1-clip1"><rect width="10" height="10"/></clipPath></defs>` +
+		`<g class="c1-mark-rect c1-role-mark" clip-path="url(#c1-clip1)"><path style="fill: url('#c1-gradient_0')"/><use href="#c1-clip1"/></g>` +
+		`<text class="c1-x">url(#clip1)</text></svg>`
+	if got != want {
+		t.Errorf("PrefixNames:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestSuffixIDs(t *testing.T) {
+	in := `<svg class="marks"><clipPath id="clip1"/><g class="mark" clip-path="url(#clip1)"><use xlink:href="#clip1"/></g></svg>`
+	want := `<svg class="marks"><clipPath id="clip1-x7"/><g class="mark" clip-path="url(#clip1-x7)"><use xlink:href="#clip1-x7"/></g></svg>`
+	if got := SuffixIDs(in, "-x7"); got != want {
+		t.Errorf("SuffixIDs:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestFlattenGradients(t *testing.T) {
+	in := `<svg class="marks" width="20" height="10"><defs>` +
+		`<linearGradient id="gradient_0" x1="0" x2="1" y1="0" y2="0"><stop offset="0" stop-color="#000000"/><stop offset="1" stop-color="#ffffff"/></linearGradient>` +
+		`<pattern id="pgradient_1" viewBox="0,0,1,1" width="100%" height="100%"><rect width="1" height="1" fill="url(#gradient_1)"/></pattern>` +
+		`<radialGradient id="gradient_1" cx="0.5" cy="0.5" r="0.5"><stop offset="0" stop-color="red"/><stop offset="0.5" stop-color="blue"/><stop offset="1" stop-color="green"/></radialGradient>` +
+		`</defs><path fill="url(#gradient_0)"/><path style="stroke: url('#pgradient_1')"/></svg>`
+	got := FlattenGradients(in)
+	want := `<svg class="marks" width="20" height="10"><path fill="#808080"/><path style="stroke: blue"/></svg>`
+	if got != want {
+		t.Errorf("FlattenGradients:\n got %s\nwant %s", got, want)
+	}
+
+	clip := `<svg><defs><clipPath id="clip1"><rect/></clipPath></defs><g clip-path="url(#clip1)"/></svg>`
+	if got := FlattenGradients(clip); got != clip {
+		t.Errorf("FlattenGradients should leave an SVG without gradients alone, got %s", got)
+	}
+}
+
+func TestMidColor(t *testing.T) {
+	tests := []struct {
+		stops []string
+		want  string
+	}{
+		{[]string{`<stop offset="0" stop-color="#f00"/>`, `<stop offset="100%" stop-color="rgb(0, 0, 255)"/>`}, "#800080"},
+		{[]string{`<stop offset="0" stop-color="#fff" stop-opacity="0"/>`, `<stop offset="1" stop-color="#fff"/>`}, "rgba(255,255,255,0.5)"},
+		{[]string{`<stop offset="0" stop-color="red"/>`, `<stop offset="0.8" stop-color="blue"/>`}, "blue"},
+		{[]string{`<stop offset="0.2" stop-color="#123456"/>`}, "#123456"},
+	}
+	for _, tc := range tests {
+		if got, ok := midColor(tc.stops); !ok || got != tc.want {
+			t.Errorf("midColor(%v) = %q, %v; want %q", tc.stops, got, ok, tc.want)
+		}
+	}
+}
@@ -6,13 +6,20 @@ package textmeasure
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"path/filepath"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
+	"unicode"
 
 	"github.com/go-text/typesetting/di"
 	"github.com/go-text/typesetting/font"
+	ot "github.com/go-text/typesetting/font/opentype"
 	"github.com/go-text/typesetting/fontscan"
 	"github.com/go-text/typesetting/language"
 	"github.com/go-text/typesetting/shaping"
@@ -25,10 +32,27 @@ type MeasurerOption func(*measurerConfig)
 
 type measurerConfig struct {
 	systemFonts    bool
+	noEmbedded     bool
 	fonts          []customFont
 	fallbackFamily string
+	metricsMode    MetricsMode
+	aliases        map[string]string // lower-case family → substitute
+	features       []string
+	fontParser     func(string) CSSFont
 }
 
+// MetricsMode selects how glyph advances are accumulated into a text width.
+type MetricsMode int
+
+const (
+	// MetricsFixedPoint sums the shaped advances in 26.6 fixed point, keeping
+	// sub-pixel precision like browser text layout. This is the default.
+	MetricsFixedPoint MetricsMode = iota
+	// MetricsRoundedGlyphs rounds each glyph advance to a whole pixel before
+	// summing, like node-canvas/Cairo with hinted metrics.
+	MetricsRoundedGlyphs
+)
+
 type customFont struct {
 	family string
 	data   []byte
@@ -41,6 +65,45 @@ func WithSystemFonts() MeasurerOption {
 	}
 }
 
+// WithoutEmbeddedFonts leaves the embedded Liberation fonts out, so only
+// system and custom fonts are used. New fails if that leaves no fonts.
+func WithoutEmbeddedFonts() MeasurerOption {
+	return func(c *measurerConfig) {
+		c.noEmbedded = true
+	}
+}
+
+// SystemFontFiles returns the font files (TrueType, OpenType and their
+// collections) in the OS font directories that WithSystemFonts scans, so
+// other renderers can load the same set. Unreadable directories are
+// skipped.
+func SystemFontFiles() ([]string, error) {
+	dirs, err := fontscan.DefaultFontDirectories(log.New(io.Discard, "", 0))
+	if err != nil {
+		return nil, fmt.Errorf("textmeasure: locating system fonts: %w", err)
+	}
+
+	var files []string
+	seen := make(map[string]bool)
+	for _, dir := range dirs {
+		_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil // skip unreadable entries
+			}
+			if d.IsDir() || seen[path] {
+				return nil
+			}
+			switch strings.ToLower(filepath.Ext(path)) {
+			case ".ttf", ".otf", ".ttc", ".otc":
+				seen[path] = true
+				files = append(files, path)
+			}
+			return nil
+		})
+	}
+	return files, nil
+}
+
 // WithFont registers a custom TTF font with the given family name.
 // Fonts added later take priority over earlier ones.
 func WithFont(family string, ttf []byte) MeasurerOption {
@@ -58,12 +121,74 @@ func WithDefaultFontFamily(family string) MeasurerOption {
 	}
 }
 
+// WithMetricsMode sets how glyph advances are summed. Defaults to
+// MetricsFixedPoint.
+func WithMetricsMode(mode MetricsMode) MeasurerOption {
+	return func(c *measurerConfig) {
+		c.metricsMode = mode
+	}
+}
+
+// WithFontAlias makes the family from resolve as to, so a spec naming a font
+// that is not installed (e.g. "Helvetica Neue") is measured with one that is.
+// Matching is case-insensitive.
+func WithFontAlias(from, to string) MeasurerOption {
+	return func(c *measurerConfig) {
+		if c.aliases == nil {
+			c.aliases = make(map[string]string)
+		}
+		c.aliases[strings.ToLower(from)] = to
+	}
+}
+
+// WithFontFeatures sets OpenType features for shaping, by tag: "tnum"
+// turns tabular figures on, "-kern" turns kerning off. Features not listed
+// keep the shaper's defaults (kerning and standard ligatures on).
+func WithFontFeatures(tags ...string) MeasurerOption {
+	return func(c *measurerConfig) {
+		c.features = append(c.features, tags...)
+	}
+}
+
+// WithFontParser parses CSS font strings with fn instead of ParseCSSFont,
+// for font shorthands the built-in parser does not handle. When fn returns
+// the zero CSSFont, the string is parsed with ParseCSSFont instead.
+func WithFontParser(fn func(s string) CSSFont) MeasurerOption {
+	return func(c *measurerConfig) {
+		c.fontParser = fn
+	}
+}
+
+// parseFontFeature parses a feature as accepted by WithFontFeatures.
+func parseFontFeature(s string) (shaping.FontFeature, error) {
+	tag, value := s, uint32(1)
+	if rest, ok := strings.CutPrefix(s, "-"); ok {
+		tag, value = rest, 0
+	}
+	if len(tag) != 4 {
+		return shaping.FontFeature{}, fmt.Errorf("textmeasure: invalid font feature %q: tags are 4 characters", s)
+	}
+	for i := range len(tag) {
+		if tag[i] < 0x20 || tag[i] > 0x7e {
+			return shaping.FontFeature{}, fmt.Errorf("textmeasure: invalid font feature %q: tags are printable ASCII", s)
+		}
+	}
+	return shaping.FontFeature{Tag: ot.MustNewTag(tag), Value: value}, nil
+}
+
 // Measurer computes text widths using HarfBuzz shaping.
 type Measurer struct {
 	mu             sync.Mutex
 	fontMap        *fontscan.FontMap
 	shaper         shaping.HarfbuzzShaper
 	fallbackFamily string
+	metricsMode    MetricsMode
+	aliases        map[string]string
+	features       []shaping.FontFeature
+	fontParser     func(string) CSSFont
+	systemFonts    bool
+	registered     map[string]bool // normalized embedded and custom families
+	available      map[string]bool // normalized family → found, memoized
 }
 
 // New creates a Measurer with embedded Liberation Sans fonts for
@@ -75,6 +200,15 @@ func New(opts ...MeasurerOption) (*Measurer, error) {
 		opt(&cfg)
 	}
 
+	features := make([]shaping.FontFeature, 0, len(cfg.features))
+	for _, f := range cfg.features {
+		feature, err := parseFontFeature(f)
+		if err != nil {
+			return nil, err
+		}
+		features = append(features, feature)
+	}
+
 	fm := fontscan.NewFontMap(nil)
 
 	// Register embedded Liberation fonts first (always-present fallback).
@@ -94,6 +228,9 @@ func New(opts ...MeasurerOption) (*Measurer, error) {
 		{liberation.MonoBoldItalic, "liberation-mono-bolditalic", "Liberation Mono"},
 	}
 
+	if cfg.noEmbedded {
+		embeddedFonts = nil
+	}
 	for _, f := range embeddedFonts {
 		if err := fm.AddFont(bytes.NewReader(f.data), f.id, f.family); err != nil {
 			return nil, fmt.Errorf("textmeasure: loading %s: %w", f.id, err)
@@ -115,12 +252,34 @@ func New(opts ...MeasurerOption) (*Measurer, error) {
 		}
 	}
 
+	if cfg.noEmbedded && !cfg.systemFonts && len(cfg.fonts) == 0 {
+		return nil, fmt.Errorf("textmeasure: no fonts to measure with (embedded fonts disabled and none registered)")
+	}
+
 	fallback := cfg.fallbackFamily
-	if fallback == "" {
+	if fallback == "" && !cfg.noEmbedded {
 		fallback = "Liberation Sans"
 	}
 
-	return &Measurer{fontMap: fm, fallbackFamily: fallback}, nil
+	registered := make(map[string]bool)
+	for _, f := range embeddedFonts {
+		registered[font.NormalizeFamily(f.family)] = true
+	}
+	for _, f := range cfg.fonts {
+		registered[font.NormalizeFamily(f.family)] = true
+	}
+
+	return &Measurer{
+		fontMap:        fm,
+		fallbackFamily: fallback,
+		metricsMode:    cfg.metricsMode,
+		aliases:        cfg.aliases,
+		features:       features,
+		fontParser:     cfg.fontParser,
+		systemFonts:    cfg.systemFonts,
+		registered:     registered,
+		available:      make(map[string]bool),
+	}, nil
 }
 
 // CSSFont represents a parsed CSS font shorthand string.
@@ -131,21 +290,90 @@ type CSSFont struct {
 	Family []string
 }
 
+func (f CSSFont) isZero() bool {
+	return f.Style == 0 && f.Weight == 0 && f.Size == 0 && len(f.Family) == 0
+}
+
+// ParseFont parses a CSS font string the way the Measurer does when
+// measuring it: with the WithFontParser function, if any, falling back to
+// ParseCSSFont.
+func (m *Measurer) ParseFont(cssFont string) CSSFont {
+	if m.fontParser != nil {
+		if parsed := m.fontParser(cssFont); !parsed.isZero() {
+			return parsed
+		}
+	}
+	return ParseCSSFont(cssFont)
+}
+
+// SetDefaultFontFamily replaces the fallback family set with
+// WithDefaultFontFamily, for the measurements that follow, and returns the
+// previous one.
+func (m *Measurer) SetDefaultFontFamily(family string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	prev := m.fallbackFamily
+	m.fallbackFamily = family
+	return prev
+}
+
 // MeasureText returns the width in pixels of the given text rendered with
 // the specified CSS font string.
 func (m *Measurer) MeasureText(text, cssFont string) float64 {
-	parsed := ParseCSSFont(cssFont)
+	parsed := m.ParseFont(cssFont)
 	if len(text) == 0 {
 		return 0
 	}
 
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	m.setQuery(parsed)
+
+	runes := []rune(text)
+	input := shaping.Input{
+		Text:         runes,
+		RunStart:     0,
+		RunEnd:       len(runes),
+		Direction:    di.DirectionLTR,
+		FontFeatures: m.features,
+		Size:         fixed.Int26_6(parsed.Size * 64),
+		Script:       language.Latin,
+		Language:     language.NewLanguage("en"),
+	}
+
+	// Split by font face for proper fallback handling.
+	splits := shaping.SplitByFace(input, m.fontMap)
+
+	var totalAdvance fixed.Int26_6
+	for _, split := range splits {
+		out := m.shaper.Shape(split)
+		if m.metricsMode == MetricsRoundedGlyphs {
+			for _, g := range out.Glyphs {
+				totalAdvance += fixed.I(g.XAdvance.Round())
+			}
+			continue
+		}
+		totalAdvance += out.Advance
+	}
 
+	return float64(totalAdvance) / 64.0
+}
+
+// setQuery points the font map at the families of parsed, aliased and
+// followed by the fallbacks. m.mu must be held.
+func (m *Measurer) setQuery(parsed CSSFont) {
 	families := make([]string, 0, len(parsed.Family)+2)
-	families = append(families, parsed.Family...)
+	for _, f := range parsed.Family {
+		if to, ok := m.aliases[strings.ToLower(f)]; ok {
+			f = to
+		}
+		families = append(families, f)
+	}
 	// Always add the configured fallback font family.
-	families = append(families, m.fallbackFamily, fontscan.SansSerif)
+	if m.fallbackFamily != "" {
+		families = append(families, m.fallbackFamily)
+	}
+	families = append(families, fontscan.SansSerif)
 
 	m.fontMap.SetQuery(fontscan.Query{
 		Families: families,
@@ -155,28 +383,74 @@ func (m *Measurer) MeasureText(text, cssFont string) float64 {
 		},
 	})
 	m.fontMap.SetScript(language.Latin)
+}
 
-	runes := []rune(text)
-	input := shaping.Input{
-		Text:      runes,
-		RunStart:  0,
-		RunEnd:    len(runes),
-		Direction: di.DirectionLTR,
-		Size:      fixed.Int26_6(parsed.Size * 64),
-		Script:    language.Latin,
-		Language:  language.NewLanguage("en"),
-	}
+// genericFamilies are the CSS generic family keywords, which always resolve
+// to some font.
+var genericFamilies = map[string]bool{
+	"serif": true, "sans-serif": true, "monospace": true, "cursive": true,
+	"fantasy": true, "system-ui": true, "ui-serif": true, "ui-sans-serif": true,
+	"ui-monospace": true, "ui-rounded": true, "emoji": true, "math": true,
+	"fangsong": true,
+}
 
-	// Split by font face for proper fallback handling.
-	splits := shaping.SplitByFace(input, m.fontMap)
+// MissingFonts reports what measuring text in cssFont had to substitute:
+// the families cssFont names, other than generic ones, that are neither
+// embedded, registered with WithFont nor (under WithSystemFonts) installed,
+// after WithFontAlias substitution; and the runes of text that no font has a
+// glyph for, which render as missing-glyph boxes.
+func (m *Measurer) MissingFonts(text, cssFont string) (families []string, runes []rune) {
+	parsed := m.ParseFont(cssFont)
 
-	var totalAdvance fixed.Int26_6
-	for _, split := range splits {
-		out := m.shaper.Shape(split)
-		totalAdvance += out.Advance
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, f := range parsed.Family {
+		if genericFamilies[strings.ToLower(f)] {
+			continue
+		}
+		family := f
+		if to, ok := m.aliases[strings.ToLower(f)]; ok {
+			family = to
+		}
+		if !m.familyAvailable(family) {
+			families = append(families, f)
+		}
 	}
 
-	return float64(totalAdvance) / 64.0
+	m.setQuery(parsed)
+	for _, r := range text {
+		if unicode.IsSpace(r) || unicode.IsControl(r) || slices.Contains(runes, r) {
+			continue
+		}
+		face := m.fontMap.ResolveFace(r)
+		if face == nil {
+			runes = append(runes, r)
+			continue
+		}
+		if _, ok := face.NominalGlyph(r); !ok {
+			runes = append(runes, r)
+		}
+	}
+	return families, runes
+}
+
+// familyAvailable reports whether a font of the family is loaded or, with
+// system fonts enabled, installed. m.mu must be held.
+func (m *Measurer) familyAvailable(family string) bool {
+	family = font.NormalizeFamily(family)
+	if m.registered[family] {
+		return true
+	}
+	if !m.systemFonts {
+		return false
+	}
+	ok, seen := m.available[family]
+	if !seen {
+		_, ok = m.fontMap.FindSystemFont(family)
+		m.available[family] = ok
+	}
+	return ok
 }
 
 // cssFontRe matches CSS font shorthand: [style] [weight] size[px|em] family[, family...]
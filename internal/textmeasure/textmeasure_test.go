@@ -1,9 +1,14 @@
 package textmeasure
 
 import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/go-text/typesetting/font"
+	"github.com/mgilbir/aster/internal/textmeasure/fonts/dejavu"
 )
 
 func TestParseCSSFont(t *testing.T) {
@@ -107,3 +112,209 @@ func TestMeasureText(t *testing.T) {
 		t.Errorf("empty text should be 0, got %v", w4)
 	}
 }
+
+func TestMetricsModeRoundedGlyphs(t *testing.T) {
+	fixedPoint, err := New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	rounded, err := New(WithMetricsMode(MetricsRoundedGlyphs))
+	if err != nil {
+		t.Fatalf("New rounded: %v", err)
+	}
+
+	const text = "Hello, World!"
+	wf := fixedPoint.MeasureText(text, "11px sans-serif")
+	wr := rounded.MeasureText(text, "11px sans-serif")
+
+	if wr != math.Trunc(wr) {
+		t.Errorf("rounded-glyph width should be a whole number of pixels, got %v", wr)
+	}
+	// Each glyph moves by at most half a pixel.
+	if diff := math.Abs(wr - wf); diff > float64(len(text))/2 {
+		t.Errorf("rounded width %v too far from fixed-point width %v", wr, wf)
+	}
+}
+
+func TestMeasureEllipsis(t *testing.T) {
+	m, err := New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	const font = "11px sans-serif"
+	ellipsis := m.MeasureText("…", font)
+	dots := m.MeasureText(".", font)
+	// U+2026 is a single glyph in Liberation Sans, roughly three periods
+	// wide — not a .notdef box or a zero advance.
+	if ellipsis < 2*dots || ellipsis > 4*dots {
+		t.Errorf("ellipsis width %v not in expected range for period width %v", ellipsis, dots)
+	}
+
+	// Vega truncates by measuring the prefix and the ellipsis separately;
+	// the sum must match the width of the label it finally draws.
+	prefix := m.MeasureText("Long category la", font)
+	whole := m.MeasureText("Long category la…", font)
+	if diff := math.Abs(prefix + ellipsis - whole); diff > 0.5 {
+		t.Errorf("prefix+ellipsis %v differs from truncated label %v by %v", prefix+ellipsis, whole, diff)
+	}
+}
+
+func TestFontAlias(t *testing.T) {
+	plain, err := New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	aliased, err := New(WithFontAlias("Helvetica Neue", "Liberation Mono"))
+	if err != nil {
+		t.Fatalf("New aliased: %v", err)
+	}
+
+	const text = "iiiii"
+	mono := plain.MeasureText(text, "12px 'Liberation Mono'")
+	if got := aliased.MeasureText(text, "12px 'helvetica neue'"); got != mono {
+		t.Errorf("aliased width %v, want Liberation Mono width %v", got, mono)
+	}
+	// Without the alias the unknown family falls back to Liberation Sans.
+	if got := plain.MeasureText(text, "12px 'Helvetica Neue'"); got == mono {
+		t.Errorf("expected unaliased family not to resolve to Liberation Mono")
+	}
+}
+
+func TestFontParser(t *testing.T) {
+	plain, err := New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	custom, err := New(WithFontParser(func(s string) CSSFont {
+		if s != "small-caps 12px/1.5 Arial" {
+			return CSSFont{}
+		}
+		return CSSFont{Style: font.StyleNormal, Weight: font.WeightBold, Size: 12, Family: []string{"Arial"}}
+	}))
+	if err != nil {
+		t.Fatalf("New custom: %v", err)
+	}
+
+	const text = "Hello, world"
+	want := plain.MeasureText(text, "bold 12px Arial")
+	if got := custom.MeasureText(text, "small-caps 12px/1.5 Arial"); got != want {
+		t.Errorf("custom-parsed width %v, want %v", got, want)
+	}
+	// A zero CSSFont falls back to the built-in parser.
+	want = plain.MeasureText(text, "italic 14px Arial")
+	if got := custom.MeasureText(text, "italic 14px Arial"); got != want {
+		t.Errorf("fallback width %v, want %v", got, want)
+	}
+	if got := custom.ParseFont("small-caps 12px/1.5 Arial"); got.Weight != font.WeightBold || len(got.Family) != 1 {
+		t.Errorf("ParseFont should use the custom parser, got %+v", got)
+	}
+}
+
+func TestSetDefaultFontFamily(t *testing.T) {
+	m, err := New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	const text, font = "iiiii", "12px 'Unknown Font'"
+	sans := m.MeasureText(text, font)
+	if prev := m.SetDefaultFontFamily("Liberation Mono"); prev != "Liberation Sans" {
+		t.Errorf("SetDefaultFontFamily returned %q, want Liberation Sans", prev)
+	}
+	mono := m.MeasureText(text, "12px 'Liberation Mono'")
+	if got := m.MeasureText(text, font); got != mono {
+		t.Errorf("width with Liberation Mono default %v, want %v", got, mono)
+	}
+	m.SetDefaultFontFamily("Liberation Sans")
+	if got := m.MeasureText(text, font); got != sans {
+		t.Errorf("width after restoring the default %v, want %v", got, sans)
+	}
+}
+
+func TestMissingFonts(t *testing.T) {
+	m, err := New(WithFontAlias("Helvetica Neue", "Liberation Sans"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	families, runes := m.MissingFonts("Tokyo 東京", "bold 12px 'Unknown Font', \"Helvetica Neue\", liberation sans, sans-serif")
+	if strings.Join(families, ",") != "Unknown Font" {
+		t.Errorf("missing families = %q, want [Unknown Font]", families)
+	}
+	if string(runes) != "東京" {
+		t.Errorf("missing runes = %q, want 東京", string(runes))
+	}
+
+	families, runes = m.MissingFonts("Tokyo", "12px sans-serif")
+	if families != nil || runes != nil {
+		t.Errorf("expected nothing missing for Latin text in a generic family, got %q, %q", families, string(runes))
+	}
+}
+
+func TestWithoutEmbeddedFonts(t *testing.T) {
+	if _, err := New(WithoutEmbeddedFonts()); err == nil {
+		t.Error("expected an error with no fonts at all")
+	}
+
+	plain, err := New(WithFont("DejaVu Sans", dejavu.SansRegular))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	strict, err := New(WithoutEmbeddedFonts(), WithFont("DejaVu Sans", dejavu.SansRegular))
+	if err != nil {
+		t.Fatalf("New strict: %v", err)
+	}
+
+	const text = "Hello, World!"
+	dejaVu := plain.MeasureText(text, "12px 'DejaVu Sans'")
+	// Liberation Sans is not there to resolve to, so the only font is used.
+	if got := strict.MeasureText(text, "12px 'Liberation Sans'"); got != dejaVu {
+		t.Errorf("expected Liberation Sans to fall back to DejaVu Sans (%v), got %v", dejaVu, got)
+	}
+	if got := plain.MeasureText(text, "12px 'Liberation Sans'"); got == dejaVu {
+		t.Error("expected the embedded Liberation Sans to be used by default")
+	}
+}
+
+func TestSystemFontFiles(t *testing.T) {
+	files, err := SystemFontFiles()
+	if err != nil {
+		t.Skipf("no system font directories: %v", err)
+	}
+	for _, f := range files {
+		switch ext := strings.ToLower(filepath.Ext(f)); ext {
+		case ".ttf", ".otf", ".ttc", ".otc":
+		default:
+			t.Errorf("unexpected font file extension %q: %s", ext, f)
+		}
+		if _, err := os.Stat(f); err != nil {
+			t.Errorf("listed font file does not exist: %v", err)
+		}
+	}
+}
+
+func TestFontFeatures(t *testing.T) {
+	kerned, err := New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	unkerned, err := New(WithFontFeatures("-kern"))
+	if err != nil {
+		t.Fatalf("New -kern: %v", err)
+	}
+
+	// Liberation Sans kerns the "AV" pair tighter than its advances.
+	const text = "AVAVAV"
+	wk := kerned.MeasureText(text, "20px sans-serif")
+	wu := unkerned.MeasureText(text, "20px sans-serif")
+	if wk >= wu {
+		t.Errorf("kerning should narrow %q: kerned %v, unkerned %v", text, wk, wu)
+	}
+
+	for _, bad := range []string{"kerning", "-ab", ""} {
+		if _, err := New(WithFontFeatures(bad)); err == nil || !strings.Contains(err.Error(), "invalid font feature") {
+			t.Errorf("feature %q: expected an invalid font feature error, got %v", bad, err)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package aster
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/mgilbir/aster/internal/svgdoc"
+)
+
+// VegaLiteToLegendSVG renders only the legends of a Vega-Lite spec (JSON) to
+// an SVG string, for placing a shared legend beside several charts. The chart
+// is laid out as usual, every other mark is dropped from the scenegraph, and
+// the SVG is cropped to the legends' bounds, rounded out to whole pixels.
+// It fails if the spec produces no legend. Render options apply as in
+// VegaLiteToSVG.
+func (c *Converter) VegaLiteToLegendSVG(spec []byte, opts ...RenderOption) (string, error) {
+	opts = c.withSource(opts, spec)
+	defer c.useRenderOptions(opts)()
+	start := time.Now()
+	spec, err := c.prepareVegaLite(spec)
+	if err != nil {
+		return "", err
+	}
+	if err := c.prefetchVegaLite(spec); err != nil {
+		return "", err
+	}
+	defer c.clearPrefetched()
+	result, err := c.rt.VegaLiteToLegendSVG(string(spec))
+	if err != nil {
+		return "", err
+	}
+
+	var legend struct {
+		SVG    string     `json:"svg"`
+		Bounds [4]float64 `json:"bounds"`
+	}
+	if err := json.Unmarshal([]byte(result), &legend); err != nil {
+		return "", fmt.Errorf("aster: decoding legend: %w", err)
+	}
+	x, y := math.Floor(legend.Bounds[0]), math.Floor(legend.Bounds[1])
+	w, h := math.Ceil(legend.Bounds[2])-x, math.Ceil(legend.Bounds[3])-y
+	svg := svgdoc.Crop(legend.SVG, x, y, w, h)
+
+	if err := c.checkSVGSize(svg); err != nil {
+		return "", err
+	}
+	svg, err = c.postProcessSVG(svg, defaultRenderConfig(opts))
+	if err != nil {
+		return "", err
+	}
+	c.reportMetrics(start, 0)
+	return svg, nil
+}
@@ -0,0 +1,309 @@
+package aster
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+// LintSeverity ranks a LintFinding.
+type LintSeverity int
+
+const (
+	// LintInfo marks a pattern that is often intended but worth knowing
+	// about, such as data of unknown size.
+	LintInfo LintSeverity = iota + 1
+	// LintWarning marks a pattern that likely makes the chart slow to
+	// render or hard to read.
+	LintWarning
+)
+
+func (s LintSeverity) String() string {
+	switch s {
+	case LintInfo:
+		return "info"
+	case LintWarning:
+		return "warning"
+	default:
+		return fmt.Sprintf("LintSeverity(%d)", int(s))
+	}
+}
+
+// LintFinding is one issue reported by LintVegaLite.
+type LintFinding struct {
+	// Code identifies the check: "large-inline-data", "unsampled-url-data",
+	// "high-cardinality-color" or "small-step-size".
+	Code     string
+	Message  string
+	Severity LintSeverity
+}
+
+// Lint thresholds.
+const (
+	lintMaxInlineRows     = 5000 // inline rows drawn without sample or aggregate
+	lintMaxColorDomain    = 20   // distinct values of a categorical color scale
+	lintMinStepChartPixel = 100  // width or height of a step-sized chart
+)
+
+// LintVegaLite compiles a Vega-Lite spec (JSON) and checks the compiled Vega
+// data and scale definitions for common performance and sizing pitfalls:
+//
+//   - large-inline-data (warning): more than 5000 inline rows that no sample
+//     or aggregate transform reduces, so every row becomes a mark.
+//   - unsampled-url-data (info): data loaded from a URL that no sample or
+//     aggregate transform reduces, so its size is unbounded.
+//   - high-cardinality-color (warning): a categorical color scale over more
+//     than 20 values, whose colors repeat or are hard to tell apart.
+//   - small-step-size (warning): a width or height left to Vega-Lite's
+//     default step that comes to under 100px for the data's categories.
+//
+// Category counts come from inline data only. The spec is compiled but not
+// rendered, so no data is loaded. A spec with no findings yields an empty
+// slice; an error means the spec did not compile.
+func (c *Converter) LintVegaLite(spec []byte) ([]LintFinding, error) {
+	vgSpec, err := c.compileVegaLite(spec)
+	if err != nil {
+		return nil, err
+	}
+	vg, err := decodeSpec(vgSpec)
+	if err != nil {
+		return nil, err
+	}
+	return lintVega(vg), nil
+}
+
+// lintVega runs the LintVegaLite checks on a compiled Vega spec.
+func lintVega(vg map[string]any) []LintFinding {
+	l := newLinter(vg)
+	findings := []LintFinding{}
+	findings = append(findings, l.dataSizes()...)
+	findings = append(findings, l.colorScales()...)
+	findings = append(findings, l.stepSizes()...)
+	return findings
+}
+
+// linter indexes the datasets and scales of a compiled Vega spec.
+type linter struct {
+	vg       map[string]any
+	datasets map[string]map[string]any // dataset name → definition
+	names    []string                  // dataset names, in declaration order
+	children map[string][]string       // dataset name → datasets sourced from it
+	scales   map[string]map[string]any // scale name → definition, all scopes
+	scaleSeq []string                  // scale names, in declaration order
+}
+
+func newLinter(vg map[string]any) *linter {
+	l := &linter{
+		vg:       vg,
+		datasets: make(map[string]map[string]any),
+		children: make(map[string][]string),
+		scales:   make(map[string]map[string]any),
+	}
+	var walk func(scope map[string]any)
+	walk = func(scope map[string]any) {
+		data, _ := scope["data"].([]any)
+		for _, d := range data {
+			def, _ := d.(map[string]any)
+			name, _ := def["name"].(string)
+			if name == "" {
+				continue
+			}
+			l.datasets[name] = def
+			l.names = append(l.names, name)
+			if src, ok := def["source"].(string); ok {
+				l.children[src] = append(l.children[src], name)
+			}
+		}
+		scales, _ := scope["scales"].([]any)
+		for _, s := range scales {
+			def, _ := s.(map[string]any)
+			name, _ := def["name"].(string)
+			if _, seen := l.scales[name]; name == "" || seen {
+				continue
+			}
+			l.scales[name] = def
+			l.scaleSeq = append(l.scaleSeq, name)
+		}
+		marks, _ := scope["marks"].([]any)
+		for _, m := range marks {
+			if mark, ok := m.(map[string]any); ok && mark["type"] == "group" {
+				walk(mark)
+			}
+		}
+	}
+	walk(vg)
+	return l
+}
+
+// reduced reports whether dataset name, or a dataset derived from it, has a
+// sample or aggregate transform.
+func (l *linter) reduced(name string) bool {
+	transforms, _ := l.datasets[name]["transform"].([]any)
+	for _, t := range transforms {
+		tr, _ := t.(map[string]any)
+		if tr["type"] == "sample" || tr["type"] == "aggregate" {
+			return true
+		}
+	}
+	for _, child := range l.children[name] {
+		if l.reduced(child) {
+			return true
+		}
+	}
+	return false
+}
+
+// rows returns the inline values at the root of dataset name's source chain,
+// or nil if they are not inline.
+func (l *linter) rows(name string) []any {
+	for range len(l.names) {
+		def := l.datasets[name]
+		src, ok := def["source"].(string)
+		if !ok {
+			rows, _ := def["values"].([]any)
+			return rows
+		}
+		name = src
+	}
+	return nil // a source cycle
+}
+
+func (l *linter) dataSizes() []LintFinding {
+	var findings []LintFinding
+	for _, name := range l.names {
+		def := l.datasets[name]
+		if _, derived := def["source"]; derived || l.reduced(name) {
+			continue
+		}
+		if rows, ok := def["values"].([]any); ok && len(rows) > lintMaxInlineRows {
+			findings = append(findings, LintFinding{
+				Code:     "large-inline-data",
+				Message:  fmt.Sprintf("dataset %q has %d inline rows and no sample or aggregate transform; every row becomes a mark", name, len(rows)),
+				Severity: LintWarning,
+			})
+		}
+		if url, ok := def["url"]; ok {
+			from := "from a URL"
+			if s, ok := url.(string); ok {
+				from = fmt.Sprintf("%q", s)
+			}
+			findings = append(findings, LintFinding{
+				Code:     "unsampled-url-data",
+				Message:  fmt.Sprintf("dataset %q loads %s with no sample or aggregate transform; its size is unbounded", name, from),
+				Severity: LintInfo,
+			})
+		}
+	}
+	return findings
+}
+
+// domainSize counts the distinct inline values of the fields a scale's
+// domain is drawn from. It reports false if the domain is not data-driven or
+// any of its fields is missing from inline data.
+func (l *linter) domainSize(scale map[string]any) (int, bool) {
+	domain, _ := scale["domain"].(map[string]any)
+	if domain == nil {
+		return 0, false
+	}
+	refs := []any{domain}
+	if fields, ok := domain["fields"].([]any); ok {
+		refs = fields
+	}
+	distinct := make(map[string]bool)
+	for _, r := range refs {
+		ref, _ := r.(map[string]any)
+		data, _ := ref["data"].(string)
+		if data == "" {
+			data, _ = domain["data"].(string)
+		}
+		field, _ := ref["field"].(string)
+		rows := l.rows(data)
+		if field == "" || rows == nil {
+			return 0, false
+		}
+		for _, row := range rows {
+			obj, _ := row.(map[string]any)
+			v, ok := obj[field]
+			if !ok {
+				return 0, false
+			}
+			distinct[fmt.Sprintf("%T:%v", v, v)] = true
+		}
+	}
+	return len(distinct), true
+}
+
+// colorChannels names the scales Vega-Lite compiles color encodings to.
+var colorChannels = map[string]bool{"color": true, "fill": true, "stroke": true}
+
+func (l *linter) colorScales() []LintFinding {
+	var findings []LintFinding
+	for _, name := range l.scaleSeq {
+		scale := l.scales[name]
+		if !colorChannels[name] || scale["type"] != "ordinal" {
+			continue
+		}
+		n, ok := l.domainSize(scale)
+		if !ok || n <= lintMaxColorDomain {
+			continue
+		}
+		findings = append(findings, LintFinding{
+			Code:     "high-cardinality-color",
+			Message:  fmt.Sprintf("color scale %q has %d categories; colors repeat or become hard to tell apart above %d", name, n, lintMaxColorDomain),
+			Severity: LintWarning,
+		})
+	}
+	return findings
+}
+
+// stepSize matches the width or height signal Vega-Lite compiles for a
+// discrete axis with no explicit size: the band count times the step.
+var stepSize = regexp.MustCompile(`^bandspace\(domain\('([^']+)'\)\.length, ([\d.]+), ([\d.]+)\) \* (\w+)$`)
+
+func (l *linter) stepSizes() []LintFinding {
+	signals, _ := l.vg["signals"].([]any)
+	values := make(map[string]float64)
+	for _, s := range signals {
+		sig, _ := s.(map[string]any)
+		name, _ := sig["name"].(string)
+		if n, ok := sig["value"].(json.Number); ok {
+			if v, err := n.Float64(); err == nil {
+				values[name] = v
+			}
+		}
+	}
+
+	var findings []LintFinding
+	for _, dim := range []string{"width", "height"} {
+		for _, s := range signals {
+			sig, _ := s.(map[string]any)
+			update, _ := sig["update"].(string)
+			m := stepSize.FindStringSubmatch(update)
+			if sig["name"] != dim || m == nil {
+				continue
+			}
+			step, ok := values[m[4]]
+			scale := l.scales[m[1]]
+			if !ok || scale == nil {
+				continue
+			}
+			n, ok := l.domainSize(scale)
+			if !ok {
+				continue
+			}
+			var inner, outer float64
+			_, _ = fmt.Sscan(m[2], &inner)
+			_, _ = fmt.Sscan(m[3], &outer)
+			px := (float64(n) - inner + 2*outer) * step
+			if px >= lintMinStepChartPixel {
+				continue
+			}
+			findings = append(findings, LintFinding{
+				Code:     "small-step-size",
+				Message:  fmt.Sprintf("chart %s is about %.0fpx: %d categories at the default step of %gpx; set %s or a larger step", dim, px, n, step, dim),
+				Severity: LintWarning,
+			})
+		}
+	}
+	return findings
+}
@@ -0,0 +1,83 @@
+package aster_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mgilbir/aster"
+)
+
+func lintCodes(findings []aster.LintFinding) map[string]aster.LintSeverity {
+	codes := make(map[string]aster.LintSeverity, len(findings))
+	for _, f := range findings {
+		codes[f.Code] = f.Severity
+	}
+	return codes
+}
+
+func TestLintVegaLite(t *testing.T) {
+	c, err := aster.New(aster.WithTextMeasurement(false))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	// 30 colors over two x categories: a crowded color scale on a chart
+	// only two steps wide.
+	var rows []string
+	for i := range 30 {
+		rows = append(rows, fmt.Sprintf(`{"a": "%s", "b": %d, "k": "k%d"}`, []string{"x", "y"}[i%2], i, i))
+	}
+	spec := []byte(`{
+		"data": {"values": [` + strings.Join(rows, ",") + `]},
+		"mark": "bar",
+		"encoding": {
+			"x": {"field": "a", "type": "nominal"},
+			"y": {"field": "b", "type": "quantitative"},
+			"color": {"field": "k", "type": "nominal"}
+		}
+	}`)
+	findings, err := c.LintVegaLite(spec)
+	if err != nil {
+		t.Fatalf("LintVegaLite: %v", err)
+	}
+	codes := lintCodes(findings)
+	if codes["high-cardinality-color"] != aster.LintWarning {
+		t.Errorf("expected a high-cardinality-color warning, got %+v", findings)
+	}
+	if codes["small-step-size"] != aster.LintWarning {
+		t.Errorf("expected a small-step-size warning, got %+v", findings)
+	}
+	if _, ok := codes["unsampled-url-data"]; ok {
+		t.Errorf("inline data should not be reported as URL data: %+v", findings)
+	}
+
+	url := []byte(`{
+		"data": {"url": "cars.json"},
+		"mark": "point",
+		"width": 300,
+		"encoding": {"x": {"field": "Horsepower", "type": "quantitative"}}
+	}`)
+	findings, err = c.LintVegaLite(url)
+	if err != nil {
+		t.Fatalf("LintVegaLite: %v", err)
+	}
+	if codes := lintCodes(findings); len(findings) != 1 || codes["unsampled-url-data"] != aster.LintInfo {
+		t.Errorf("expected only an unsampled-url-data info, got %+v", findings)
+	}
+
+	sampled := []byte(`{
+		"data": {"url": "cars.json"},
+		"transform": [{"sample": 500}],
+		"mark": "point",
+		"encoding": {"x": {"field": "Horsepower", "type": "quantitative"}}
+	}`)
+	findings, err = c.LintVegaLite(sampled)
+	if err != nil {
+		t.Fatalf("LintVegaLite: %v", err)
+	}
+	if len(findings) != 0 {
+		t.Errorf("expected no findings for sampled data, got %+v", findings)
+	}
+}
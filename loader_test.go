@@ -2,13 +2,21 @@ package aster_test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"os"
 	"path/filepath"
+	"reflect"
+	"regexp"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/mgilbir/aster"
 )
@@ -193,6 +201,334 @@ func TestHTTPLoaderIntegration(t *testing.T) {
 	}
 }
 
+func TestHTTPLoaderCharset(t *testing.T) {
+	latin1 := []byte("city,temp\nMontr\xe9al,3\n")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/declared.csv" {
+			w.Header().Set("Content-Type", "text/csv; charset=iso-8859-1")
+		}
+		_, _ = w.Write(latin1)
+	}))
+	defer ts.Close()
+
+	ctx := context.Background()
+	for _, tc := range []struct {
+		charset, path, want string
+	}{
+		{"", "/data.csv", string(latin1)},
+		{"windows-1252", "/data.csv", "city,temp\nMontréal,3\n"},
+		{"auto", "/data.csv", "city,temp\nMontréal,3\n"},
+		{"auto", "/declared.csv", "city,temp\nMontréal,3\n"},
+	} {
+		l := &aster.HTTPLoader{Client: ts.Client(), Charset: tc.charset}
+		data, err := l.Load(ctx, ts.URL+tc.path)
+		if err != nil {
+			t.Fatalf("Load %s with Charset %q: %v", tc.path, tc.charset, err)
+		}
+		if string(data) != tc.want {
+			t.Errorf("Load %s with Charset %q = %q, want %q", tc.path, tc.charset, data, tc.want)
+		}
+	}
+
+	l := &aster.HTTPLoader{Client: ts.Client(), Charset: "no-such-charset"}
+	_, err := l.Load(ctx, ts.URL+"/data.csv")
+	var le *aster.LoadError
+	if !errors.As(err, &le) || le.Kind != aster.LoadDenied {
+		t.Errorf("expected a LoadDenied error for an unknown charset, got %v", err)
+	}
+}
+
+func TestNewHTTPLoaderFromEnv(t *testing.T) {
+	t.Setenv("ASTER_ALLOWED_DOMAINS", "example.com, cdn.example.org,")
+	t.Setenv("ASTER_BASE_URL", "https://example.com/data/")
+	t.Setenv("ASTER_HTTP_TIMEOUT", "1500ms")
+	t.Setenv("ASTER_MAX_REDIRECTS", "3")
+	t.Setenv("ASTER_CHARSET", "auto")
+
+	l, err := aster.NewHTTPLoaderFromEnv()
+	if err != nil {
+		t.Fatalf("NewHTTPLoaderFromEnv: %v", err)
+	}
+	if !reflect.DeepEqual(l.AllowedDomains, []string{"example.com", "cdn.example.org"}) {
+		t.Errorf("AllowedDomains = %q", l.AllowedDomains)
+	}
+	if l.BaseURL != "https://example.com/data/" || l.Timeout != 1500*time.Millisecond || l.MaxRedirects != 3 || l.Charset != "auto" {
+		t.Errorf("unexpected loader: %+v", l)
+	}
+	if l.Client != http.DefaultClient {
+		t.Errorf("expected http.DefaultClient")
+	}
+
+	t.Setenv("ASTER_MAX_REDIRECTS", "0")
+	if l, err := aster.NewHTTPLoaderFromEnv(); err != nil || l.MaxRedirects >= 0 {
+		t.Errorf("ASTER_MAX_REDIRECTS=0 should refuse redirects, got %+v, %v", l, err)
+	}
+
+	for name, value := range map[string]string{
+		"ASTER_ALLOWED_DOMAINS": "example.com:8080",
+		"ASTER_BASE_URL":        "/data/",
+		"ASTER_HTTP_TIMEOUT":    "10",
+		"ASTER_MAX_REDIRECTS":   "-1",
+		"ASTER_CHARSET":         "no-such-charset",
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(name, value)
+			if _, err := aster.NewHTTPLoaderFromEnv(); err == nil || !strings.Contains(err.Error(), name) {
+				t.Errorf("expected an error naming %s for %q, got %v", name, value, err)
+			}
+		})
+	}
+}
+
+// roundTripFunc adapts a function to http.RoundTripper.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestHTTPLoaderUsesCustomTransport(t *testing.T) {
+	var calls int
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader(`[{"a":1}]`)),
+			Request:    req,
+		}, nil
+	})
+
+	l := aster.NewHTTPLoader(&http.Client{Transport: transport})
+	data, err := l.Load(context.Background(), "https://example.com/data.json")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected custom RoundTripper to be invoked once, got %d", calls)
+	}
+	if string(data) != `[{"a":1}]` {
+		t.Errorf("unexpected data: %s", data)
+	}
+}
+
+func TestHTTPLoaderRespectsRedirectPolicy(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old.json" {
+			http.Redirect(w, r, "/new.json", http.StatusFound)
+			return
+		}
+		_, _ = fmt.Fprint(w, `"new"`)
+	}))
+	defer ts.Close()
+
+	client := ts.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	l := aster.NewHTTPLoader(client)
+
+	_, err := l.Load(context.Background(), ts.URL+"/old.json")
+	if err == nil || !strings.Contains(err.Error(), "HTTP 302") {
+		t.Fatalf("expected the client's redirect policy to stop at the 302, got %v", err)
+	}
+}
+
+func TestHTTPLoaderMaxRedirects(t *testing.T) {
+	// /hop/N redirects to /hop/N-1; /hop/0 serves the data.
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/hop/"))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		if n > 0 {
+			http.Redirect(w, r, fmt.Sprintf("/hop/%d", n-1), http.StatusFound)
+			return
+		}
+		_, _ = fmt.Fprint(w, `"end"`)
+	}))
+	defer ts.Close()
+
+	l := &aster.HTTPLoader{Client: ts.Client(), MaxRedirects: 3}
+	data, err := l.Load(context.Background(), ts.URL+"/hop/3")
+	if err != nil {
+		t.Fatalf("expected 3 redirects to be followed, got %v", err)
+	}
+	if string(data) != `"end"` {
+		t.Errorf("unexpected data: %s", data)
+	}
+
+	_, err = l.Load(context.Background(), ts.URL+"/hop/4")
+	var le *aster.LoadError
+	if !errors.As(err, &le) || le.Kind != aster.LoadDenied {
+		t.Fatalf("expected a LoadDenied error past MaxRedirects, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "MaxRedirects") {
+		t.Errorf("expected the error to name MaxRedirects, got %v", err)
+	}
+	l.MaxRedirects = -1
+	_, err = l.Load(context.Background(), ts.URL+"/hop/1")
+	if !errors.As(err, &le) || le.Kind != aster.LoadDenied {
+		t.Fatalf("expected negative MaxRedirects to refuse the redirect, got %v", err)
+	}
+	if ts.Client().CheckRedirect != nil {
+		t.Error("the loader must not modify its Client")
+	}
+}
+
+func TestHTTPLoaderMaxRedirectsChecksDomains(t *testing.T) {
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/away.json" {
+			// Same server, but under a host name that is not allowed.
+			u, _ := url.Parse(ts.URL)
+			http.Redirect(w, r, "http://localhost:"+u.Port()+"/data.json", http.StatusFound)
+			return
+		}
+		_, _ = fmt.Fprint(w, `"data"`)
+	}))
+	defer ts.Close()
+
+	// AllowedDomains is enforced on every hop whether or not MaxRedirects
+	// is set.
+	for _, n := range []int{0, 5} {
+		l := &aster.HTTPLoader{Client: ts.Client(), AllowedDomains: []string{"127.0.0.1"}, MaxRedirects: n}
+		_, err := l.Load(context.Background(), ts.URL+"/away.json")
+		var le *aster.LoadError
+		if !errors.As(err, &le) || le.Kind != aster.LoadDenied || !strings.Contains(err.Error(), "localhost") {
+			t.Fatalf("MaxRedirects %d: expected the redirect to localhost to be denied, got %v", n, err)
+		}
+	}
+	if ts.Client().CheckRedirect != nil {
+		t.Error("the loader must not modify its Client")
+	}
+}
+
+func TestNewHTTPLoaderNilClient(t *testing.T) {
+	if l := aster.NewHTTPLoader(nil); l.Client != http.DefaultClient {
+		t.Errorf("expected http.DefaultClient, got %v", l.Client)
+	}
+}
+
+func TestHTTPLoaderTimeout(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	l := &aster.HTTPLoader{Timeout: 50 * time.Millisecond}
+	start := time.Now()
+	_, err := l.Load(context.Background(), ts.URL+"/slow.json")
+	if err == nil {
+		t.Fatal("expected timeout error, got nil")
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if kind := loadErrorKind(t, err); kind != aster.LoadNetwork {
+		t.Errorf("expected LoadNetwork, got %v", kind)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Load took %v, expected it to stop near the 50ms timeout", elapsed)
+	}
+}
+
+func TestHTTPLoaderTimeoutKeepsShorterParentDeadline(t *testing.T) {
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer ts.Close()
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	l := &aster.HTTPLoader{Timeout: time.Minute}
+	start := time.Now()
+	if _, err := l.Load(ctx, ts.URL+"/slow.json"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Load took %v, expected the parent's 50ms deadline to win", elapsed)
+	}
+}
+
+// ---------- HandlerLoader ----------
+
+func TestHandlerLoaderServesRelativeAndAbsolute(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/data/cars.json", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, `[{"name":"car"}]`)
+	})
+	l := aster.NewHandlerLoader(mux, "https://data.example.com/")
+
+	ctx := context.Background()
+	for _, uri := range []string{"data/cars.json", "https://data.example.com/data/cars.json"} {
+		sanitized, err := l.Sanitize(ctx, uri)
+		if err != nil {
+			t.Fatalf("Sanitize(%q): %v", uri, err)
+		}
+		if sanitized != "https://data.example.com/data/cars.json" {
+			t.Errorf("Sanitize(%q) = %q", uri, sanitized)
+		}
+		data, err := l.Load(ctx, sanitized)
+		if err != nil {
+			t.Fatalf("Load(%q): %v", sanitized, err)
+		}
+		if string(data) != `[{"name":"car"}]` {
+			t.Errorf("unexpected data: %s", data)
+		}
+	}
+}
+
+func TestHandlerLoaderRejectsOtherHosts(t *testing.T) {
+	l := aster.NewHandlerLoader(http.NotFoundHandler(), "https://data.example.com/")
+	_, err := l.Sanitize(context.Background(), "https://evil.com/data.json")
+	if kind := loadErrorKind(t, err); kind != aster.LoadDenied {
+		t.Errorf("expected LoadDenied, got %v", kind)
+	}
+}
+
+func TestHandlerLoaderNotFound(t *testing.T) {
+	l := aster.NewHandlerLoader(http.NotFoundHandler(), "")
+	ctx := context.Background()
+	sanitized, err := l.Sanitize(ctx, "missing.json")
+	if err != nil {
+		t.Fatalf("Sanitize: %v", err)
+	}
+	_, err = l.Load(ctx, sanitized)
+	if kind := loadErrorKind(t, err); kind != aster.LoadNotFound {
+		t.Errorf("expected LoadNotFound, got %v", kind)
+	}
+}
+
+// Load is public, so it must reject URIs that never went through Sanitize
+// rather than panic on them.
+func TestHandlerLoaderUnparsableURI(t *testing.T) {
+	l := aster.NewHandlerLoader(http.NotFoundHandler(), "")
+	for _, uri := range []string{"http://[::1", "http://local host/x.json", "%zz"} {
+		_, err := l.Load(context.Background(), uri)
+		if kind := loadErrorKind(t, err); kind != aster.LoadDenied {
+			t.Errorf("Load(%q): expected LoadDenied, got %v", uri, kind)
+		}
+	}
+}
+
+func TestHandlerLoaderEmptyResponse(t *testing.T) {
+	l := aster.NewHandlerLoader(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}), "")
+	data, err := l.Load(context.Background(), "http://localhost/empty.json")
+	if err != nil || len(data) != 0 {
+		t.Errorf("Load = %q, %v; want an empty 200 response", data, err)
+	}
+}
+
 // ---------- FileLoader: os.Root ----------
 
 func TestFileLoaderBasicRead(t *testing.T) {
@@ -221,6 +557,71 @@ func TestFileLoaderBasicRead(t *testing.T) {
 	}
 }
 
+func TestFileLoaderCharset(t *testing.T) {
+	dir := t.TempDir()
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\xe9")
+	files := map[string][]byte{
+		"latin1.csv": []byte("name\nZo\xeb\n"),
+		"utf8.csv":   []byte("name\nZoë\n"),
+		"image.png":  png,
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	l := &aster.FileLoader{BaseDir: dir, Charset: "auto"}
+	defer func() { _ = l.Close() }()
+
+	ctx := context.Background()
+	for name, want := range map[string]string{
+		"latin1.csv": "name\nZoë\n",
+		"utf8.csv":   "name\nZoë\n",
+		"image.png":  string(png),
+	} {
+		data, err := l.Load(ctx, name)
+		if err != nil {
+			t.Fatalf("Load %s: %v", name, err)
+		}
+		if string(data) != want {
+			t.Errorf("Load %s = %q, want %q", name, data, want)
+		}
+	}
+}
+
+func TestFileLoaderIgnoresQueryAndFragment(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "data"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "data", "cars.json"), []byte(`[]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	l := &aster.FileLoader{BaseDir: dir}
+	defer func() { _ = l.Close() }()
+
+	ctx := context.Background()
+	for _, uri := range []string{"data/cars.json?v=2", "data/cars.json#top", "data/cars.json?v=2&t=1#x"} {
+		sanitized, err := l.Sanitize(ctx, uri)
+		if err != nil {
+			t.Fatalf("Sanitize(%q): %v", uri, err)
+		}
+		if want := filepath.Join("data", "cars.json"); sanitized != want {
+			t.Errorf("Sanitize(%q) = %q, want %q", uri, sanitized, want)
+		}
+		if _, err := l.Load(ctx, sanitized); err != nil {
+			t.Errorf("Load(%q): %v", sanitized, err)
+		}
+	}
+
+	// A query string does not hide path traversal.
+	if _, err := l.Sanitize(ctx, "../secret.json?v=1"); err == nil {
+		t.Error("expected traversal with query to be rejected")
+	}
+}
+
 func TestFileLoaderRejectsAbsolutePath(t *testing.T) {
 	l := &aster.FileLoader{BaseDir: t.TempDir()}
 	_, err := l.Sanitize(context.Background(), "/etc/passwd")
@@ -274,6 +675,10 @@ func TestFileLoaderOSRootBlocksSymlinkEscape(t *testing.T) {
 	if err == nil {
 		t.Fatal("expected os.Root to reject symlink escape")
 	}
+	var le *aster.LoadError
+	if !errors.As(err, &le) || le.Kind != aster.LoadDenied {
+		t.Errorf("expected a LoadDenied error for the escape, got %v", err)
+	}
 }
 
 func TestFileLoaderNewFileLoaderInvalidDir(t *testing.T) {
@@ -345,6 +750,215 @@ func TestStaticLoaderSanitizeAcceptsAnyURI(t *testing.T) {
 	}
 }
 
+// ---------- RawLoader ----------
+
+func TestRawLoaderReturnsBytesVerbatim(t *testing.T) {
+	csv := []byte("a,b\nA,28\nB,55\n")
+	l := &aster.RawLoader{Data: csv, ContentType: "text/csv"}
+
+	ctx := context.Background()
+	uri, err := l.Sanitize(ctx, "anything.csv")
+	if err != nil {
+		t.Fatalf("Sanitize: %v", err)
+	}
+	if uri != "anything.csv" {
+		t.Errorf("Sanitize should return URI unchanged, got %q", uri)
+	}
+
+	got, err := l.Load(ctx, uri)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if string(got) != string(csv) {
+		t.Errorf("expected verbatim CSV, got: %q", got)
+	}
+}
+
+func TestRawLoaderFeedsCSVParser(t *testing.T) {
+	spec := []byte(`{
+		"data": {"url": "data.csv", "format": {"type": "csv"}},
+		"mark": "bar",
+		"encoding": {
+			"x": {"field": "a", "type": "nominal"},
+			"y": {"field": "b", "type": "quantitative"}
+		}
+	}`)
+
+	c, err := aster.New(aster.WithLoader(&aster.RawLoader{
+		Data:        []byte("a,b\nA,28\nB,55\n"),
+		ContentType: "text/csv",
+	}))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	data, err := c.VegaLiteToData(spec, "json")
+	if err != nil {
+		t.Fatalf("VegaLiteToData: %v", err)
+	}
+	var rows []map[string]any
+	if err := json.Unmarshal(data, &rows); err != nil {
+		t.Fatalf("decoding rows: %v", err)
+	}
+	if len(rows) != 2 || rows[0]["a"] != "A" || rows[0]["b"] != 28.0 {
+		t.Errorf("unexpected parsed rows: %s", data)
+	}
+}
+
+// ---------- BudgetLoader ----------
+
+func TestBudgetLoaderRejectsOverBudget(t *testing.T) {
+	l := &aster.BudgetLoader{
+		Loader:        &aster.RawLoader{Data: []byte("0123456789")},
+		MaxTotalBytes: 25,
+	}
+	ctx := context.Background()
+	for i := range 2 {
+		if _, err := l.Load(ctx, "a.csv"); err != nil {
+			t.Fatalf("load %d within budget: %v", i, err)
+		}
+	}
+	_, err := l.Load(ctx, "c.csv")
+	var le *aster.LoadError
+	if !errors.As(err, &le) || le.Kind != aster.LoadTooLarge || le.URI != "c.csv" {
+		t.Fatalf("expected a LoadTooLarge error for c.csv, got %v", err)
+	}
+}
+
+func TestBudgetLoaderResetsPerRender(t *testing.T) {
+	// Each render loads the 14-byte CSV twice: 28 bytes, within a budget of
+	// 40 that two renders together would exceed.
+	spec := []byte(`{
+		"data": {"url": "a.csv", "format": {"type": "csv"}},
+		"layer": [
+			{"mark": "bar"},
+			{"data": {"url": "b.csv", "format": {"type": "csv"}}, "mark": "tick"}
+		],
+		"encoding": {
+			"x": {"field": "a", "type": "nominal"},
+			"y": {"field": "b", "type": "quantitative"}
+		}
+	}`)
+	l := &aster.BudgetLoader{
+		Loader:        &aster.RawLoader{Data: []byte("a,b\nA,28\nB,55\n")},
+		MaxTotalBytes: 40,
+	}
+	c, err := aster.New(aster.WithLoader(l))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	for i := range 2 {
+		if _, err := c.VegaLiteToSVG(spec); err != nil {
+			t.Fatalf("render %d: %v", i, err)
+		}
+	}
+
+	l.MaxTotalBytes = 20
+	_, err = c.VegaLiteToSVG(spec)
+	var le *aster.LoadError
+	if !errors.As(err, &le) || le.Kind != aster.LoadTooLarge {
+		t.Errorf("expected a LoadTooLarge error over a 20-byte budget, got %v", err)
+	}
+}
+
+// ---------- RewriteLoader ----------
+
+func TestRewriteLoaderRules(t *testing.T) {
+	l := &aster.RewriteLoader{
+		Loader: &aster.HTTPLoader{},
+		Rules: []aster.RewriteRule{
+			{Prefix: "https://cdn.example.com/", Replacement: "http://mirror.internal/cdn/"},
+			{Pattern: regexp.MustCompile(`^https://([a-z]+)\.example\.com/`), Replacement: "http://mirror.internal/$1/"},
+		},
+	}
+	for uri, want := range map[string]string{
+		// The prefix rule comes first, so the pattern rule never sees it.
+		"https://cdn.example.com/a.csv":  "http://mirror.internal/cdn/a.csv",
+		"https://data.example.com/b.csv": "http://mirror.internal/data/b.csv",
+		"https://other.org/c.csv":        "https://other.org/c.csv",
+	} {
+		got, err := l.Sanitize(context.Background(), uri)
+		if err != nil || got != want {
+			t.Errorf("Sanitize(%q) = %q, %v; want %q", uri, got, err, want)
+		}
+	}
+}
+
+func TestVegaDatasetsRewriteRules(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/mirror/data/cars.json", func(w http.ResponseWriter, _ *http.Request) {
+		_, _ = w.Write([]byte(`[{"Name": "chevrolet"}]`))
+	})
+	l := &aster.RewriteLoader{
+		Loader: aster.NewHandlerLoader(mux, "http://localhost/"),
+		Rules:  aster.VegaDatasetsRewriteRules("http://localhost/mirror/data/"),
+	}
+
+	for _, uri := range []string{
+		"https://cdn.jsdelivr.net/npm/vega-datasets@v1.29.0/data/cars.json",
+		"https://cdn.jsdelivr.net/npm/vega-datasets/data/cars.json",
+		"https://raw.githubusercontent.com/vega/vega-datasets/main/data/cars.json",
+		"https://vega.github.io/vega-datasets/data/cars.json",
+	} {
+		sanitized, err := l.Sanitize(context.Background(), uri)
+		if err != nil {
+			t.Errorf("Sanitize(%q): %v", uri, err)
+			continue
+		}
+		data, err := l.Load(context.Background(), sanitized)
+		if err != nil || !strings.Contains(string(data), "chevrolet") {
+			t.Errorf("Load(%q) = %q, %v", sanitized, data, err)
+		}
+	}
+}
+
+// ---------- ExampleDatasetsLoader ----------
+
+func TestExampleDatasetsLoader(t *testing.T) {
+	want, err := os.ReadFile("testdata/vega-datasets/data/cars.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := aster.ExampleDatasetsLoader()
+	ctx := context.Background()
+
+	for _, uri := range []string{
+		"data/cars.json",
+		"cars.json",
+		"./data/cars.json?v=2",
+		"https://cdn.jsdelivr.net/npm/vega-datasets@v1.29.0/data/cars.json",
+		"https://vega.github.io/vega-datasets/data/cars.json",
+	} {
+		sanitized, err := l.Sanitize(ctx, uri)
+		if err != nil {
+			t.Errorf("Sanitize(%q): %v", uri, err)
+			continue
+		}
+		data, err := l.Load(ctx, sanitized)
+		if err != nil || string(data) != string(want) {
+			t.Errorf("Load(%q) = %d bytes, %v; want cars.json", sanitized, len(data), err)
+		}
+	}
+
+	for _, uri := range []string{"../aster.go", "/etc/passwd", "https://example.com/data/cars.json"} {
+		if _, err := l.Sanitize(ctx, uri); loadErrorKind(t, err) != aster.LoadDenied {
+			t.Errorf("Sanitize(%q): expected LoadDenied, got %v", uri, err)
+		}
+	}
+
+	// movies.json is vendored but too large for the embedded subset.
+	sanitized, err := l.Sanitize(ctx, "data/movies.json")
+	if err != nil {
+		t.Fatalf("Sanitize: %v", err)
+	}
+	if _, err := l.Load(ctx, sanitized); loadErrorKind(t, err) != aster.LoadNotFound {
+		t.Errorf("movies.json: expected LoadNotFound, got %v", err)
+	}
+}
+
 // ---------- FallbackLoader ----------
 
 func TestFallbackLoaderFirstMatchServes(t *testing.T) {
@@ -431,6 +1045,268 @@ func TestFallbackLoaderOnlyDenyLoaders(t *testing.T) {
 	}
 }
 
+// ---------- DataURILoader ----------
+
+func TestDataURILoaderDecodes(t *testing.T) {
+	ctx := context.Background()
+	tests := map[string]string{
+		"data:application/json;base64,W3siYSI6MX1d": `[{"a":1}]`,
+		"data:text/csv,a%2Cb%0A1%2C2":               "a,b\n1,2",
+		"DATA:,plain":                               "plain",
+	}
+	for uri, want := range tests {
+		sanitized, err := aster.DataURILoader{}.Sanitize(ctx, uri)
+		if err != nil {
+			t.Fatalf("Sanitize(%q): %v", uri, err)
+		}
+		data, err := aster.DataURILoader{}.Load(ctx, sanitized)
+		if err != nil {
+			t.Fatalf("Load(%q): %v", uri, err)
+		}
+		if string(data) != want {
+			t.Errorf("Load(%q) = %q, want %q", uri, data, want)
+		}
+	}
+}
+
+func TestDataURILoaderRejects(t *testing.T) {
+	ctx := context.Background()
+	for _, uri := range []string{"data.json", "https://example.com/data.json", "data:no-comma"} {
+		_, err := aster.DataURILoader{}.Sanitize(ctx, uri)
+		if kind := loadErrorKind(t, err); kind != aster.LoadDenied {
+			t.Errorf("Sanitize(%q): expected LoadDenied, got %v", uri, kind)
+		}
+	}
+	if _, err := (aster.DataURILoader{}).Load(ctx, "data:;base64,!!!"); err == nil {
+		t.Error("expected invalid base64 to fail")
+	}
+}
+
+// ---------- SchemeLoader ----------
+
+func TestSchemeLoaderRoutesByScheme(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "local.json"), []byte(`"file"`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/local.json", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`"http"`))
+	})
+
+	// The HTTP side would happily accept relative paths via its BaseURL;
+	// routing by scheme keeps them on the file loader regardless of order.
+	l := &aster.SchemeLoader{Loaders: map[string]aster.Loader{
+		"":      &aster.FileLoader{BaseDir: dir},
+		"https": aster.NewHandlerLoader(mux, "https://data.example.com/"),
+	}}
+	defer func() { _ = l.Close() }()
+
+	ctx := context.Background()
+	for uri, want := range map[string]string{
+		"local.json":                          `"file"`,
+		"https://data.example.com/local.json": `"http"`,
+		"HTTPS://data.example.com/local.json": `"http"`,
+	} {
+		sanitized, err := l.Sanitize(ctx, uri)
+		if err != nil {
+			t.Fatalf("Sanitize(%q): %v", uri, err)
+		}
+		data, err := l.Load(ctx, sanitized)
+		if err != nil {
+			t.Fatalf("Load(%q): %v", uri, err)
+		}
+		if string(data) != want {
+			t.Errorf("Load(%q) = %s, want %s", uri, data, want)
+		}
+	}
+
+	_, err := l.Sanitize(ctx, "ftp://example.com/data.json")
+	if kind := loadErrorKind(t, err); kind != aster.LoadDenied {
+		t.Errorf("expected LoadDenied for unrouted scheme, got %v", kind)
+	}
+}
+
+func TestSchemeLoaderDefault(t *testing.T) {
+	l := &aster.SchemeLoader{
+		Loaders: map[string]aster.Loader{"data": aster.DataURILoader{}},
+		Default: &aster.StaticLoader{Value: "default"},
+	}
+	data, err := l.Load(context.Background(), "anything.json")
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if string(data) != `"default"` {
+		t.Errorf("expected Default loader to serve, got %s", data)
+	}
+}
+
+func TestNewStandardLoader(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "cars.json"), []byte(`[]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	l := aster.NewStandardLoader(dir, &aster.HTTPLoader{AllowedDomains: []string{"example.com"}})
+	defer func() { _ = l.Close() }()
+
+	ctx := context.Background()
+	if data, err := l.Load(ctx, "cars.json"); err != nil || string(data) != `[]` {
+		t.Errorf("relative path: got %q, %v", data, err)
+	}
+	if data, err := l.Load(ctx, "data:,x"); err != nil || string(data) != "x" {
+		t.Errorf("data URI: got %q, %v", data, err)
+	}
+	if _, err := l.Sanitize(ctx, "https://example.com/cars.json"); err != nil {
+		t.Errorf("allowlisted https: %v", err)
+	}
+	if _, err := l.Sanitize(ctx, "https://evil.com/cars.json"); err == nil {
+		t.Error("expected non-allowlisted domain to be rejected")
+	}
+
+	noHTTP := aster.NewStandardLoader(dir, nil)
+	if _, err := noHTTP.Sanitize(ctx, "https://example.com/cars.json"); err == nil {
+		t.Error("expected https to be denied without an HTTPLoader")
+	}
+}
+
+// ---------- LoadError ----------
+
+// loadErrorKind extracts the LoadErrorKind from err, failing the test if err
+// is not a *aster.LoadError.
+func loadErrorKind(t *testing.T, err error) aster.LoadErrorKind {
+	t.Helper()
+	var le *aster.LoadError
+	if !errors.As(err, &le) {
+		t.Fatalf("expected *aster.LoadError, got %T: %v", err, err)
+	}
+	return le.Kind
+}
+
+func TestLoadErrorDenyLoader(t *testing.T) {
+	_, err := aster.DenyLoader{}.Load(context.Background(), "data.json")
+	if kind := loadErrorKind(t, err); kind != aster.LoadDenied {
+		t.Errorf("expected LoadDenied, got %v", kind)
+	}
+	if !strings.Contains(err.Error(), "resource loading denied") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestLoadErrorHTTPBlockedDomain(t *testing.T) {
+	l := &aster.HTTPLoader{AllowedDomains: []string{"example.com"}}
+	_, err := l.Sanitize(context.Background(), "https://evil.com/data.json")
+	if kind := loadErrorKind(t, err); kind != aster.LoadDenied {
+		t.Errorf("expected LoadDenied, got %v", kind)
+	}
+}
+
+func TestLoadErrorHTTPStatus(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing.json":
+			http.NotFound(w, r)
+		case "/secret.json":
+			w.WriteHeader(http.StatusForbidden)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer ts.Close()
+
+	l := &aster.HTTPLoader{Client: ts.Client()}
+	for path, want := range map[string]aster.LoadErrorKind{
+		"/missing.json": aster.LoadNotFound,
+		"/secret.json":  aster.LoadForbidden,
+		"/broken.json":  aster.LoadNetwork,
+	} {
+		_, err := l.Load(context.Background(), ts.URL+path)
+		if kind := loadErrorKind(t, err); kind != want {
+			t.Errorf("%s: expected %v, got %v", path, want, kind)
+		}
+	}
+}
+
+func TestLoadErrorFileLoader(t *testing.T) {
+	l := &aster.FileLoader{BaseDir: t.TempDir()}
+	defer func() { _ = l.Close() }()
+	ctx := context.Background()
+
+	_, err := l.Sanitize(ctx, "../../etc/passwd")
+	if kind := loadErrorKind(t, err); kind != aster.LoadDenied {
+		t.Errorf("traversal: expected LoadDenied, got %v", kind)
+	}
+
+	_, err = l.Load(ctx, "missing.json")
+	if kind := loadErrorKind(t, err); kind != aster.LoadNotFound {
+		t.Errorf("missing file: expected LoadNotFound, got %v", kind)
+	}
+	var le *aster.LoadError
+	if errors.As(err, &le) && le.URI != "missing.json" {
+		t.Errorf("expected URI %q, got %q", "missing.json", le.URI)
+	}
+}
+
+func TestLoadErrorFallbackPassesThrough(t *testing.T) {
+	l := aster.NewFallbackLoader(aster.DenyLoader{})
+	_, err := l.Load(context.Background(), "data.json")
+	if kind := loadErrorKind(t, err); kind != aster.LoadDenied {
+		t.Errorf("expected LoadDenied, got %v", kind)
+	}
+}
+
+// ---------- Converter data base URL ----------
+
+// uriRecorder serves one row for every URI and records the URIs it was
+// asked to sanitize.
+type uriRecorder struct {
+	uris []string
+}
+
+func (l *uriRecorder) Sanitize(_ context.Context, uri string) (string, error) {
+	l.uris = append(l.uris, uri)
+	return uri, nil
+}
+
+func (l *uriRecorder) Load(_ context.Context, _ string) ([]byte, error) {
+	return []byte(`[{"a": 1}]`), nil
+}
+
+func TestWithDataBaseURL(t *testing.T) {
+	loader := &uriRecorder{}
+	c, err := aster.New(aster.WithLoader(loader), aster.WithDataBaseURL("https://data.example.com/datasets"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	spec := []byte(`{
+		"data": {"url": "cars.json"},
+		"mark": "point",
+		"encoding": {"x": {"field": "a", "type": "quantitative"}}
+	}`)
+	if _, err := c.VegaLiteToSVG(spec); err != nil {
+		t.Fatalf("VegaLiteToSVG: %v", err)
+	}
+	want := "https://data.example.com/datasets/cars.json"
+	if len(loader.uris) != 1 || loader.uris[0] != want {
+		t.Errorf("expected the loader to see %q, got %q", want, loader.uris)
+	}
+
+	// Absolute URLs are passed through.
+	loader.uris = nil
+	spec = []byte(`{
+		"data": {"url": "https://other.example.com/cars.json"},
+		"mark": "point",
+		"encoding": {"x": {"field": "a", "type": "quantitative"}}
+	}`)
+	if _, err := c.VegaLiteToSVG(spec); err != nil {
+		t.Fatalf("VegaLiteToSVG: %v", err)
+	}
+	if len(loader.uris) != 1 || loader.uris[0] != "https://other.example.com/cars.json" {
+		t.Errorf("expected the absolute URL unchanged, got %q", loader.uris)
+	}
+}
+
 // ---------- Converter auto-close ----------
 
 // closerTracker is a Loader that tracks whether Close was called.
@@ -466,3 +1342,60 @@ func TestConverterCloseWithDenyLoader(t *testing.T) {
 		t.Fatalf("Close: %v", err)
 	}
 }
+
+func TestWithRenderLoader(t *testing.T) {
+	c, err := aster.New() // DenyLoader by default
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	spec := []byte(`{
+		"data": {"url": "tenant.json"},
+		"mark": "point",
+		"encoding": {"x": {"field": "a", "type": "quantitative"}}
+	}`)
+	tenant := &uriRecorder{}
+	if _, err := c.VegaLiteToSVG(spec, aster.WithRenderLoader(tenant)); err != nil {
+		t.Fatalf("VegaLiteToSVG with render loader: %v", err)
+	}
+	if len(tenant.uris) == 0 || tenant.uris[0] != "tenant.json" {
+		t.Errorf("render loader saw %v, want tenant.json", tenant.uris)
+	}
+
+	// The override lasts for one call only.
+	if _, err := c.VegaLiteToSVG(spec); err == nil {
+		t.Error("expected the converter's DenyLoader to reject the next render")
+	}
+}
+
+func TestExtensionlessCSVEndpoint(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/reported", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		_, _ = fmt.Fprint(w, "a,b\nx,1\ny,2\n")
+	})
+	mux.HandleFunc("/api/sniffed", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, "a\tb\nx\t1\ny\t2\n")
+	})
+	c, err := aster.New(aster.WithLoader(aster.NewHandlerLoader(mux, "https://data.example.com/")))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	for _, path := range []string{"api/reported", "api/sniffed"} {
+		spec := []byte(`{
+			"data": {"url": "` + path + `"},
+			"mark": "bar",
+			"encoding": {"x": {"field": "a", "type": "nominal"}, "y": {"field": "b", "type": "quantitative"}}
+		}`)
+		out, err := c.VegaLiteToData(spec, "csv")
+		if err != nil {
+			t.Fatalf("%s: VegaLiteToData: %v", path, err)
+		}
+		if !strings.Contains(string(out), "x,1") || !strings.Contains(string(out), "y,2") {
+			t.Errorf("%s: expected the CSV rows to be parsed, got:\n%s", path, out)
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package aster
+
+import "time"
+
+// RenderMetrics reports how long each phase of a render took. Phases that do
+// not apply to a call (Compile for Vega input, PNG for SVG output) are zero.
+type RenderMetrics struct {
+	Compile  time.Duration // Vega-Lite → Vega compilation
+	Parse    time.Duration // parsing the Vega spec into a dataflow
+	Dataflow time.Duration // running the dataflow, including data loading
+	SVG      time.Duration // serializing the scenegraph to SVG
+	PNG      time.Duration // resvg rasterization
+	Total    time.Duration // wall time of the whole call
+}
+
+// reportMetrics delivers the metrics of a render that began at start to the
+// WithMetrics callback, if one is configured.
+func (c *Converter) reportMetrics(start time.Time, png time.Duration) {
+	if c.cfg.metrics == nil {
+		return
+	}
+	t := c.rt.Timings()
+	c.cfg.metrics(RenderMetrics{
+		Compile:  t.Compile,
+		Parse:    t.Parse,
+		Dataflow: t.Dataflow,
+		SVG:      t.Serialize,
+		PNG:      png,
+		Total:    time.Since(start),
+	})
+}
@@ -0,0 +1,56 @@
+package aster_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/mgilbir/aster"
+)
+
+func TestWithMetrics(t *testing.T) {
+	spec, err := os.ReadFile("testdata/bar-chart.vl.json")
+	if err != nil {
+		t.Fatalf("reading test spec: %v", err)
+	}
+
+	var got []aster.RenderMetrics
+	c, err := aster.New(aster.WithMetrics(func(m aster.RenderMetrics) {
+		got = append(got, m)
+	}))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	if _, err := c.VegaLiteToSVG(spec); err != nil {
+		t.Fatalf("VegaLiteToSVG: %v", err)
+	}
+	if _, err := c.VegaLiteToPNG(spec); err != nil {
+		t.Fatalf("VegaLiteToPNG: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 metrics callbacks, got %d", len(got))
+	}
+
+	svgM, pngM := got[0], got[1]
+	if svgM.PNG != 0 {
+		t.Errorf("SVG render reported PNG time %v", svgM.PNG)
+	}
+	if pngM.PNG <= 0 {
+		t.Errorf("PNG render reported no rasterization time")
+	}
+	for i, m := range got {
+		phases := m.Compile + m.Parse + m.Dataflow + m.SVG + m.PNG
+		if m.Total <= 0 || phases > m.Total {
+			t.Errorf("render %d: phases %v should be positive and within total %v", i, phases, m.Total)
+		}
+	}
+
+	// A failed render does not report.
+	if _, err := c.VegaLiteToSVG([]byte("not json")); err == nil {
+		t.Fatal("expected error for invalid spec")
+	}
+	if len(got) != 2 {
+		t.Errorf("expected no callback for a failed render, got %d", len(got))
+	}
+}
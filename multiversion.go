@@ -0,0 +1,81 @@
+package aster
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/mgilbir/aster/internal/runtime"
+)
+
+// MultiVersion creates Converters for any of the embedded Vega-Lite
+// versions, for services that let each request pick one. The vendored
+// modules of a version are compiled to QuickJS bytecode the first time it is
+// requested and reused by every later Converter for it, so only the first
+// pays for parsing the Vega and Vega-Lite bundles.
+//
+// Each Converter still has its own QuickJS runtime: runtimes cannot share
+// heaps, so every open Converter costs its full memory whatever its version.
+// The cached bytecode, a few megabytes per version, is kept for the
+// MultiVersion's lifetime. A MultiVersion is safe for concurrent use.
+type MultiVersion struct {
+	opts []Option
+
+	mu      sync.Mutex
+	modules map[string]*runtime.Modules // version set key → bytecode
+}
+
+// NewMultiVersion returns a MultiVersion whose Converters are created with
+// opts.
+func NewMultiVersion(opts ...Option) *MultiVersion {
+	return &MultiVersion{opts: opts, modules: make(map[string]*runtime.Modules)}
+}
+
+// For creates a Converter for Vega-Lite version v, as accepted by
+// WithVegaLiteVersion ("" for the default), configured with the
+// MultiVersion's options followed by opts. Options selecting a version are
+// overridden by v. The caller must Close the Converter.
+func (m *MultiVersion) For(v string, opts ...Option) (*Converter, error) {
+	var key string
+	if v == "" {
+		def, err := runtime.DefaultVersion()
+		if err != nil {
+			return nil, fmt.Errorf("aster: %w", err)
+		}
+		key = def
+	} else {
+		if !HasVegaLiteVersion(v) {
+			return nil, fmt.Errorf("aster: Vega-Lite version %q is not embedded in this build", v)
+		}
+		key = VersionKey(v)
+	}
+	mods, err := m.compiled(key)
+	if err != nil {
+		return nil, err
+	}
+
+	all := make([]Option, 0, len(m.opts)+len(opts)+1)
+	all = append(all, m.opts...)
+	all = append(all, opts...)
+	all = append(all, func(c *config) {
+		c.vegaLiteVersion = key
+		c.modules = mods
+	})
+	return New(all...)
+}
+
+// compiled returns the bytecode for version set key, compiling it on first
+// use. The lock is held while compiling so concurrent first requests for a
+// version compile it once.
+func (m *MultiVersion) compiled(key string) (*runtime.Modules, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if mods, ok := m.modules[key]; ok {
+		return mods, nil
+	}
+	mods, err := runtime.CompileModules(key)
+	if err != nil {
+		return nil, fmt.Errorf("aster: %w", err)
+	}
+	m.modules[key] = mods
+	return mods, nil
+}
@@ -0,0 +1,39 @@
+package aster_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mgilbir/aster"
+)
+
+func TestMultiVersion(t *testing.T) {
+	spec, err := os.ReadFile("testdata/bar-chart.vl.json")
+	if err != nil {
+		t.Fatalf("reading test spec: %v", err)
+	}
+
+	mv := aster.NewMultiVersion(aster.WithTextMeasurement(false))
+	for _, v := range []string{"6.4", "6.4", ""} {
+		c, err := mv.For(v)
+		if err != nil {
+			t.Fatalf("For(%q): %v", v, err)
+		}
+		svg, err := c.VegaLiteToSVG(spec)
+		_ = c.Close()
+		if err != nil {
+			t.Fatalf("For(%q) VegaLiteToSVG: %v", v, err)
+		}
+		if !strings.HasPrefix(svg, "<svg") {
+			t.Errorf("For(%q): expected SVG output, got %.100s", v, svg)
+		}
+	}
+}
+
+func TestMultiVersionUnknown(t *testing.T) {
+	mv := aster.NewMultiVersion()
+	if _, err := mv.For("0.1"); err == nil {
+		t.Error("expected an error for a version not embedded in this build")
+	}
+}
@@ -3,6 +3,10 @@ package aster
 import (
 	"strings"
 	"time"
+
+	"github.com/mgilbir/aster/internal/resvg"
+	"github.com/mgilbir/aster/internal/runtime"
+	"github.com/mgilbir/aster/internal/textmeasure"
 )
 
 // Option configures a Converter.
@@ -17,19 +21,59 @@ type config struct {
 	loader            Loader
 	theme             string
 	memoryLimit       uint64
+	stackSize         int
 	timeout           time.Duration
 	textMeasure       bool
-	vegaLiteVersion   string // version set key, e.g. "vl6_4"
+	vegaLiteVersion   string           // version set key, e.g. "vl6_4"
+	modules           *runtime.Modules // precompiled modules from a MultiVersion
 	systemFonts       bool
+	noEmbeddedFonts   bool
 	fonts             []fontEntry
 	defaultFontFamily string
 	timezone          string
+	logLevel          string
+	fixedNow          time.Time
+	maxTimerDepth     int
+	trustedSpec       bool
+	sanitizeOutput    bool
+	responsiveSVG     bool
+	uniqueIDs         bool
+	sourceMetadata    bool
+	fullPrecision     bool
+	nativeTooltips    bool
+	strictWarnings    bool
+	strictFonts       bool
+	nonFiniteNumbers  bool
+	sortedKeys        bool
+	configDefaults    map[string]any          // merged under each Vega-Lite spec's config
+	markClip          *bool                   // WithClip: default clip of Vega-Lite marks
+	projectionFits    map[string][][2]float64 // projection name → lon/lat corners
+	maxRenderBytes    int
+	maxSpecBytes      int
+	maxRequests       int
+	maxMarks          int
+	prefetchWorkers   int
+	clampWidth        float64
+	clampHeight       float64
+	textMetricsMode   TextMetricsMode
+	svgPostProcessors []func(svg string) (string, error)
+	renderLanguages   []string
+	pngFamilies       resvg.FamilyMapping // generic family overrides for PNG rendering
+	specTransforms    []func(spec map[string]any) (map[string]any, error)
+	metrics           func(RenderMetrics)
+	schemeLoaders     map[string]Loader
+	dataBaseURL       string
+	fontAliases       map[string]string // lower-case family → substitute
+	fontFeatures      []string
+	fontParser        func(string) CSSFont
 }
 
 func defaultConfig() *config {
 	return &config{
 		loader:      DenyLoader{},
 		timeout:     30 * time.Second,
+		maxRequests: DefaultMaxRequests,
+		maxMarks:    DefaultMaxMarks,
 		textMeasure: true,
 		// vegaLiteVersion left empty; runtime reads default from versions.json
 	}
@@ -43,6 +87,33 @@ func WithLoader(l Loader) Option {
 	}
 }
 
+// WithLoaderForScheme routes URIs with the given scheme ("" for relative
+// paths, "https", "data", ...) to l, without the first-match ordering of
+// FallbackLoader. URIs whose scheme has no dedicated loader fall through to
+// the WithLoader loader (DenyLoader by default). See SchemeLoader.
+func WithLoaderForScheme(scheme string, l Loader) Option {
+	return func(c *config) {
+		if c.schemeLoaders == nil {
+			c.schemeLoaders = make(map[string]Loader)
+		}
+		c.schemeLoaders[strings.ToLower(scheme)] = l
+	}
+}
+
+// WithDataBaseURL sets Vega's loader baseURL: relative data URLs in specs,
+// such as "url": "cars.json", are prefixed with base before any Loader
+// sees them, so every loader receives absolute URIs. Unlike
+// HTTPLoader.BaseURL, which resolves URIs only for that loader, it applies
+// whatever the loader. As in Vega, the base is prepended as text, with a
+// slash inserted if neither side has one, rather than resolved like a link:
+// "/data/cars.json" is appended too. URLs with a scheme, protocol-relative
+// URLs and data: URIs are left alone.
+func WithDataBaseURL(base string) Option {
+	return func(c *config) {
+		c.dataBaseURL = base
+	}
+}
+
 // WithTheme sets a Vega theme configuration (JSON string) applied to all renders.
 func WithTheme(theme string) Option {
 	return func(c *config) {
@@ -51,14 +122,32 @@ func WithTheme(theme string) Option {
 }
 
 // WithMemoryLimit sets the maximum memory (in bytes) for the QuickJS runtime.
-// Zero means no limit.
+// A render that needs more fails with an error wrapping
+// ErrMemoryLimitExceeded. Zero means no limit.
 func WithMemoryLimit(bytes uint64) Option {
 	return func(c *config) {
 		c.memoryLimit = bytes
 	}
 }
 
+// WithStackSize sets the QuickJS stack limit in bytes, which bounds how
+// deeply Vega may recurse, e.g. through nested facets and repeats. A larger
+// limit admits deeper specs at the cost of stack memory per Converter; it
+// must stay within the native stack of the QuickJS WASM build, past which a
+// deep spec crashes the runtime instead of failing with a RangeError. Zero
+// (the default) keeps QuickJS's own limit.
+func WithStackSize(bytes int) Option {
+	return func(c *config) {
+		c.stackSize = bytes
+	}
+}
+
 // WithTimeout sets the maximum duration for a single render operation.
+// Loader calls receive a context with this deadline. A render that exceeds
+// it fails with an error wrapping ErrRenderTimeout. The embedded QuickJS
+// cannot interrupt a running script, so a CPU-bound render runs to
+// completion before it is failed; only Loader calls are cut off at the
+// deadline.
 func WithTimeout(d time.Duration) Option {
 	return func(c *config) {
 		c.timeout = d
@@ -76,24 +165,106 @@ func WithTextMeasurement(enabled bool) Option {
 
 // WithVegaLiteVersion sets the Vega-Lite version to use.
 // Accepts human-readable versions like "5.8", "6.4" which are mapped to
-// internal version set keys (e.g. "vl5_8", "vl6_4").
+// internal version set keys (e.g. "vl5_8", "vl6_4"; see VersionKey).
 // The default is "6.4".
 func WithVegaLiteVersion(v string) Option {
 	return func(c *config) {
-		// Map "5.8" → "vl5_8", "6.4" → "vl6_4", etc.
-		key := "vl" + strings.ReplaceAll(v, ".", "_")
-		c.vegaLiteVersion = key
+		c.vegaLiteVersion = VersionKey(v)
+	}
+}
+
+// TextMetricsMode selects which reference environment text measurement
+// imitates.
+type TextMetricsMode int
+
+const (
+	// TextMetricsBrowser keeps sub-pixel glyph advances, matching browsers
+	// and vl-convert (the reference for testdata/vl-convert). This is the
+	// default.
+	TextMetricsBrowser TextMetricsMode = iota
+	// TextMetricsCanvas rounds each glyph advance to a whole pixel, matching
+	// node-canvas/Cairo with hinted metrics (the environment that generated
+	// the vega-lite example SVGs in testdata/vega-lite).
+	TextMetricsCanvas
+)
+
+// WithTextMetricsMode selects how text widths are accumulated from glyph
+// advances. See TextMetricsBrowser and TextMetricsCanvas.
+func WithTextMetricsMode(mode TextMetricsMode) Option {
+	return func(c *config) {
+		c.textMetricsMode = mode
+	}
+}
+
+// WithFontFeatures sets the OpenType features used when shaping text for
+// measurement, by tag: e.g. WithFontFeatures("tnum") for tabular figures,
+// which changes the width of numeric axis labels, or "-kern" to turn
+// kerning off. Use it to match how the final renderer draws the font, since
+// it affects only measurement. Features not listed keep the shaper's
+// defaults. New fails if a tag is not four characters.
+func WithFontFeatures(tags ...string) Option {
+	return func(c *config) {
+		c.fontFeatures = append(c.fontFeatures, tags...)
+	}
+}
+
+// CSSFont is a parsed CSS font shorthand, as returned by a WithFontParser
+// function. Style and Weight are from github.com/go-text/typesetting/font;
+// Size is in pixels.
+type CSSFont = textmeasure.CSSFont
+
+// WithFontParser parses the CSS font strings Vega measures text with using
+// fn, instead of the built-in parser, which handles only
+// "[style] [weight] size family[, family...]". It is an escape hatch for
+// shorthands that parser misreads, such as ones with a font-variant or a
+// line height. When fn returns the zero CSSFont, the string is parsed with
+// the built-in parser, so fn need only handle the inputs it knows.
+func WithFontParser(fn func(s string) CSSFont) Option {
+	return func(c *config) {
+		c.fontParser = fn
+	}
+}
+
+// WithFontAlias substitutes the font family to wherever a spec names from,
+// for families that are not available (e.g. WithFontAlias("Helvetica Neue",
+// "Liberation Sans")). The alias applies to text measurement and to PNG
+// rasterization; SVG output keeps the spec's family names so viewers that
+// have the original font still use it. Matching is case-insensitive.
+func WithFontAlias(from, to string) Option {
+	return func(c *config) {
+		if c.fontAliases == nil {
+			c.fontAliases = make(map[string]string)
+		}
+		c.fontAliases[strings.ToLower(from)] = to
 	}
 }
 
 // WithSystemFonts enables scanning of system-installed fonts for text
 // measurement. System fonts supplement the always-present embedded Liberation Sans.
+// The PNG renderer loads the same font files, so rasterized text uses the
+// fonts it was measured with; this makes the first PNG render slower on
+// systems with many fonts.
 func WithSystemFonts() Option {
 	return func(c *config) {
 		c.systemFonts = true
 	}
 }
 
+// WithoutEmbeddedFonts leaves the embedded Liberation fonts out of text
+// measurement and PNG rendering, so only fonts registered with WithFont (and
+// WithSystemFonts, if set) are used. Without it, text in a family that was
+// never registered silently falls back to Liberation Sans; with it, such
+// text is measured with one of the registered fonts and renders in PNGs as
+// missing glyphs, which makes a missing font visible. Set
+// WithResvgSansFamily and WithResvgMonospaceFamily to registered families
+// to keep the generic families working. With text measurement enabled, New
+// fails if no fonts remain.
+func WithoutEmbeddedFonts() Option {
+	return func(c *config) {
+		c.noEmbeddedFonts = true
+	}
+}
+
 // WithFont registers a custom TTF font with the given family name for text
 // measurement. Custom fonts take priority over system and embedded fonts.
 // Multiple calls append additional fonts; later fonts take higher priority.
@@ -123,23 +294,607 @@ func WithTimezone(tz string) Option {
 	}
 }
 
-// PNGOption configures a single PNG render operation.
-type PNGOption func(*pngConfig)
+// WithFixedNow pins the JavaScript clock to t: Date.now(), new Date() and
+// Vega's now() expression function all return t, so specs with
+// time-relative signals or filters render deterministically.
+func WithFixedNow(t time.Time) Option {
+	return func(c *config) {
+		c.fixedNow = t
+	}
+}
+
+// WithMaxTimerDepth caps chains of setTimeout callbacks that schedule
+// further timers. Vega runs headless, but a timer that keeps rescheduling
+// itself (as animated specs do via d3-timer) would otherwise keep a render
+// running until WithTimeout, or crash the runtime where timers run inline.
+// Each callback counts one level deeper than the one that scheduled it;
+// timers scheduled past the limit are silently dropped. Defaults to 100;
+// n <= 0 keeps the default.
+func WithMaxTimerDepth(n int) Option {
+	return func(c *config) {
+		c.maxTimerDepth = n
+	}
+}
+
+// WithLogLevel sets the level of Vega's and Vega-Lite's internal logger:
+// "none", "error", "warn", "info" or "debug". Messages go to the JavaScript
+// console. Vega's default is "warn"; "debug" helps trace dataflow and
+// transform problems, while "none" silences noisy specs. New returns an
+// error for any other value.
+func WithLogLevel(level string) Option {
+	return func(c *config) {
+		c.logLevel = level
+	}
+}
+
+// WithStrictWarnings makes renders and compiles that log any Vega or
+// Vega-Lite warning fail with a *WarningsError listing them (see
+// Converter.Warnings), so CI can catch specs relying on deprecated or
+// ignored properties before a version bump breaks them.
+func WithStrictWarnings() Option {
+	return func(c *config) {
+		c.strictWarnings = true
+	}
+}
+
+// FontsError is the error returned when a Converter created with
+// WithStrictFonts measures text it cannot render faithfully. Families lists
+// the requested font families that are not available and Glyphs the
+// characters no font has a glyph for.
+type FontsError = runtime.FontsError
+
+// WithStrictFonts makes renders that measure text in a font family that is
+// not available, or with characters no available font covers, fail with a
+// *FontsError listing them, instead of silently substituting the default
+// family or drawing missing-glyph boxes. A family counts as available if
+// it is embedded, added with WithFont or, with WithSystemFonts, installed;
+// WithFontAlias targets stand in for the families they replace, and generic
+// families such as "sans-serif" always resolve. Fonts are checked as text
+// is measured, so WithTextMeasurement(false) turns the check off.
+func WithStrictFonts() Option {
+	return func(c *config) {
+		c.strictFonts = true
+	}
+}
+
+// WithSortedKeys makes VegaLiteToVega (and CompileMany) write the compiled
+// spec with every object's keys in sorted order, so compiled outputs can be
+// diffed reliably across runs and Vega-Lite versions. By default keys keep
+// the order Vega-Lite emits them in. Number and string text is unchanged.
+func WithSortedKeys() Option {
+	return func(c *config) {
+		c.sortedKeys = true
+	}
+}
+
+// WithNonFiniteNumbers accepts the bare number literals NaN, Infinity and
+// -Infinity in spec JSON, as Vega's own relaxed parser does, e.g. a scale
+// domain of [0, Infinity]. Standard JSON has no such numbers, so without it
+// these specs fail to parse. The values reach Vega as the JavaScript numbers
+// they name; WithSpecTransform hooks see them as the placeholder strings
+// "__aster_NaN__", "__aster_Infinity__" and "__aster_-Infinity__".
+func WithNonFiniteNumbers() Option {
+	return func(c *config) {
+		c.nonFiniteNumbers = true
+	}
+}
+
+// WithPrefetch fetches every data URL a spec references (see ListResources)
+// before rendering it, up to workers at a time, and serves the render's
+// loads from those results. Vega's loads reach the Loader one at a time, so
+// for specs with many independent datasets this overlaps their network
+// round trips; the runtime itself stays single-threaded. The Loader must be
+// safe for concurrent use. Vega-Lite specs are compiled once more to find
+// their URLs. URLs computed from signals are not prefetched, and a failed
+// prefetch is retried by the render, which reports the error. The prefetch
+// is part of the render: its time counts against WithTimeout, and a spec
+// referencing more URLs than WithMaxRequests allows fails before any are
+// fetched. workers <= 0 (the default) disables prefetching.
+func WithPrefetch(workers int) Option {
+	return func(c *config) {
+		c.prefetchWorkers = workers
+	}
+}
+
+// WithTrustedSpec marks all specs rendered by the Converter as trusted
+// first-party input, disabling the defensive size guards meant for untrusted
+// specs (see the "Untrusted input" section of the README for the list).
+// WithMemoryLimit and WithTimeout still apply.
+func WithTrustedSpec() Option {
+	return func(c *config) {
+		c.trustedSpec = true
+	}
+}
+
+// WithSanitizeOutput strips active content (<script>, <foreignObject>, on*
+// event attributes, javascript: URLs) from rendered SVGs before they are
+// returned. See SanitizeSVG.
+func WithSanitizeOutput() Option {
+	return func(c *config) {
+		c.sanitizeOutput = true
+	}
+}
+
+// WithFullPrecision keeps full floating-point precision in SVG path data.
+// aster itself never rounds coordinates, but the d3-shape generators Vega
+// uses for lines, areas, arcs and symbols round path coordinates to 3
+// decimals; this disables that rounding, for output imported into CAD or
+// illustration tools where it is visible at high zoom. Other attributes
+// (transforms, positions, sizes) are always written at full precision.
+func WithFullPrecision() Option {
+	return func(c *config) {
+		c.fullPrecision = true
+	}
+}
+
+// WithNativeTooltips gives every mark item with a tooltip (from a Vega-Lite
+// tooltip encoding or a Vega tooltip property) a <title> child holding the
+// tooltip text, so any SVG viewer shows it on hover without JavaScript.
+// Object tooltips are written one "key: value" line per field. Line, area
+// and trail marks are one SVG element, so they show their first point's
+// tooltip.
+func WithNativeTooltips() Option {
+	return func(c *config) {
+		c.nativeTooltips = true
+	}
+}
+
+// WithResponsiveSVG emits SVGs that scale to their container: the root
+// element keeps its viewBox (preserving the chart's aspect ratio) but gets
+// width="100%" and no fixed height. PNG output is unaffected.
+func WithResponsiveSVG() Option {
+	return func(c *config) {
+		c.responsiveSVG = true
+	}
+}
+
+// WithSourceMetadata embeds a <metadata> element in every output SVG that
+// records where it came from: the SHA-256 of the input spec, exactly as
+// passed to the render method, and the aster, Vega and Vega-Lite versions
+// that rendered it. Downstream systems can then trace an image back to its
+// spec and toolchain. It is off by default so that output stays byte-for-byte
+// comparable; PNG output is unaffected.
+func WithSourceMetadata() Option {
+	return func(c *config) {
+		c.sourceMetadata = true
+	}
+}
 
-type pngConfig struct {
-	scale float64
+// WithUniqueIDs appends a random suffix, fresh for every render, to each
+// id in the output SVG and to the url(#id) and href="#id" references to it.
+// Vega numbers clip paths and gradients from zero in every render (clip0,
+// gradient_0, ...), so without this, several SVGs inlined into one page
+// clip or fill with each other's definitions. SVG output is then no longer
+// byte-for-byte reproducible. PNG output is unaffected.
+func WithUniqueIDs() Option {
+	return func(c *config) {
+		c.uniqueIDs = true
+	}
 }
 
-func defaultPNGConfig() *pngConfig {
-	return &pngConfig{
+// WithDefaultSize sets the default width and height of Vega-Lite charts that
+// do not specify a size. It merges into the spec's config.view
+// (continuousWidth/discreteWidth and continuousHeight/discreteHeight); sizes
+// set by the spec itself, including its own config, take precedence.
+// A non-positive value leaves that dimension at Vega-Lite's default.
+func WithDefaultSize(width, height float64) Option {
+	return func(c *config) {
+		if c.configDefaults == nil {
+			c.configDefaults = make(map[string]any)
+		}
+		if width > 0 {
+			setDefault(c.configDefaults, width, "view", "continuousWidth")
+			setDefault(c.configDefaults, width, "view", "discreteWidth")
+		}
+		if height > 0 {
+			setDefault(c.configDefaults, height, "view", "continuousHeight")
+			setDefault(c.configDefaults, height, "view", "discreteHeight")
+		}
+	}
+}
+
+// WithAutosize sets the autosize mode for Vega-Lite specs, e.g.
+// WithAutosize("fit", false, "padding") to make every chart's total size,
+// padding and axes included, equal its width and height. It merges into the
+// spec's config.autosize, so properties set by the spec's own autosize (or
+// config) take precedence. An empty autosizeType or contains, or a false
+// resize, leaves that property at Vega-Lite's default.
+func WithAutosize(autosizeType string, resize bool, contains string) Option {
+	return func(c *config) {
+		if c.configDefaults == nil {
+			c.configDefaults = make(map[string]any)
+		}
+		if autosizeType != "" {
+			setDefault(c.configDefaults, autosizeType, "autosize", "type")
+		}
+		if resize {
+			setDefault(c.configDefaults, true, "autosize", "resize")
+		}
+		if contains != "" {
+			setDefault(c.configDefaults, contains, "autosize", "contains")
+		}
+	}
+}
+
+// WithAutosizeContains sets Vega-Lite's autosize.contains for every spec:
+// "content" (the default) leaves padding outside the chart's width and
+// height, while "padding" counts it within them. Matching the setting of the
+// environment that produced reference SVGs avoids off-by-padding size diffs.
+// Like WithAutosize, it merges into the spec's config.autosize, so the spec's
+// own autosize (or config) takes precedence; of the two options, the one
+// given later wins. An empty contains leaves Vega-Lite's default.
+func WithAutosizeContains(contains string) Option {
+	return func(c *config) {
+		if contains == "" {
+			return
+		}
+		if c.configDefaults == nil {
+			c.configDefaults = make(map[string]any)
+		}
+		setDefault(c.configDefaults, contains, "autosize", "contains")
+	}
+}
+
+// WithClip sets whether the marks of every Vega-Lite spec are clipped to
+// their view's width and height, so outliers beyond a scale's fixed domain
+// no longer draw over the axes and their labels. It sets the clip property
+// of each mark definition, in layers and concatenated, faceted and repeated
+// specs too; a mark that sets its own clip keeps it. Vega-Lite leaves marks
+// unclipped by default.
+func WithClip(clip bool) Option {
+	return func(c *config) {
+		c.markClip = &clip
+	}
+}
+
+// WithProjectionFit fits the named projection to a fixed geographic extent,
+// given as two [longitude, latitude] corners, instead of to the data being
+// drawn. Maps rendered with the same extent share a viewport, so several
+// maps on a dashboard line up whatever their data. The fit is applied to the
+// compiled Vega spec before rendering; Vega-Lite names a chart's projection
+// "projection" (see VegaLiteToVega). Rendering fails if the spec has no
+// projection of that name. Calling it again for a name replaces the extent.
+func WithProjectionFit(name string, extent [][2]float64) Option {
+	return func(c *config) {
+		if c.projectionFits == nil {
+			c.projectionFits = make(map[string][][2]float64)
+		}
+		c.projectionFits[name] = extent
+	}
+}
+
+// WithMaxRenderBytes rejects renders whose SVG output exceeds n bytes,
+// before any PNG rasterization. This protects services from specs that
+// produce millions of marks. Errors wrap ErrLimitExceeded. Zero (the
+// default) means unlimited.
+func WithMaxRenderBytes(n int) Option {
+	return func(c *config) {
+		c.maxRenderBytes = n
+	}
+}
+
+// WithMaxSpecBytes rejects input specs longer than n bytes of JSON before
+// they are parsed, protecting services from memory exhaustion through giant
+// inline datasets. Errors wrap ErrLimitExceeded. Zero (the default) means
+// unlimited.
+func WithMaxSpecBytes(n int) Option {
+	return func(c *config) {
+		c.maxSpecBytes = n
+	}
+}
+
+// DefaultMaxRequests is the WithMaxRequests limit of a Converter that does
+// not set one.
+const DefaultMaxRequests = 1000
+
+// WithMaxRequests fails renders that request more than n distinct external
+// resources, counted as the URIs passed to the Loader's Sanitize: datasets
+// and images alike. This protects services from specs that fetch thousands
+// of map tiles. Vega renders on past a failed image, so the render fails
+// once it completes. Errors wrap ErrLimitExceeded. The default is
+// DefaultMaxRequests; zero or less means unlimited.
+func WithMaxRequests(n int) Option {
+	return func(c *config) {
+		c.maxRequests = n
+	}
+}
+
+// DefaultMaxMarks is the WithMaxMarks limit of a Converter that does not
+// set one.
+const DefaultMaxMarks = 1_000_000
+
+// WithMaxMarks fails SVG and PNG renders whose scenegraph holds more than n
+// items (every mark instance, groups included), before the SVG is
+// serialized. Unlike WithMaxRenderBytes, this catches small specs whose
+// transforms fan out to millions of marks, without first paying for the
+// serializer. Errors wrap ErrLimitExceeded and suggest sampling. The default
+// is DefaultMaxMarks; zero or less means unlimited.
+func WithMaxMarks(n int) Option {
+	return func(c *config) {
+		c.maxMarks = n
+	}
+}
+
+// WithScaleClamp lowers a spec's top-level width and height to at most
+// maxWidth and maxHeight before it is rendered, reporting each clamp in
+// Converter.Warnings. Unlike WithMaxRenderBytes, which rejects a render,
+// the chart still renders, at the clamped size, so preview services always
+// produce something reasonable for specs such as "width": 1e9. Only numeric
+// sizes are clamped; axes, legends and padding add to them as usual. A
+// non-positive maximum leaves that dimension unclamped, the default.
+func WithScaleClamp(maxWidth, maxHeight float64) Option {
+	return func(c *config) {
+		c.clampWidth = maxWidth
+		c.clampHeight = maxHeight
+	}
+}
+
+// WithSpecTransform registers a function that rewrites each spec before it
+// is rendered or compiled, for generic edits such as stripping tooltips or
+// adding a watermark mark. It receives the decoded input spec — Vega-Lite for
+// the VegaLite* methods, Vega for the Vega* methods — with numbers as
+// json.Number, after any WithDefaultSize config has been merged in.
+// Transforms run in registration order and may modify the map in place or
+// return a new one. A non-nil error aborts the render.
+func WithSpecTransform(fn func(spec map[string]any) (map[string]any, error)) Option {
+	return func(c *config) {
+		c.specTransforms = append(c.specTransforms, fn)
+	}
+}
+
+// WithSVGPostProcessor registers a function that rewrites each rendered SVG
+// before it is returned or rasterized, for transforms such as minification or
+// custom attribute injection. Processors run in registration order, before
+// the built-in SVG output options, and apply to PNG renders too. A non-nil
+// error aborts the render.
+func WithSVGPostProcessor(fn func(svg string) (string, error)) Option {
+	return func(c *config) {
+		c.svgPostProcessors = append(c.svgPostProcessors, fn)
+	}
+}
+
+// WithRenderLanguages sets the languages, as BCP 47 tags in priority order,
+// that the PNG renderer matches against systemLanguage attributes to choose
+// among the children of an SVG <switch>, as a browser does with its
+// preferred languages. resvg's glyph fallback does not consult the list: it
+// takes the first registered font that has a glyph, so for, say, Japanese
+// forms of Han characters, register a Japanese font with WithFont and name
+// it in the text's font-family. It has no effect on SVG output. PNG
+// rendering fails with an error wrapping errors.ErrUnsupported if the
+// embedded resvg.wasm predates the set_languages export (rebuild it with
+// make vendor-resvg).
+func WithRenderLanguages(langs []string) Option {
+	return func(c *config) {
+		c.renderLanguages = langs
+	}
+}
+
+// WithResvgSansFamily sets the font family the PNG renderer uses for the
+// generic sans-serif family (default "Liberation Sans"). Use it when the
+// sans font registered with WithFont has a different family name, e.g.
+// WithFont("Inter", data) with WithResvgSansFamily("Inter"). It has no
+// effect on SVG output or text measurement.
+func WithResvgSansFamily(family string) Option {
+	return func(c *config) {
+		c.pngFamilies.SansSerif = family
+	}
+}
+
+// WithResvgSerifFamily sets the font family the PNG renderer uses for the
+// generic serif family. No serif font is embedded, so by default resvg
+// falls back to its own choice. It has no effect on SVG output or text
+// measurement.
+func WithResvgSerifFamily(family string) Option {
+	return func(c *config) {
+		c.pngFamilies.Serif = family
+	}
+}
+
+// WithResvgMonospaceFamily sets the font family the PNG renderer uses for
+// the generic monospace family (default "Liberation Mono").
+func WithResvgMonospaceFamily(family string) Option {
+	return func(c *config) {
+		c.pngFamilies.Monospace = family
+	}
+}
+
+// WithMetrics registers a callback that receives per-phase timings after
+// each successful VegaToSVG, VegaLiteToSVG, VegaToPNG or VegaLiteToPNG call.
+// The JS phases are timed inside the runtime with performance.now and
+// rasterization is timed in Go. The callback runs synchronously on the
+// rendering goroutine.
+func WithMetrics(fn func(m RenderMetrics)) Option {
+	return func(c *config) {
+		c.metrics = fn
+	}
+}
+
+// RenderOption configures a single render call. Options that do not apply
+// to a call's output format are ignored (WithScale for SVG output,
+// WithSVGTitle for PNG output).
+type RenderOption func(*renderConfig)
+
+// PNGOption configures a single PNG render operation. It is the same type as
+// RenderOption, so every render option can be passed to the PNG methods.
+type PNGOption = RenderOption
+
+type renderConfig struct {
+	scale          float64
+	fitWidth       float64
+	svgTitle       string
+	svgDesc        string
+	svgClassPrefix string
+	loader         Loader
+	defaultFont    string
+	trim           bool
+	flatGradients  bool
+	dpiMetadata    bool
+	estimateText   bool
+	antialiasing   Antialiasing
+	source         []byte // input spec, for WithSourceMetadata
+}
+
+func defaultRenderConfig(opts []RenderOption) *renderConfig {
+	rc := &renderConfig{
 		scale: 1.0,
 	}
+	for _, opt := range opts {
+		opt(rc)
+	}
+	return rc
 }
 
 // WithScale sets the scale factor for PNG rendering. A scale of 2.0 produces
-// an image with twice the dimensions. Default is 1.0.
+// an image with twice the dimensions. Fractional scales are allowed; each
+// scaled dimension is rounded half up to a whole pixel, so a 100px chart at
+// 1.5 is 150px. Default is 1.0.
 func WithScale(scale float64) PNGOption {
-	return func(c *pngConfig) {
+	return func(c *renderConfig) {
 		c.scale = scale
 	}
 }
+
+// WithDPI renders the PNG for print at dpi pixels per inch and records the
+// resolution in the file's pHYs chunk, so tools such as Word or InDesign
+// place it at the chart's physical size: one CSS pixel is 1/96 inch, so
+// WithDPI(300) rasterizes at a scale of 3.125. It replaces WithScale; a
+// later WithScale, or WithFitWidth, changes the scale and the recorded
+// resolution with it, keeping the physical size consistent. Like WithScale,
+// it does not affect SVG output.
+func WithDPI(dpi float64) PNGOption {
+	return func(c *renderConfig) {
+		c.scale = dpi / cssDPI
+		c.dpiMetadata = true
+	}
+}
+
+// Antialiasing selects how smoothly PNG output draws edges.
+type Antialiasing int
+
+const (
+	// AntialiasAll smooths the edges of shapes and text. This is the
+	// default, and resvg's.
+	AntialiasAll Antialiasing = iota
+	// AntialiasText smooths text but draws shapes with crisp, pixel-aligned
+	// edges, for sharp bars and gridlines with readable labels.
+	AntialiasText
+	// AntialiasNone draws shapes and text without smoothing: the crispest
+	// output, with jagged diagonals and curves.
+	AntialiasNone
+)
+
+// WithAntialiasing sets how PNG output smooths edges, to get crisper or
+// smoother images, or to match a reference environment's rasterizer. The
+// embedded resvg build does not expose resvg's rendering options, so the
+// mode is applied as shape-rendering and text-rendering hints on the root
+// <svg> element, which resvg honors: elements that set their own hint keep
+// it. SVG output is unaffected.
+func WithAntialiasing(mode Antialiasing) PNGOption {
+	return func(c *renderConfig) {
+		c.antialiasing = mode
+	}
+}
+
+// WithTrim crops the PNG to its content, removing the border of fully
+// transparent pixels, or of the background color when the chart paints one,
+// such as padding, for tight thumbnails. The border color is taken from the
+// image's top-left pixel. Trimming happens after rasterization, so the
+// output is smaller than the WithScale or WithFitWidth size.
+func WithTrim() PNGOption {
+	return func(c *renderConfig) {
+		c.trim = true
+	}
+}
+
+// WithFitWidth uniformly scales the finished chart so the output is exactly
+// px pixels wide, with the height scaled in proportion and rounded to a
+// whole pixel. Unlike setting the spec's width, the chart is not laid out
+// again: it is the same render, larger or smaller. PNG output is rasterized
+// at the fitting scale, overriding WithScale; SVG output gets the new width
+// and height with the original size as its viewBox. A non-positive px, or an
+// SVG without a pixel size, leaves the output unscaled. WithResponsiveSVG
+// output stays responsive.
+func WithFitWidth(px float64) RenderOption {
+	return func(c *renderConfig) {
+		c.fitWidth = px
+	}
+}
+
+// WithSVGClassPrefix prefixes every class name and element id in the output
+// SVG, e.g. "mark-rect" becomes "chart1-mark-rect", and rewrites the
+// url(#id) and href="#id" references to match. Give each chart inlined into
+// one HTML page its own prefix so page styles for one do not hit another
+// and clip paths and gradients resolve within their own chart. The prefix
+// should be a valid CSS identifier start, such as a letter.
+func WithSVGClassPrefix(prefix string) RenderOption {
+	return func(c *renderConfig) {
+		c.svgClassPrefix = prefix
+	}
+}
+
+// WithRenderLoader loads the render's external data through l instead of
+// the Converter's loader (WithLoader and WithLoaderForScheme), for that call
+// only. One Converter can then render on behalf of several tenants, each
+// with its own allowed domains or credentials. It does not make the
+// Converter safe for concurrent use: renders still run one at a time.
+func WithRenderLoader(l Loader) RenderOption {
+	return func(c *renderConfig) {
+		c.loader = l
+	}
+}
+
+// WithRenderDefaultFont overrides WithDefaultFontFamily for one render: text
+// that asks for "sans-serif" or another generic family, or for a family that
+// is not registered, is measured with family. One Converter can then render
+// for tenants with different default fonts. Switching itself is cheap: the
+// family is swapped in for the render and restored afterwards. The cost is
+// in loading each family's font faces the first time a render uses it, and
+// in keeping them loaded, so many tenant families grow the Converter's
+// memory. Like WithDefaultFontFamily, it affects only text measurement.
+func WithRenderDefaultFont(family string) RenderOption {
+	return func(c *renderConfig) {
+		c.defaultFont = family
+	}
+}
+
+// WithFlattenGradients replaces the gradient fills and strokes of SVG
+// output with a solid color: the gradient's color at its midpoint. Some SVG
+// consumers, such as certain PDF importers, mishandle Vega's gradient
+// definitions; the flattened SVG renders acceptably there at the cost of
+// the gradient itself, so a legend's color ramp becomes a single swatch.
+// PNG output keeps its gradients.
+func WithFlattenGradients() RenderOption {
+	return func(c *renderConfig) {
+		c.flatGradients = true
+	}
+}
+
+// WithRenderTextMeasurement(false) skips text measurement for one render
+// of a Converter that measures text (see WithTextMeasurement): label widths
+// come from Vega's own estimation, as if the Converter were created with
+// WithTextMeasurement(false). Use it for fast previews, or for a spec whose
+// layout was tuned to the estimate. It does not turn measurement on for a
+// Converter without it, and WithStrictFonts has nothing to check while it
+// is off.
+func WithRenderTextMeasurement(enabled bool) RenderOption {
+	return func(c *renderConfig) {
+		c.estimateText = !enabled
+	}
+}
+
+// WithSVGTitle inserts a <title> as the first child of the output <svg>,
+// which screen readers announce and browsers show as a tooltip when the SVG
+// is used as an <img>. The text is XML-escaped.
+func WithSVGTitle(title string) RenderOption {
+	return func(c *renderConfig) {
+		c.svgTitle = title
+	}
+}
+
+// WithSVGDesc inserts a <desc> with a longer description into the output
+// <svg>, after any WithSVGTitle. The text is XML-escaped.
+func WithSVGDesc(desc string) RenderOption {
+	return func(c *renderConfig) {
+		c.svgDesc = desc
+	}
+}
@@ -0,0 +1,92 @@
+package aster
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// VegaLiteToSVGWithParams renders a Vega-Lite spec to SVG like VegaLiteToSVG,
+// but first sets the value of named params, so the static chart shows a
+// chosen state: a variable param's value, or a selection's initial
+// selection, such as a pre-selected legend category that filters the data.
+// Values take the shape of a param's "value" property in Vega-Lite, e.g.
+// {"x": [10, 20]} for an interval selection over x, or
+// [{"category": "A"}] for a point selection.
+//
+// Params are matched by name at the top level and in nested layer, concat
+// and facet specs. It is an error if a named param does not exist.
+func (c *Converter) VegaLiteToSVGWithParams(spec []byte, params map[string]any, opts ...RenderOption) (string, error) {
+	opts = c.withSource(opts, spec)
+	if err := c.checkSpecSize(spec); err != nil {
+		return "", err
+	}
+	if err := checkSpecShape(spec); err != nil {
+		return "", err
+	}
+	if c.cfg.nonFiniteNumbers {
+		spec = quoteNonFinite(spec)
+	}
+	vl, err := decodeSpec(spec)
+	if err != nil {
+		return "", err
+	}
+	if err := setParamValues(vl, params); err != nil {
+		return "", err
+	}
+	spec, err = json.Marshal(vl)
+	if err != nil {
+		return "", fmt.Errorf("aster: encoding spec: %w", err)
+	}
+	return c.VegaLiteToSVG(spec, opts...)
+}
+
+// paramScopes are the Vega-Lite properties that hold nested view specs.
+var paramScopes = []string{"layer", "hconcat", "vconcat", "concat", "spec"}
+
+// setParamValues sets the value of every param named in params, searching
+// the top level and nested view specs. It fails on the first name (in sorted
+// order) that matches no param.
+func setParamValues(vl map[string]any, params map[string]any) error {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	found := make(map[string]bool, len(params))
+	var walk func(view map[string]any)
+	walk = func(view map[string]any) {
+		list, _ := view["params"].([]any)
+		for _, p := range list {
+			param, _ := p.(map[string]any)
+			name, _ := param["name"].(string)
+			value, ok := params[name]
+			if !ok {
+				continue
+			}
+			found[name] = true
+			param["value"] = value
+		}
+		for _, key := range paramScopes {
+			switch nested := view[key].(type) {
+			case map[string]any:
+				walk(nested)
+			case []any:
+				for _, v := range nested {
+					if child, ok := v.(map[string]any); ok {
+						walk(child)
+					}
+				}
+			}
+		}
+	}
+	walk(vl)
+
+	for _, name := range names {
+		if !found[name] {
+			return fmt.Errorf("aster: param %q not found in spec", name)
+		}
+	}
+	return nil
+}
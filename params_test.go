@@ -0,0 +1,55 @@
+package aster_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mgilbir/aster"
+)
+
+func TestVegaLiteToSVGWithParams(t *testing.T) {
+	c, err := aster.New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	// The top view defines a brush over x; the bottom view shows only the
+	// brushed points.
+	spec := []byte(`{
+		"data": {"values": [{"x": 0}, {"x": 1}, {"x": 2}, {"x": 3}, {"x": 4}]},
+		"vconcat": [
+			{
+				"params": [{"name": "brush", "select": {"type": "interval", "encodings": ["x"]}}],
+				"mark": "point",
+				"encoding": {"x": {"field": "x", "type": "quantitative"}}
+			},
+			{
+				"transform": [{"filter": {"param": "brush"}}],
+				"mark": "point",
+				"encoding": {"x": {"field": "x", "type": "quantitative"}}
+			}
+		]
+	}`)
+	points := func(svg string) int { return strings.Count(svg, `aria-roledescription="point"`) }
+
+	plain, err := c.VegaLiteToSVGWithParams(spec, nil)
+	if err != nil {
+		t.Fatalf("VegaLiteToSVGWithParams: %v", err)
+	}
+	if got := points(plain); got != 10 {
+		t.Errorf("without a brush, got %d points; want all 10", got)
+	}
+
+	brushed, err := c.VegaLiteToSVGWithParams(spec, map[string]any{"brush": map[string]any{"x": []any{0.5, 2.5}}})
+	if err != nil {
+		t.Fatalf("VegaLiteToSVGWithParams: %v", err)
+	}
+	if got := points(brushed); got != 7 {
+		t.Errorf("with x in [0.5, 2.5] brushed, got %d points; want 5 + 2", got)
+	}
+
+	if _, err := c.VegaLiteToSVGWithParams(spec, map[string]any{"nope": 1}); err == nil || !strings.Contains(err.Error(), `"nope"`) {
+		t.Errorf("expected an error naming the missing param, got %v", err)
+	}
+}
@@ -0,0 +1,162 @@
+package aster
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"image/color"
+	"image/png"
+	"strconv"
+)
+
+// pointsPerCSSPixel converts CSS pixels (96 per inch) to PDF points (72 per
+// inch).
+const pointsPerCSSPixel = 72.0 / 96.0
+
+// VegaToPDF renders a Vega spec (JSON) to a single-page PDF.
+// See VegaLiteToPDF for how the page is produced.
+func (c *Converter) VegaToPDF(spec []byte, opts ...PNGOption) ([]byte, error) {
+	data, err := c.VegaToPNG(spec, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return pngToPDF(data, pdfScale(defaultRenderConfig(opts)))
+}
+
+// VegaLiteToPDF renders a Vega-Lite spec (JSON) to a single-page PDF.
+//
+// The PDF is raster-backed: the chart is rendered to PNG, exactly as by
+// VegaLiteToPNG, and embedded as an image on a page the size of the chart
+// (one CSS pixel is 1/96 inch). Text is not selectable and the image
+// resolution is 96 DPI times WithScale, so use e.g. WithDPI(300) for
+// 300 DPI print output. Vector PDF output is not yet supported.
+func (c *Converter) VegaLiteToPDF(spec []byte, opts ...PNGOption) ([]byte, error) {
+	data, err := c.VegaLiteToPNG(spec, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return pngToPDF(data, pdfScale(defaultRenderConfig(opts)))
+}
+
+// pdfScale returns the scale the PNG for a PDF was rendered at relative to
+// its page: WithScale, or 1 with WithFitWidth, whose page is the fitted
+// width.
+func pdfScale(rc *renderConfig) float64 {
+	if rc.fitWidth > 0 {
+		return 1
+	}
+	return rc.scale
+}
+
+// pngToPDF wraps a PNG rendered at scale in a one-page PDF sized to the
+// unscaled image. Transparency is kept through a soft mask.
+func pngToPDF(data []byte, scale float64) ([]byte, error) {
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("aster: decoding PNG for PDF: %w", err)
+	}
+	if scale <= 0 {
+		scale = 1
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	rgb := make([]byte, 0, w*h*3)
+	alpha := make([]byte, 0, w*h)
+	opaque := true
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			rgb = append(rgb, c.R, c.G, c.B)
+			alpha = append(alpha, c.A)
+			opaque = opaque && c.A == 0xff
+		}
+	}
+
+	pageW := formatPDFNumber(float64(w) / scale * pointsPerCSSPixel)
+	pageH := formatPDFNumber(float64(h) / scale * pointsPerCSSPixel)
+
+	pdf := &pdfWriter{}
+	pdf.buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+	pdf.object("<< /Type /Catalog /Pages 2 0 R >>", nil)
+	pdf.object("<< /Type /Pages /Kids [3 0 R] /Count 1 >>", nil)
+	pdf.object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %s %s] "+
+		"/Resources << /XObject << /Im0 4 0 R >> >> /Contents 5 0 R >>", pageW, pageH), nil)
+
+	rgbStream, err := deflate(rgb)
+	if err != nil {
+		return nil, err
+	}
+	smask := ""
+	if !opaque {
+		smask = " /SMask 6 0 R"
+	}
+	pdf.object(fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d "+
+		"/ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /FlateDecode%s /Length %d >>",
+		w, h, smask, len(rgbStream)), rgbStream)
+
+	content := fmt.Sprintf("q %s 0 0 %s 0 0 cm /Im0 Do Q", pageW, pageH)
+	pdf.object(fmt.Sprintf("<< /Length %d >>", len(content)), []byte(content))
+
+	if !opaque {
+		alphaStream, err := deflate(alpha)
+		if err != nil {
+			return nil, err
+		}
+		pdf.object(fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d "+
+			"/ColorSpace /DeviceGray /BitsPerComponent 8 /Filter /FlateDecode /Length %d >>",
+			w, h, len(alphaStream)), alphaStream)
+	}
+
+	return pdf.finish(), nil
+}
+
+// pdfWriter appends numbered objects to a PDF body and records their byte
+// offsets for the cross-reference table.
+type pdfWriter struct {
+	buf     bytes.Buffer
+	offsets []int
+}
+
+// object writes the next object (numbered from 1) with the given dictionary
+// and, if stream is non-nil, stream data.
+func (p *pdfWriter) object(dict string, stream []byte) {
+	p.offsets = append(p.offsets, p.buf.Len())
+	fmt.Fprintf(&p.buf, "%d 0 obj\n%s\n", len(p.offsets), dict)
+	if stream != nil {
+		p.buf.WriteString("stream\n")
+		p.buf.Write(stream)
+		p.buf.WriteString("\nendstream\n")
+	}
+	p.buf.WriteString("endobj\n")
+}
+
+// finish writes the cross-reference table and trailer, with the catalog as
+// object 1, and returns the document.
+func (p *pdfWriter) finish() []byte {
+	xref := p.buf.Len()
+	fmt.Fprintf(&p.buf, "xref\n0 %d\n0000000000 65535 f \n", len(p.offsets)+1)
+	for _, off := range p.offsets {
+		fmt.Fprintf(&p.buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&p.buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(p.offsets)+1, xref)
+	return p.buf.Bytes()
+}
+
+// deflate zlib-compresses data for a FlateDecode stream.
+func deflate(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zlib.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, fmt.Errorf("aster: compressing PDF image: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("aster: compressing PDF image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// formatPDFNumber formats v as a PDF real, which may not use exponents.
+func formatPDFNumber(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
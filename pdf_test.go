@@ -0,0 +1,49 @@
+package aster_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/mgilbir/aster"
+)
+
+func TestVegaLiteToPDF(t *testing.T) {
+	spec, err := os.ReadFile("testdata/bar-chart.vl.json")
+	if err != nil {
+		t.Fatalf("reading test spec: %v", err)
+	}
+
+	c, err := aster.New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	svg, err := c.VegaLiteToSVG(spec)
+	if err != nil {
+		t.Fatalf("VegaLiteToSVG: %v", err)
+	}
+	w, h := svgSize(t, svg)
+
+	// The page is the chart's size in points regardless of scale; only the
+	// embedded image resolution changes.
+	for _, scale := range []float64{1, 2} {
+		pdf, err := c.VegaLiteToPDF(spec, aster.WithScale(scale))
+		if err != nil {
+			t.Fatalf("VegaLiteToPDF scale %v: %v", scale, err)
+		}
+		if !bytes.HasPrefix(pdf, []byte("%PDF-")) || !bytes.HasSuffix(pdf, []byte("%%EOF\n")) {
+			t.Fatalf("scale %v: output is not a PDF: %.40q", scale, pdf)
+		}
+		mediaBox := fmt.Sprintf("/MediaBox [0 0 %v %v]", w*0.75, h*0.75)
+		if !bytes.Contains(pdf, []byte(mediaBox)) {
+			t.Errorf("scale %v: expected %s", scale, mediaBox)
+		}
+		image := fmt.Sprintf("/Width %v /Height %v", w*scale, h*scale)
+		if !bytes.Contains(pdf, []byte(image)) {
+			t.Errorf("scale %v: expected image %s", scale, image)
+		}
+	}
+}
@@ -2,16 +2,21 @@ package aster_test
 
 import (
 	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"image"
+	"image/color"
 	"image/png"
 	"math"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/mgilbir/aster"
+	"github.com/mgilbir/aster/internal/textmeasure/fonts/dejavu"
 )
 
 func TestSVGToPNG(t *testing.T) {
@@ -75,6 +80,41 @@ func TestVegaLiteToPNG(t *testing.T) {
 	}
 }
 
+func TestVegaLiteToSVGAndPNG(t *testing.T) {
+	spec, err := os.ReadFile("testdata/bar-chart.vl.json")
+	if err != nil {
+		t.Fatalf("reading test spec: %v", err)
+	}
+
+	var renders int
+	c, err := aster.New(aster.WithMetrics(func(aster.RenderMetrics) { renders++ }))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	svg, data, err := c.VegaLiteToSVGAndPNG(spec, aster.WithScale(2), aster.WithSVGTitle("Bars"))
+	if err != nil {
+		t.Fatalf("VegaLiteToSVGAndPNG: %v", err)
+	}
+	if renders != 1 {
+		t.Errorf("expected one render, got %d", renders)
+	}
+	if !strings.HasPrefix(svg, "<svg") || !strings.Contains(svg, "<title>Bars</title>") {
+		t.Errorf("expected SVG output with the title: %.200s", svg)
+	}
+
+	// The PNG is the same render at twice the SVG's size.
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("png.Decode: %v", err)
+	}
+	w, h := svgSize(t, svg)
+	if b := img.Bounds(); b.Dx() != int(math.Round(2*w)) || b.Dy() != int(math.Round(2*h)) {
+		t.Errorf("expected %vx%v at scale 2, got %dx%d", 2*w, 2*h, b.Dx(), b.Dy())
+	}
+}
+
 func TestVegaLiteToPNGScale(t *testing.T) {
 	spec, err := os.ReadFile("testdata/bar-chart.vl.json")
 	if err != nil {
@@ -114,6 +154,305 @@ func TestVegaLiteToPNGScale(t *testing.T) {
 	}
 }
 
+func TestVegaLiteToPNGMulti(t *testing.T) {
+	spec, err := os.ReadFile("testdata/bar-chart.vl.json")
+	if err != nil {
+		t.Fatalf("reading test spec: %v", err)
+	}
+
+	c, err := aster.New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	single, err := c.VegaLiteToPNG(spec)
+	if err != nil {
+		t.Fatalf("VegaLiteToPNG: %v", err)
+	}
+	w, h := pngSize(t, single)
+
+	pngs, err := c.VegaLiteToPNGMulti(spec, []float64{0.5, 1, 2, 2})
+	if err != nil {
+		t.Fatalf("VegaLiteToPNGMulti: %v", err)
+	}
+	if len(pngs) != 3 {
+		t.Fatalf("expected one PNG per distinct scale, got %d", len(pngs))
+	}
+	for scale, data := range pngs {
+		gw, gh := pngSize(t, data)
+		if want := int(float64(w)*scale + 0.5); gw != want {
+			t.Errorf("scale %v: width %d, want %d (height %d of %d)", scale, gw, want, gh, h)
+		}
+	}
+	if !bytes.Equal(pngs[1], single) {
+		t.Error("the 1x PNG should match VegaLiteToPNG")
+	}
+
+	if _, err := c.VegaLiteToPNGMulti(spec, []float64{1, 0}); err == nil {
+		t.Error("expected an error for a zero scale")
+	}
+}
+
+func TestVegaLiteToPNGFractionalScale(t *testing.T) {
+	spec, err := os.ReadFile("testdata/bar-chart.vl.json")
+	if err != nil {
+		t.Fatalf("reading test spec: %v", err)
+	}
+
+	c, err := aster.New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	size := func(opts ...aster.PNGOption) (int, int) {
+		t.Helper()
+		data, err := c.VegaLiteToPNG(spec, opts...)
+		if err != nil {
+			t.Fatalf("VegaLiteToPNG: %v", err)
+		}
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("png.Decode: %v", err)
+		}
+		return img.Bounds().Dx(), img.Bounds().Dy()
+	}
+
+	w1, h1 := size()
+	for _, scale := range []float64{1.5, 0.75} {
+		wantW := int(math.Floor(float64(w1)*scale + 0.5))
+		wantH := int(math.Floor(float64(h1)*scale + 0.5))
+		if w, h := size(aster.WithScale(scale)); w != wantW || h != wantH {
+			t.Errorf("scale %v: got %dx%d, want %dx%d (1x is %dx%d)", scale, w, h, wantW, wantH, w1, h1)
+		}
+	}
+}
+
+func TestSVGToPNGWithRenderLanguages(t *testing.T) {
+	svg := `<svg xmlns="http://www.w3.org/2000/svg" width="4" height="4"><switch>
+		<rect systemLanguage="ja" width="4" height="4" fill="#ff0000"/>
+		<rect width="4" height="4" fill="#0000ff"/>
+	</switch></svg>`
+
+	c, err := aster.New(
+		aster.WithTextMeasurement(false),
+		aster.WithRenderLanguages([]string{"ja", "en"}),
+	)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	data, err := c.SVGToPNG(svg)
+	if errors.Is(err, errors.ErrUnsupported) {
+		t.Skipf("embedded resvg.wasm predates set_languages: %v", err)
+	}
+	if err != nil {
+		t.Fatalf("SVGToPNG: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("png.Decode: %v", err)
+	}
+	if got, want := color.NRGBAModel.Convert(img.At(2, 2)).(color.NRGBA), (color.NRGBA{0xff, 0, 0, 0xff}); got != want {
+		t.Errorf("drew %v; want the systemLanguage=ja branch %v", got, want)
+	}
+}
+
+func TestWithResvgSansFamily(t *testing.T) {
+	text := func(family string) string {
+		return `<svg xmlns="http://www.w3.org/2000/svg" width="80" height="20">
+			<text x="0" y="15" font-family="` + family + `" font-size="12">Wiggle</text>
+		</svg>`
+	}
+
+	c, err := aster.New(
+		aster.WithTextMeasurement(false),
+		aster.WithResvgSansFamily("Liberation Mono"),
+	)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	sans, err := c.SVGToPNG(text("sans-serif"))
+	if err != nil {
+		t.Fatalf("SVGToPNG sans-serif: %v", err)
+	}
+	mono, err := c.SVGToPNG(text("monospace"))
+	if err != nil {
+		t.Fatalf("SVGToPNG monospace: %v", err)
+	}
+	if !bytes.Equal(sans, mono) {
+		t.Error("sans-serif should rasterize with Liberation Mono")
+	}
+}
+
+func TestWithResvgSerifFamily(t *testing.T) {
+	text := func(family string) string {
+		return `<svg xmlns="http://www.w3.org/2000/svg" width="80" height="20">
+			<text x="0" y="15" font-family="` + family + `" font-size="12">Wiggle</text>
+		</svg>`
+	}
+
+	c, err := aster.New(
+		aster.WithTextMeasurement(false),
+		aster.WithResvgSerifFamily("Liberation Mono"),
+	)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	serif, err := c.SVGToPNG(text("serif"))
+	if err != nil {
+		t.Fatalf("SVGToPNG serif: %v", err)
+	}
+	mono, err := c.SVGToPNG(text("monospace"))
+	if err != nil {
+		t.Fatalf("SVGToPNG monospace: %v", err)
+	}
+	if !bytes.Equal(serif, mono) {
+		t.Error("serif should rasterize with Liberation Mono")
+	}
+}
+
+func TestVegaLiteToPNGBackground(t *testing.T) {
+	spec := []byte(`{
+		"background": "#eee",
+		"data": {"values": [{"a": 1}]},
+		"mark": "point",
+		"encoding": {"x": {"field": "a", "type": "quantitative"}}
+	}`)
+
+	c, err := aster.New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	data, err := c.VegaLiteToPNG(spec)
+	if err != nil {
+		t.Fatalf("VegaLiteToPNG: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("png.Decode: %v", err)
+	}
+
+	b := img.Bounds()
+	corners := []image.Point{
+		b.Min,
+		{b.Max.X - 1, b.Min.Y},
+		{b.Min.X, b.Max.Y - 1},
+		{b.Max.X - 1, b.Max.Y - 1},
+	}
+	want := color.NRGBA{0xee, 0xee, 0xee, 0xff}
+	for _, p := range corners {
+		if got := color.NRGBAModel.Convert(img.At(p.X, p.Y)); got != want {
+			t.Errorf("corner %v = %v, want %v", p, got, want)
+		}
+	}
+}
+
+func TestSVGToPNGFontAlias(t *testing.T) {
+	svgFor := func(family string) string {
+		return `<svg xmlns="http://www.w3.org/2000/svg" width="80" height="20"><text x="0" y="15" font-family="` + family + `" font-size="12">iiiiii</text></svg>`
+	}
+
+	c, err := aster.New(
+		aster.WithTextMeasurement(false),
+		aster.WithFontAlias("Helvetica Neue", "Liberation Mono"),
+	)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	aliased, err := c.SVGToPNG(svgFor("Helvetica Neue"))
+	if err != nil {
+		t.Fatalf("SVGToPNG aliased: %v", err)
+	}
+	direct, err := c.SVGToPNG(svgFor("Liberation Mono"))
+	if err != nil {
+		t.Fatalf("SVGToPNG direct: %v", err)
+	}
+	if !bytes.Equal(aliased, direct) {
+		t.Error("expected aliased family to rasterize like Liberation Mono")
+	}
+}
+
+func TestWithoutEmbeddedFonts(t *testing.T) {
+	if _, err := aster.New(aster.WithoutEmbeddedFonts()); err == nil {
+		t.Error("expected New to fail with text measurement and no fonts")
+	}
+
+	svg := `<svg xmlns="http://www.w3.org/2000/svg" width="80" height="20"><text x="0" y="15" font-family="Liberation Sans" font-size="12">Wiggle</text></svg>`
+
+	plain, err := aster.New(aster.WithTextMeasurement(false))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = plain.Close() }()
+	strict, err := aster.New(
+		aster.WithoutEmbeddedFonts(),
+		aster.WithFont("DejaVu Sans", dejavu.SansRegular),
+	)
+	if err != nil {
+		t.Fatalf("New strict: %v", err)
+	}
+	defer func() { _ = strict.Close() }()
+
+	want, err := plain.SVGToPNG(svg)
+	if err != nil {
+		t.Fatalf("SVGToPNG: %v", err)
+	}
+	got, err := strict.SVGToPNG(svg)
+	if err != nil {
+		t.Fatalf("SVGToPNG strict: %v", err)
+	}
+	if bytes.Equal(got, want) {
+		t.Error("expected Liberation Sans to be unavailable to the PNG renderer")
+	}
+}
+
+func TestSVGToPNGConcurrent(t *testing.T) {
+	c, err := aster.New(aster.WithTextMeasurement(false))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 1; i <= 8; i++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			svg := fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="10"><rect width="%d" height="10"/></svg>`, w, w)
+			data, err := c.SVGToPNG(svg)
+			if err != nil {
+				errs <- err
+				return
+			}
+			img, err := png.Decode(bytes.NewReader(data))
+			if err != nil {
+				errs <- err
+				return
+			}
+			if got := img.Bounds().Dx(); got != w {
+				errs <- fmt.Errorf("width %d: got %d", w, got)
+			}
+		}(i * 10)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
 func TestSVGToPNGError(t *testing.T) {
 	c, err := aster.New(aster.WithTextMeasurement(false))
 	if err != nil {
@@ -259,3 +598,184 @@ func TestVLConvertPNGSpecs(t *testing.T) {
 		})
 	}
 }
+
+func TestSVGToPNGResvgError(t *testing.T) {
+	c, err := aster.New(aster.WithTextMeasurement(false))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	_, err = c.SVGToPNG(`<svg xmlns="http://www.w3.org/2000/svg" width="4" height="4"><g><rect/></circle></g></svg>`)
+	var re *aster.ResvgError
+	if !errors.As(err, &re) {
+		t.Fatalf("expected a *ResvgError, got %T: %v", err, err)
+	}
+	if !strings.Contains(re.Message, "'circle'") {
+		t.Errorf("expected the offending element in the message, got %q", re.Message)
+	}
+}
+
+func TestWithTrim(t *testing.T) {
+	c, err := aster.New(aster.WithTextMeasurement(false))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	for _, tc := range []struct {
+		name, background string
+	}{
+		{"transparent", ""},
+		{"background", `<rect width="100" height="60" fill="#ffeecc"/>`},
+	} {
+		svg := `<svg xmlns="http://www.w3.org/2000/svg" width="100" height="60">` + tc.background +
+			`<rect x="20" y="10" width="30" height="20" fill="black"/></svg>`
+		data, err := c.SVGToPNG(svg, aster.WithTrim())
+		if err != nil {
+			t.Fatalf("%s: SVGToPNG: %v", tc.name, err)
+		}
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("%s: decoding PNG: %v", tc.name, err)
+		}
+		if b := img.Bounds(); b.Dx() != 30 || b.Dy() != 20 {
+			t.Errorf("%s: trimmed to %dx%d; want 30x20", tc.name, b.Dx(), b.Dy())
+		}
+	}
+
+	spec := []byte(`{
+		"padding": 40,
+		"data": {"values": [{"a": "x", "b": 1}, {"a": "y", "b": 2}]},
+		"mark": "bar",
+		"encoding": {"x": {"field": "a", "type": "nominal"}, "y": {"field": "b", "type": "quantitative"}}
+	}`)
+	full, err := c.VegaLiteToPNG(spec)
+	if err != nil {
+		t.Fatalf("VegaLiteToPNG: %v", err)
+	}
+	trimmed, err := c.VegaLiteToPNG(spec, aster.WithTrim())
+	if err != nil {
+		t.Fatalf("VegaLiteToPNG with trim: %v", err)
+	}
+	fw, fh := pngSize(t, full)
+	tw, th := pngSize(t, trimmed)
+	if tw >= fw || th >= fh {
+		t.Errorf("trimmed %dx%d should be smaller than %dx%d", tw, th, fw, fh)
+	}
+}
+
+// pngSize returns the pixel dimensions of a PNG image.
+func pngSize(t *testing.T, data []byte) (int, int) {
+	t.Helper()
+	cfg, err := png.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("png.DecodeConfig: %v", err)
+	}
+	return cfg.Width, cfg.Height
+}
+
+func TestWithDPI(t *testing.T) {
+	svg := `<svg xmlns="http://www.w3.org/2000/svg" width="96" height="48">
+		<rect width="96" height="48" fill="steelblue"/>
+	</svg>`
+
+	c, err := aster.New(aster.WithTextMeasurement(false))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	for _, opts := range [][]aster.PNGOption{
+		{aster.WithDPI(300)},
+		{aster.WithDPI(300), aster.WithTrim()},
+	} {
+		data, err := c.SVGToPNG(svg, opts...)
+		if err != nil {
+			t.Fatalf("SVGToPNG: %v", err)
+		}
+		if w, h := pngSize(t, data); w != 300 || h != 150 {
+			t.Errorf("expected 300x150 at 300 DPI, got %dx%d", w, h)
+		}
+		x, y, unit, ok := pngPhys(t, data)
+		if !ok {
+			t.Fatal("expected a pHYs chunk")
+		}
+		// 300 DPI is 11811 pixels per meter.
+		if x != 11811 || y != 11811 || unit != 1 {
+			t.Errorf("pHYs = %d x %d per unit %d; want 11811 x 11811 per meter", x, y, unit)
+		}
+	}
+
+	plain, err := c.SVGToPNG(svg, aster.WithScale(2))
+	if err != nil {
+		t.Fatalf("SVGToPNG: %v", err)
+	}
+	if _, _, _, ok := pngPhys(t, plain); ok {
+		t.Error("WithScale alone should not add a pHYs chunk")
+	}
+}
+
+// pngPhys reads the pHYs chunk of a PNG: pixels per unit on each axis and
+// the unit (1 for meters). It reports false if there is none.
+func pngPhys(t *testing.T, data []byte) (x, y uint32, unit byte, ok bool) {
+	t.Helper()
+	if _, err := png.Decode(bytes.NewReader(data)); err != nil {
+		t.Fatalf("png.Decode: %v", err)
+	}
+	for rest := data[8:]; len(rest) >= 12; {
+		n := binary.BigEndian.Uint32(rest)
+		kind, body := string(rest[4:8]), rest[8:8+n]
+		if kind == "pHYs" {
+			return binary.BigEndian.Uint32(body), binary.BigEndian.Uint32(body[4:]), body[8], true
+		}
+		rest = rest[12+n:]
+	}
+	return 0, 0, 0, false
+}
+
+func TestWithAntialiasing(t *testing.T) {
+	svg := `<svg xmlns="http://www.w3.org/2000/svg" width="40" height="40">
+		<circle cx="20" cy="20" r="15" fill="black"/>
+	</svg>`
+
+	c, err := aster.New(aster.WithTextMeasurement(false))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	// alphas counts the distinct alpha values in a PNG: edge smoothing
+	// adds partially transparent pixels around the opaque circle.
+	alphas := func(opts ...aster.PNGOption) int {
+		data, err := c.SVGToPNG(svg, opts...)
+		if err != nil {
+			t.Fatalf("SVGToPNG: %v", err)
+		}
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("png.Decode: %v", err)
+		}
+		seen := make(map[uint32]bool)
+		b := img.Bounds()
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			for x := b.Min.X; x < b.Max.X; x++ {
+				_, _, _, a := img.At(x, y).RGBA()
+				seen[a] = true
+			}
+		}
+		return len(seen)
+	}
+
+	if n := alphas(); n <= 2 {
+		t.Errorf("default rendering has %d alpha levels; expected smoothed edges", n)
+	}
+	if n := alphas(aster.WithAntialiasing(aster.AntialiasAll)); n <= 2 {
+		t.Errorf("AntialiasAll has %d alpha levels; expected smoothed edges", n)
+	}
+	for _, mode := range []aster.Antialiasing{aster.AntialiasText, aster.AntialiasNone} {
+		if n := alphas(aster.WithAntialiasing(mode)); n != 2 {
+			t.Errorf("mode %d has %d alpha levels; want 2 (transparent and opaque)", mode, n)
+		}
+	}
+}
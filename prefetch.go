@@ -0,0 +1,141 @@
+package aster
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/mgilbir/aster/internal/runtime"
+)
+
+// prefetchLoader serves the data fetched ahead of a render by WithPrefetch
+// and passes every other call to the wrapped Loader. The QuickJS runtime
+// resolves loads one at a time, so fetching a spec's datasets concurrently
+// beforehand is what parallelizes the network.
+type prefetchLoader struct {
+	Loader
+
+	mu   sync.Mutex
+	data map[string][]byte // sanitized URI → data, for the current render
+}
+
+func (l *prefetchLoader) Load(ctx context.Context, uri string) ([]byte, error) {
+	l.mu.Lock()
+	data, ok := l.data[uri]
+	l.mu.Unlock()
+	if ok {
+		return data, nil
+	}
+	return l.Loader.Load(ctx, uri)
+}
+
+// fetch sanitizes and loads urls through the wrapped Loader, at most
+// workers at a time, and keeps what loads for the next render. Failures are
+// not kept: the render loads that resource itself and reports the error.
+func (l *prefetchLoader) fetch(ctx context.Context, urls []string, workers int) {
+	data := make(map[string][]byte, len(urls))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, workers)
+	for _, u := range urls {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			href, err := l.Loader.Sanitize(ctx, u)
+			if err != nil {
+				return
+			}
+			d, err := l.Loader.Load(ctx, href)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			data[href] = d
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	l.mu.Lock()
+	l.data = data
+	l.mu.Unlock()
+}
+
+// clear drops the prefetched data once a render is done with it.
+func (l *prefetchLoader) clear() {
+	l.mu.Lock()
+	l.data = nil
+	l.mu.Unlock()
+}
+
+// prefetchVegaLite fetches the data a prepared Vega-Lite spec references,
+// compiling it to find the URLs, when WithPrefetch is set.
+func (c *Converter) prefetchVegaLite(spec []byte) error {
+	if c.prefetcher == nil {
+		return nil
+	}
+	vgSpec, err := c.rt.VegaLiteToVega(string(spec))
+	if err != nil {
+		return nil // the render reports it
+	}
+	return c.prefetchVega([]byte(vgSpec))
+}
+
+// prefetchVega fetches the data a prepared Vega spec references, when
+// WithPrefetch is set. The loads share the per-render state of the next
+// eval, so a BudgetLoader counts them against that render, and the time they
+// take counts against its WithTimeout. A spec with more URLs than
+// WithMaxRequests allows fails before anything is fetched.
+func (c *Converter) prefetchVega(spec []byte) error {
+	if c.prefetcher == nil {
+		return nil
+	}
+	vg, err := decodeSpec(spec)
+	if err != nil {
+		return nil // the render reports it
+	}
+	urls := vegaDataURLs(vg)
+	if len(urls) == 0 {
+		return nil
+	}
+	for i, u := range urls {
+		urls[i] = resolveDataBaseURL(c.cfg.dataBaseURL, u)
+	}
+	if err := c.checkRequestCount(urls); err != nil {
+		return err
+	}
+	ctx := runtime.ContextWithScope(context.Background(), c.rt.ReserveScope())
+	if c.cfg.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.cfg.timeout)
+		defer cancel()
+	}
+	c.prefetcher.fetch(ctx, urls, c.cfg.prefetchWorkers)
+	return nil
+}
+
+// absoluteDataURL matches the URIs bridge.js leaves alone when applying
+// WithDataBaseURL, like Vega's own loader.
+var absoluteDataURL = regexp.MustCompile(`^(data:|([A-Za-z]+:)?//)`)
+
+// resolveDataBaseURL prefixes a relative uri with base as bridge.js does, so
+// prefetched data is keyed by the URI the render asks for.
+func resolveDataBaseURL(base, uri string) string {
+	if base == "" || absoluteDataURL.MatchString(uri) {
+		return uri
+	}
+	if !strings.HasPrefix(uri, "/") && !strings.HasSuffix(base, "/") {
+		uri = "/" + uri
+	}
+	return base + uri
+}
+
+// clearPrefetched releases the data prefetched for a render.
+func (c *Converter) clearPrefetched() {
+	if c.prefetcher != nil {
+		c.prefetcher.clear()
+	}
+}
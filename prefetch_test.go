@@ -0,0 +1,101 @@
+package aster_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mgilbir/aster"
+)
+
+// barrierLoader holds each Load until n loads are in flight at once, or a
+// short wait expires, and records the peak concurrency and total loads.
+type barrierLoader struct {
+	n int
+
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+	loads    int
+	arrived  chan struct{}
+}
+
+func (l *barrierLoader) Sanitize(_ context.Context, uri string) (string, error) {
+	return uri, nil
+}
+
+func (l *barrierLoader) Load(_ context.Context, _ string) ([]byte, error) {
+	l.mu.Lock()
+	l.loads++
+	l.inFlight++
+	l.peak = max(l.peak, l.inFlight)
+	if l.inFlight == l.n {
+		close(l.arrived)
+	}
+	l.mu.Unlock()
+
+	select {
+	case <-l.arrived:
+	case <-time.After(500 * time.Millisecond):
+	}
+
+	l.mu.Lock()
+	l.inFlight--
+	l.mu.Unlock()
+	return []byte(`[{"a": 1}]`), nil
+}
+
+func TestWithPrefetch(t *testing.T) {
+	spec := []byte(`{
+		"data": [
+			{"name": "a", "url": "a.json"},
+			{"name": "b", "url": "b.json"},
+			{"name": "c", "url": "c.json"}
+		],
+		"marks": []
+	}`)
+
+	loader := &barrierLoader{n: 3, arrived: make(chan struct{})}
+	c, err := aster.New(aster.WithLoader(loader), aster.WithPrefetch(3))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	if _, err := c.VegaToSVG(spec); err != nil {
+		t.Fatalf("VegaToSVG: %v", err)
+	}
+	if loader.peak != 3 {
+		t.Errorf("expected all 3 datasets fetched concurrently, peak was %d", loader.peak)
+	}
+	if loader.loads != 3 {
+		t.Errorf("expected the render to be served from the prefetch, got %d loads", loader.loads)
+	}
+}
+
+func TestWithPrefetchMaxRequests(t *testing.T) {
+	spec := []byte(`{
+		"data": [
+			{"name": "a", "url": "a.json"},
+			{"name": "b", "url": "b.json"},
+			{"name": "c", "url": "c.json"}
+		],
+		"marks": []
+	}`)
+
+	loader := &barrierLoader{n: 3, arrived: make(chan struct{})}
+	c, err := aster.New(aster.WithLoader(loader), aster.WithPrefetch(3), aster.WithMaxRequests(2))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	if _, err := c.VegaToSVG(spec); !errors.Is(err, aster.ErrLimitExceeded) {
+		t.Fatalf("expected ErrLimitExceeded, got %v", err)
+	}
+	if loader.loads != 0 {
+		t.Errorf("expected nothing fetched past WithMaxRequests, got %d loads", loader.loads)
+	}
+}
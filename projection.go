@@ -0,0 +1,109 @@
+package aster
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// vegaLiteSVG renders a prepared Vega-Lite spec to SVG. With projection fits
+// configured the spec is compiled first, so that the fits can be applied to
+// the compiled projections; otherwise it is rendered in one runtime call.
+func (c *Converter) vegaLiteSVG(spec []byte) (string, error) {
+	defer c.clearPrefetched()
+	if len(c.cfg.projectionFits) == 0 {
+		if err := c.prefetchVegaLite(spec); err != nil {
+			return "", err
+		}
+		return c.rt.VegaLiteToSVG(string(spec))
+	}
+	vgSpec, err := c.rt.VegaLiteToVega(string(spec))
+	if err != nil {
+		return "", err
+	}
+	vg, err := decodeSpec([]byte(vgSpec))
+	if err != nil {
+		return "", err
+	}
+	if err := c.applyProjectionFits(vg); err != nil {
+		return "", err
+	}
+	out, err := json.Marshal(vg)
+	if err != nil {
+		return "", fmt.Errorf("aster: encoding spec: %w", err)
+	}
+	if err := c.prefetchVega(out); err != nil {
+		return "", err
+	}
+	return c.rt.VegaToSVG(string(out))
+}
+
+// applyProjectionFits replaces the fit of every projection named by
+// WithProjectionFit, searching the top level and group marks. It fails on
+// the first name (in sorted order) that matches no projection.
+func (c *Converter) applyProjectionFits(vg map[string]any) error {
+	fits := c.cfg.projectionFits
+	names := make([]string, 0, len(fits))
+	for name, extent := range fits {
+		if len(extent) != 2 {
+			return fmt.Errorf("aster: projection %q fit extent needs 2 corners, got %d", name, len(extent))
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	found := make(map[string]bool, len(fits))
+	var walk func(scope map[string]any)
+	walk = func(scope map[string]any) {
+		projections, _ := scope["projections"].([]any)
+		for _, p := range projections {
+			projection, _ := p.(map[string]any)
+			name, _ := projection["name"].(string)
+			extent, ok := fits[name]
+			if !ok {
+				continue
+			}
+			found[name] = true
+			projection["fit"] = boundsFeature(extent[0], extent[1])
+		}
+		marks, _ := scope["marks"].([]any)
+		for _, m := range marks {
+			if mark, ok := m.(map[string]any); ok {
+				walk(mark)
+			}
+		}
+	}
+	walk(vg)
+
+	for _, name := range names {
+		if !found[name] {
+			return fmt.Errorf("aster: projection %q not found in compiled spec", name)
+		}
+	}
+	return nil
+}
+
+// boundsFeature returns a GeoJSON feature spanning the longitude/latitude
+// box with corners a and b. It is a set of points rather than a polygon so
+// that it has no winding order to get wrong; the edge midpoints are included
+// because projected edges need not be straight.
+func boundsFeature(a, b [2]float64) map[string]any {
+	lon0, lon1 := min(a[0], b[0]), max(a[0], b[0])
+	lat0, lat1 := min(a[1], b[1]), max(a[1], b[1])
+	lonMid, latMid := (lon0+lon1)/2, (lat0+lat1)/2
+	var coords []any
+	for _, p := range [][2]float64{
+		{lon0, lat0}, {lonMid, lat0}, {lon1, lat0}, {lon1, latMid},
+		{lon1, lat1}, {lonMid, lat1}, {lon0, lat1}, {lon0, latMid},
+	} {
+		coords = append(coords, []any{p[0], p[1]})
+	}
+	return map[string]any{
+		"type":       "Feature",
+		"properties": map[string]any{},
+		"geometry": map[string]any{
+			"type":        "MultiPoint",
+			"coordinates": coords,
+		},
+	}
+}
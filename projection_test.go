@@ -0,0 +1,68 @@
+package aster_test
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/mgilbir/aster"
+)
+
+func TestWithProjectionFit(t *testing.T) {
+	points := func(values string) []byte {
+		return []byte(`{
+			"width": 200, "height": 200,
+			"data": {"values": ` + values + `},
+			"projection": {"type": "mercator"},
+			"mark": "circle",
+			"encoding": {
+				"longitude": {"field": "lon", "type": "quantitative"},
+				"latitude": {"field": "lat", "type": "quantitative"}
+			}
+		}`)
+	}
+	near := points(`[{"lon": 0, "lat": 0}, {"lon": 10, "lat": 10}]`)
+	far := points(`[{"lon": 0, "lat": 0}, {"lon": 50, "lat": 40}]`)
+
+	// origin returns the position of the first symbol, the point (0, 0).
+	symbolRe := regexp.MustCompile(`class="mark-symbol[^"]*".*?transform="translate\(([^)]+)\)"`)
+	origin := func(t *testing.T, c *aster.Converter, spec []byte) string {
+		t.Helper()
+		svg, err := c.VegaLiteToSVG(spec)
+		if err != nil {
+			t.Fatalf("VegaLiteToSVG: %v", err)
+		}
+		m := symbolRe.FindStringSubmatch(svg)
+		if m == nil {
+			t.Fatalf("no symbol in SVG:\n%s", svg)
+		}
+		return m[1]
+	}
+
+	plain, err := aster.New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = plain.Close() }()
+	if origin(t, plain, near) == origin(t, plain, far) {
+		t.Fatal("maps fitted to different data should place the origin differently")
+	}
+
+	c, err := aster.New(aster.WithProjectionFit("projection", [][2]float64{{-10, -10}, {60, 60}}))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+	if a, b := origin(t, c, near), origin(t, c, far); a != b {
+		t.Errorf("maps with a shared fit extent should place the origin identically: %s vs %s", a, b)
+	}
+
+	missing, err := aster.New(aster.WithProjectionFit("inset", [][2]float64{{0, 0}, {1, 1}}))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = missing.Close() }()
+	if _, err := missing.VegaLiteToSVG(near); err == nil || !strings.Contains(err.Error(), `projection "inset" not found`) {
+		t.Errorf("expected a missing projection error, got %v", err)
+	}
+}
@@ -0,0 +1,67 @@
+package aster
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"runtime/debug"
+
+	"github.com/mgilbir/aster/internal/runtime"
+)
+
+// modulePath is the import path of this module, looked up in the build info
+// for WithSourceMetadata.
+const modulePath = "github.com/mgilbir/aster"
+
+// withSource records spec as the render's input for WithSourceMetadata. The
+// first spec recorded wins, so a method that rewrites its spec and renders
+// it through another keeps the caller's original.
+func (c *Converter) withSource(opts []RenderOption, spec []byte) []RenderOption {
+	if !c.cfg.sourceMetadata {
+		return opts
+	}
+	return append(opts[:len(opts):len(opts)], func(rc *renderConfig) {
+		if rc.source == nil {
+			rc.source = spec
+		}
+	})
+}
+
+// sourceMetadata builds the <metadata> element of WithSourceMetadata, or ""
+// if it is not set.
+func (c *Converter) sourceMetadata(rc *renderConfig) string {
+	if !c.cfg.sourceMetadata || rc.source == nil {
+		return ""
+	}
+	sum := sha256.Sum256(rc.source)
+	var vega, vegaLite string
+	if sets, err := runtime.AvailableVersions(); err == nil {
+		set := sets[c.rt.Version()]
+		vega, vegaLite = set.VegaVersion, set.VegaLiteVersion
+	}
+	return fmt.Sprintf(`<metadata><aster:source xmlns:aster="https://%s" spec-sha256="%s" aster-version="%s" vega-version="%s" vega-lite-version="%s"/></metadata>`,
+		modulePath, hex.EncodeToString(sum[:]), html.EscapeString(moduleVersion()),
+		html.EscapeString(vega), html.EscapeString(vegaLite))
+}
+
+// moduleVersion returns the version of this module in the running binary,
+// or "(devel)" when it is built from a checkout.
+func moduleVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "(devel)"
+	}
+	if info.Main.Path == modulePath {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			if dep.Replace != nil {
+				return dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+	return "(devel)"
+}
@@ -0,0 +1,55 @@
+package aster
+
+import "context"
+
+// renderLoader is the Loader the runtime calls. It passes each call to the
+// Converter's loader, or to the loader a render passed with WithRenderLoader
+// while that render runs.
+type renderLoader struct {
+	base     Loader
+	override Loader // nil outside a render with WithRenderLoader
+}
+
+func (l *renderLoader) current() Loader {
+	if l.override != nil {
+		return l.override
+	}
+	return l.base
+}
+
+func (l *renderLoader) Load(ctx context.Context, uri string) ([]byte, error) {
+	return l.current().Load(ctx, uri)
+}
+
+func (l *renderLoader) Sanitize(ctx context.Context, uri string) (string, error) {
+	return l.current().Sanitize(ctx, uri)
+}
+
+// useRenderOptions installs the Converter state that render options in opts
+// change for a single render, the WithRenderLoader loader, the
+// WithRenderDefaultFont family and WithRenderTextMeasurement, and returns
+// the func that restores it.
+func (c *Converter) useRenderOptions(opts []RenderOption) func() {
+	rc := defaultRenderConfig(opts)
+	var restore []func()
+	if rc.loader != nil {
+		c.renderLoader.override = rc.loader
+		restore = append(restore, func() { c.renderLoader.override = nil })
+	}
+	if rc.defaultFont != "" && c.measurer != nil {
+		prev := c.measurer.SetDefaultFontFamily(rc.defaultFont)
+		restore = append(restore, func() { c.measurer.SetDefaultFontFamily(prev) })
+	}
+	if rc.estimateText && c.measurer != nil {
+		// A failure here means the runtime is unusable, which the render
+		// itself reports.
+		if err := c.rt.SetTextMeasurement(false); err == nil {
+			restore = append(restore, func() { _ = c.rt.SetTextMeasurement(true) })
+		}
+	}
+	return func() {
+		for _, fn := range restore {
+			fn()
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package aster
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ListResources compiles a Vega-Lite spec to Vega and returns the distinct
+// data URLs it references, in declaration order, without loading any of
+// them. Top-level datasets, lookup sources, and datasets nested in group
+// marks (facets) are all included. URLs computed from signals are not
+// resolvable statically and are omitted.
+//
+// Operators can use the result to audit or allowlist an untrusted spec's
+// external requests before rendering it.
+func (c *Converter) ListResources(spec []byte) ([]string, error) {
+	vgSpec, err := c.compileVegaLite(spec)
+	if err != nil {
+		return nil, err
+	}
+	var vg map[string]any
+	if err := json.Unmarshal(vgSpec, &vg); err != nil {
+		return nil, fmt.Errorf("aster: parsing compiled spec: %w", err)
+	}
+	return vegaDataURLs(vg), nil
+}
+
+// vegaDataURLs walks a Vega spec's data definitions, including those nested
+// in group marks, and returns the distinct static url values.
+func vegaDataURLs(vg map[string]any) []string {
+	var urls []string
+	seen := make(map[string]bool)
+
+	var walk func(scope map[string]any)
+	walk = func(scope map[string]any) {
+		data, _ := scope["data"].([]any)
+		for _, d := range data {
+			def, _ := d.(map[string]any)
+			if u, ok := def["url"].(string); ok && !seen[u] {
+				seen[u] = true
+				urls = append(urls, u)
+			}
+		}
+		marks, _ := scope["marks"].([]any)
+		for _, m := range marks {
+			if mark, ok := m.(map[string]any); ok {
+				walk(mark)
+			}
+		}
+	}
+	walk(vg)
+	return urls
+}
@@ -0,0 +1,50 @@
+package aster_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/mgilbir/aster"
+)
+
+func TestListResources(t *testing.T) {
+	spec := []byte(`{
+		"data": {"url": "data/lookup_groups.csv"},
+		"transform": [{
+			"lookup": "person",
+			"from": {
+				"data": {"url": "data/lookup_people.csv"},
+				"key": "name",
+				"fields": ["age"]
+			}
+		}],
+		"facet": {"row": {"field": "group", "type": "nominal"}},
+		"spec": {
+			"mark": "bar",
+			"encoding": {
+				"x": {"field": "person", "type": "nominal"},
+				"y": {"field": "age", "type": "quantitative"}
+			}
+		}
+	}`)
+
+	// The default DenyLoader is fine: nothing is loaded.
+	c, err := aster.New(aster.WithTextMeasurement(false))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	urls, err := c.ListResources(spec)
+	if err != nil {
+		t.Fatalf("ListResources: %v", err)
+	}
+	for _, want := range []string{"data/lookup_groups.csv", "data/lookup_people.csv"} {
+		if !slices.Contains(urls, want) {
+			t.Errorf("expected %q in %v", want, urls)
+		}
+	}
+	if len(urls) != 2 {
+		t.Errorf("expected 2 distinct URLs, got %v", urls)
+	}
+}
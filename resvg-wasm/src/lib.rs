@@ -8,6 +8,7 @@ use resvg::usvg;
 static mut FONT_DB: Option<Arc<usvg::fontdb::Database>> = None;
 static mut RESULT_BUF: Vec<u8> = Vec::new();
 static mut ERROR_BUF: Vec<u8> = Vec::new();
+static mut LANGUAGES: Vec<String> = Vec::new();
 
 #[no_mangle]
 pub extern "C" fn alloc_mem(size: u32) -> u32 {
@@ -70,6 +71,27 @@ pub extern "C" fn font_db_set_monospace(ptr: u32, len: u32) -> i32 {
     }
 }
 
+#[no_mangle]
+pub extern "C" fn font_db_set_serif(ptr: u32, len: u32) -> i32 {
+    unsafe {
+        let data = slice::from_raw_parts(ptr as *const u8, len as usize);
+        let name = match std::str::from_utf8(data) {
+            Ok(s) => s,
+            Err(e) => {
+                set_error(&format!("invalid UTF-8: {}", e));
+                return -1;
+            }
+        };
+        if let Some(ref mut db) = FONT_DB {
+            Arc::get_mut(db).unwrap().set_serif_family(name);
+            0
+        } else {
+            set_error("font_db not initialized");
+            -1
+        }
+    }
+}
+
 #[no_mangle]
 pub extern "C" fn font_db_add(ptr: u32, len: u32) -> i32 {
     unsafe {
@@ -84,6 +106,29 @@ pub extern "C" fn font_db_add(ptr: u32, len: u32) -> i32 {
     }
 }
 
+/// Sets the languages matched against systemLanguage attributes when
+/// resolving <switch> elements, as a newline-separated list of BCP 47 tags
+/// (e.g. "ja\nen"). An empty list restores usvg's default.
+#[no_mangle]
+pub extern "C" fn set_languages(ptr: u32, len: u32) -> i32 {
+    unsafe {
+        let data = slice::from_raw_parts(ptr as *const u8, len as usize);
+        let list = match std::str::from_utf8(data) {
+            Ok(s) => s,
+            Err(e) => {
+                set_error(&format!("invalid UTF-8: {}", e));
+                return -1;
+            }
+        };
+        LANGUAGES = list
+            .split('\n')
+            .filter(|l| !l.is_empty())
+            .map(|l| l.to_string())
+            .collect();
+    }
+    0
+}
+
 #[no_mangle]
 pub extern "C" fn render(svg_ptr: u32, svg_len: u32, scale_bits: u64) -> i32 {
     unsafe {
@@ -114,6 +159,11 @@ pub extern "C" fn render(svg_ptr: u32, svg_len: u32, scale_bits: u64) -> i32 {
 
     let mut opts = usvg::Options::default();
     opts.fontdb = db;
+    unsafe {
+        if !LANGUAGES.is_empty() {
+            opts.languages = LANGUAGES.clone();
+        }
+    }
 
     let tree = match usvg::Tree::from_str(svg_str, &opts) {
         Ok(t) => t,
@@ -124,8 +174,9 @@ pub extern "C" fn render(svg_ptr: u32, svg_len: u32, scale_bits: u64) -> i32 {
     };
 
     let size = tree.size();
-    let w = (size.width() as f64 * scale).ceil() as u32;
-    let h = (size.height() as f64 * scale).ceil() as u32;
+    // Round half up, matching the Go side's scaling of the root size.
+    let w = (size.width() as f64 * scale + 0.5).floor() as u32;
+    let h = (size.height() as f64 * scale + 0.5).floor() as u32;
 
     if w == 0 || h == 0 {
         set_error("SVG has zero dimensions");
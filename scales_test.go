@@ -0,0 +1,62 @@
+package aster_test
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/mgilbir/aster"
+)
+
+func TestVegaLiteToSVGWithScales(t *testing.T) {
+	chart := func(values string) []byte {
+		return []byte(`{
+			"data": {"values": ` + values + `},
+			"mark": "bar",
+			"encoding": {
+				"x": {"field": "a", "type": "nominal"},
+				"y": {"field": "b", "type": "quantitative"}
+			}
+		}`)
+	}
+	small := chart(`[{"a": "A", "b": 3}, {"a": "B", "b": 7}]`)
+	large := chart(`[{"a": "A", "b": 30}, {"a": "B", "b": 70}]`)
+
+	c, err := aster.New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	// yTicks returns the y axis labels, which Vega renders as
+	// right-aligned text in the left axis.
+	labelRe := regexp.MustCompile(`<text text-anchor="end"[^>]*>([^<]+)</text>`)
+	yTicks := func(svg string) string {
+		var labels []string
+		for _, m := range labelRe.FindAllStringSubmatch(svg, -1) {
+			labels = append(labels, m[1])
+		}
+		return strings.Join(labels, ",")
+	}
+
+	domains := map[string][2]float64{"y": {0, 100}}
+	smallSVG, err := c.VegaLiteToSVGWithScales(small, domains)
+	if err != nil {
+		t.Fatalf("VegaLiteToSVGWithScales small: %v", err)
+	}
+	largeSVG, err := c.VegaLiteToSVGWithScales(large, domains)
+	if err != nil {
+		t.Fatalf("VegaLiteToSVGWithScales large: %v", err)
+	}
+	if yTicks(smallSVG) == "" || yTicks(smallSVG) != yTicks(largeSVG) {
+		t.Errorf("shared domain should give identical y axes: %q vs %q", yTicks(smallSVG), yTicks(largeSVG))
+	}
+	if !strings.Contains(yTicks(smallSVG), "100") {
+		t.Errorf("y axis should reach the domain maximum, got %q", yTicks(smallSVG))
+	}
+
+	if _, err := c.VegaLiteToSVGWithScales(small, map[string][2]float64{"size": {0, 1}}); err == nil ||
+		!strings.Contains(err.Error(), `scale "size" not found`) {
+		t.Errorf("expected a missing scale error, got %v", err)
+	}
+}
@@ -0,0 +1,35 @@
+package aster
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Signals parses a Vega spec, runs its dataflow once, and returns the name
+// and initial value of every signal in the resulting runtime, including
+// Vega's built-in signals such as width, height and padding. Values are
+// decoded from JSON, so numbers are float64 and values JSON cannot represent
+// (such as functions) are omitted.
+//
+// Front-ends can use the result to build controls for an interactive chart.
+// To inspect a Vega-Lite spec, compile it first with VegaLiteToVega.
+func (c *Converter) Signals(spec []byte) (map[string]any, error) {
+	spec, err := c.prepareVega(spec)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.prefetchVega(spec); err != nil {
+		return nil, err
+	}
+	defer c.clearPrefetched()
+	result, err := c.rt.Signals(string(spec))
+	if err != nil {
+		return nil, err
+	}
+
+	var signals map[string]any
+	if err := json.Unmarshal([]byte(result), &signals); err != nil {
+		return nil, fmt.Errorf("aster: decoding signals: %w", err)
+	}
+	return signals, nil
+}
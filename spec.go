@@ -0,0 +1,331 @@
+package aster
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrEmptySpec is returned for a spec that is empty or only whitespace, such
+// as an unset request body, instead of the JavaScript parse error it would
+// otherwise cause.
+var ErrEmptySpec = errors.New("aster: empty spec")
+
+// checkSpecShape rejects input that cannot be a spec before it reaches the
+// runtime: empty input, and valid JSON that is not an object. Malformed
+// JSON is left for the parser to report.
+func checkSpecShape(spec []byte) error {
+	spec = bytes.TrimSpace(spec)
+	if len(spec) == 0 {
+		return ErrEmptySpec
+	}
+	if spec[0] == '{' || !json.Valid(spec) {
+		return nil
+	}
+	var kind string
+	switch spec[0] {
+	case '[':
+		kind = "an array"
+	case '"':
+		kind = "a string"
+	case 't', 'f':
+		kind = "a boolean"
+	case 'n':
+		kind = "null"
+	default:
+		kind = "a number"
+	}
+	return fmt.Errorf("aster: spec must be a JSON object, got %s", kind)
+}
+
+// decodeSpec parses a JSON spec into a map, keeping numbers as json.Number so
+// re-encoding does not change their text.
+func decodeSpec(spec []byte) (map[string]any, error) {
+	dec := json.NewDecoder(bytes.NewReader(spec))
+	dec.UseNumber()
+	var m map[string]any
+	if err := dec.Decode(&m); err != nil {
+		return nil, fmt.Errorf("aster: parsing spec: %w", err)
+	}
+	return m, nil
+}
+
+// sortKeys re-encodes a JSON document with every object's keys in sorted
+// order, keeping the text of numbers and strings as they were.
+func sortKeys(doc []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("aster: parsing spec: %w", err)
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, fmt.Errorf("aster: encoding spec: %w", err)
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// nonFinite maps the non-standard number literals accepted under
+// WithNonFiniteNumbers to the placeholder strings that carry them through
+// encoding/json and into the runtime, where bridge.js turns them back into
+// numbers. -Infinity must come before Infinity so the longer token matches.
+var nonFinite = [][2]string{
+	{"-Infinity", `"__aster_-Infinity__"`},
+	{"Infinity", `"__aster_Infinity__"`},
+	{"NaN", `"__aster_NaN__"`},
+}
+
+// quoteNonFinite replaces each bare NaN, Infinity and -Infinity outside a
+// JSON string with its placeholder string, leaving the rest of the text
+// untouched.
+func quoteNonFinite(spec []byte) []byte {
+	var out []byte
+	last := 0
+	inString := false
+	for i := 0; i < len(spec); i++ {
+		ch := spec[i]
+		if inString {
+			switch ch {
+			case '\\':
+				i++
+			case '"':
+				inString = false
+			}
+			continue
+		}
+		if ch == '"' {
+			inString = true
+			continue
+		}
+		for _, nf := range nonFinite {
+			if bytes.HasPrefix(spec[i:], []byte(nf[0])) {
+				out = append(out, spec[last:i]...)
+				out = append(out, nf[1]...)
+				i += len(nf[0]) - 1
+				last = i + 1
+				break
+			}
+		}
+	}
+	if out == nil {
+		return spec
+	}
+	return append(out, spec[last:]...)
+}
+
+// unquoteNonFinite turns the placeholder strings written by quoteNonFinite
+// (and by bridge.js for non-finite numbers in compiled specs) back into bare
+// NaN, Infinity and -Infinity.
+func unquoteNonFinite(spec []byte) []byte {
+	for _, nf := range nonFinite {
+		spec = bytes.ReplaceAll(spec, []byte(nf[1]), []byte(nf[0]))
+	}
+	return spec
+}
+
+// mergeDefaults deep-merges defaults into dst. Keys already present in dst
+// win; nested objects are merged recursively.
+func mergeDefaults(dst, defaults map[string]any) {
+	for k, dv := range defaults {
+		cur, ok := dst[k]
+		if !ok {
+			dst[k] = cloneJSON(dv)
+			continue
+		}
+		curMap, curIsMap := cur.(map[string]any)
+		defMap, defIsMap := dv.(map[string]any)
+		if curIsMap && defIsMap {
+			mergeDefaults(curMap, defMap)
+		}
+	}
+}
+
+// cloneJSON deep-copies a decoded JSON value so specs never share (and later
+// mutate) the Converter's defaults.
+func cloneJSON(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, e := range v {
+			out[k] = cloneJSON(e)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, e := range v {
+			out[i] = cloneJSON(e)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// setDefault stores value at the nested path in m, creating intermediate
+// objects as needed.
+func setDefault(m map[string]any, value any, path ...string) {
+	for _, key := range path[:len(path)-1] {
+		next, ok := m[key].(map[string]any)
+		if !ok {
+			next = make(map[string]any)
+			m[key] = next
+		}
+		m = next
+	}
+	m[path[len(path)-1]] = value
+}
+
+// setMarkClip sets clip on every mark definition of a Vega-Lite view and
+// the views nested in it that does not set its own, expanding shorthand
+// marks such as "point" to {"type": "point"}.
+func setMarkClip(view map[string]any, clip bool) {
+	switch mark := view["mark"].(type) {
+	case string:
+		view["mark"] = map[string]any{"type": mark, "clip": clip}
+	case map[string]any:
+		if _, ok := mark["clip"]; !ok {
+			mark["clip"] = clip
+		}
+	}
+	for _, key := range paramScopes {
+		switch nested := view[key].(type) {
+		case map[string]any:
+			setMarkClip(nested, clip)
+		case []any:
+			for _, v := range nested {
+				if child, ok := v.(map[string]any); ok {
+					setMarkClip(child, clip)
+				}
+			}
+		}
+	}
+}
+
+// prepareVegaLite applies Converter-level spec defaults, mark clipping,
+// transforms and size clamps to a Vega-Lite spec before it is compiled.
+// Specs are passed through untouched when none is configured, apart from
+// WithNonFiniteNumbers quoting.
+func (c *Converter) prepareVegaLite(spec []byte) ([]byte, error) {
+	c.clamps = nil
+	if err := c.checkSpecSize(spec); err != nil {
+		return nil, err
+	}
+	if err := checkSpecShape(spec); err != nil {
+		return nil, err
+	}
+	if c.cfg.nonFiniteNumbers {
+		spec = quoteNonFinite(spec)
+	}
+	if len(c.cfg.configDefaults) == 0 && c.cfg.markClip == nil && len(c.cfg.specTransforms) == 0 && !c.clampsSize() {
+		return spec, nil
+	}
+
+	m, err := decodeSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.cfg.markClip != nil {
+		setMarkClip(m, *c.cfg.markClip)
+	}
+	if len(c.cfg.configDefaults) > 0 {
+		specConfig, ok := m["config"].(map[string]any)
+		if !ok {
+			specConfig = make(map[string]any)
+			m["config"] = specConfig
+		}
+		mergeDefaults(specConfig, c.cfg.configDefaults)
+	}
+
+	return c.transformSpec(m)
+}
+
+// prepareVega applies Converter-level projection fits, spec transforms and
+// size clamps to a Vega spec before it is rendered. Specs are passed through
+// untouched when none is configured, apart from WithNonFiniteNumbers quoting.
+func (c *Converter) prepareVega(spec []byte) ([]byte, error) {
+	c.clamps = nil
+	if err := c.checkSpecSize(spec); err != nil {
+		return nil, err
+	}
+	if err := checkSpecShape(spec); err != nil {
+		return nil, err
+	}
+	if c.cfg.nonFiniteNumbers {
+		spec = quoteNonFinite(spec)
+	}
+	if len(c.cfg.projectionFits) == 0 && len(c.cfg.specTransforms) == 0 && !c.clampsSize() {
+		return spec, nil
+	}
+
+	m, err := decodeSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.cfg.projectionFits) > 0 {
+		if err := c.applyProjectionFits(m); err != nil {
+			return nil, err
+		}
+	}
+	return c.transformSpec(m)
+}
+
+// transformSpec runs the WithSpecTransform hooks over a decoded spec, in
+// registration order, applies WithScaleClamp to the result and re-encodes it.
+func (c *Converter) transformSpec(m map[string]any) ([]byte, error) {
+	for i, fn := range c.cfg.specTransforms {
+		var err error
+		m, err = fn(m)
+		if err != nil {
+			return nil, fmt.Errorf("aster: spec transform %d: %w", i, err)
+		}
+		if m == nil {
+			return nil, fmt.Errorf("aster: spec transform %d returned a nil spec", i)
+		}
+	}
+
+	c.clampSize(m)
+
+	out, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("aster: encoding spec: %w", err)
+	}
+	return out, nil
+}
+
+// clampsSize reports whether WithScaleClamp set a maximum width or height.
+func (c *Converter) clampsSize() bool {
+	return c.cfg.clampWidth > 0 || c.cfg.clampHeight > 0
+}
+
+// clampSize lowers a spec's top-level numeric width and height to the
+// WithScaleClamp maxima, noting each dimension it changes for Warnings.
+// Sizes given as signals, steps or "container" are left alone.
+func (c *Converter) clampSize(m map[string]any) {
+	dims := []struct {
+		key string
+		max float64
+	}{
+		{"width", c.cfg.clampWidth},
+		{"height", c.cfg.clampHeight},
+	}
+	for _, d := range dims {
+		if d.max <= 0 {
+			continue
+		}
+		n, ok := m[d.key].(json.Number)
+		if !ok {
+			continue
+		}
+		v, err := n.Float64()
+		if err != nil || v <= d.max {
+			continue
+		}
+		m[d.key] = d.max
+		c.clamps = append(c.clamps, fmt.Sprintf("aster: clamped spec %s %s to %g (WithScaleClamp)", d.key, n, d.max))
+	}
+}
@@ -0,0 +1,404 @@
+package aster_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/mgilbir/aster"
+)
+
+// svgSize returns the width and height attributes of the root <svg> element.
+func svgSize(t *testing.T, svg string) (float64, float64) {
+	t.Helper()
+	m := regexp.MustCompile(`^<svg[^>]*\swidth="([\d.]+)"[^>]*\sheight="([\d.]+)"`).FindStringSubmatch(svg)
+	if m == nil {
+		t.Fatalf("no width/height on root <svg>: %.200s", svg)
+	}
+	w, _ := strconv.ParseFloat(m[1], 64)
+	h, _ := strconv.ParseFloat(m[2], 64)
+	return w, h
+}
+
+func TestWithDefaultSize(t *testing.T) {
+	// bar-chart.vl.json sets no width/height.
+	spec, err := os.ReadFile("testdata/bar-chart.vl.json")
+	if err != nil {
+		t.Fatalf("reading test spec: %v", err)
+	}
+
+	c, err := aster.New(aster.WithDefaultSize(400, 300))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	svg, err := c.VegaLiteToSVG(spec)
+	if err != nil {
+		t.Fatalf("VegaLiteToSVG: %v", err)
+	}
+	w, h := svgSize(t, svg)
+	// The plot area is 400x300; axes and padding add to it.
+	if w < 400 || h < 300 {
+		t.Errorf("expected at least 400x300, got %vx%v", w, h)
+	}
+
+	// A spec's own size wins over the default.
+	sized := []byte(`{
+		"width": 100, "height": 50,
+		"data": {"values": [{"a": 1}]},
+		"mark": "point",
+		"encoding": {"x": {"field": "a", "type": "quantitative"}}
+	}`)
+	svg, err = c.VegaLiteToSVG(sized)
+	if err != nil {
+		t.Fatalf("VegaLiteToSVG sized: %v", err)
+	}
+	if w, h := svgSize(t, svg); w >= 400 || h >= 300 {
+		t.Errorf("spec size should win, got %vx%v", w, h)
+	}
+}
+
+func TestWithAutosize(t *testing.T) {
+	spec := []byte(`{
+		"width": 200, "height": 100,
+		"data": {"values": [{"a": "x", "b": 1}, {"a": "y", "b": 2}]},
+		"mark": "bar",
+		"encoding": {
+			"x": {"field": "a", "type": "nominal"},
+			"y": {"field": "b", "type": "quantitative"}
+		}
+	}`)
+
+	c, err := aster.New(aster.WithAutosize("fit", false, "padding"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	svg, err := c.VegaLiteToSVG(spec)
+	if err != nil {
+		t.Fatalf("VegaLiteToSVG: %v", err)
+	}
+	// With fit/padding the whole chart, axes included, is exactly the
+	// spec's width and height.
+	if w, h := svgSize(t, svg); w != 200 || h != 100 {
+		t.Errorf("expected a 200x100 fit chart, got %vx%v", w, h)
+	}
+
+	// A spec's own autosize wins over the default.
+	padded := []byte(strings.Replace(string(spec), `"mark"`, `"autosize": {"type": "pad"}, "mark"`, 1))
+	svg, err = c.VegaLiteToSVG(padded)
+	if err != nil {
+		t.Fatalf("VegaLiteToSVG padded: %v", err)
+	}
+	if w, h := svgSize(t, svg); w <= 200 || h <= 100 {
+		t.Errorf("pad autosize should grow past 200x100 for the axes, got %vx%v", w, h)
+	}
+}
+
+func TestWithAutosizeContains(t *testing.T) {
+	spec := []byte(`{
+		"width": 200, "height": 100,
+		"data": {"values": [{"a": "x", "b": 1}, {"a": "y", "b": 2}]},
+		"mark": "bar",
+		"encoding": {
+			"x": {"field": "a", "type": "nominal"},
+			"y": {"field": "b", "type": "quantitative"}
+		}
+	}`)
+
+	// Under fit autosize, Vega-Lite's default 5px padding is added around
+	// the 200x100 chart with "content" and taken out of it with "padding".
+	for contains, want := range map[string][2]float64{"content": {210, 110}, "padding": {200, 100}} {
+		c, err := aster.New(aster.WithAutosize("fit", false, ""), aster.WithAutosizeContains(contains))
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		svg, err := c.VegaLiteToSVG(spec)
+		_ = c.Close()
+		if err != nil {
+			t.Fatalf("VegaLiteToSVG %s: %v", contains, err)
+		}
+		if w, h := svgSize(t, svg); w != want[0] || h != want[1] {
+			t.Errorf("contains %q: expected %vx%v, got %vx%v", contains, want[0], want[1], w, h)
+		}
+	}
+}
+
+func TestWithClip(t *testing.T) {
+	// The second point lies far outside the fixed x domain.
+	spec := []byte(`{
+		"data": {"values": [{"a": 5, "b": 1}, {"a": 500, "b": 2}]},
+		"layer": [{
+			"mark": "point",
+			"encoding": {
+				"x": {"field": "a", "type": "quantitative", "scale": {"domain": [0, 10]}},
+				"y": {"field": "b", "type": "quantitative"}
+			}
+		}]
+	}`)
+
+	clipped := regexp.MustCompile(`<g class="mark-symbol role-mark[^"]*"[^>]*clip-path="url\(#`)
+	for _, clip := range []bool{false, true} {
+		c, err := aster.New(aster.WithClip(clip))
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		svg, err := c.VegaLiteToSVG(spec)
+		_ = c.Close()
+		if err != nil {
+			t.Fatalf("VegaLiteToSVG: %v", err)
+		}
+		if got := clipped.MatchString(svg); got != clip {
+			t.Errorf("WithClip(%v): points clipped = %v, want %v: %.400s", clip, got, clip, svg)
+		}
+	}
+}
+
+func TestWithSpecTransform(t *testing.T) {
+	spec := []byte(`{
+		"data": {"values": [{"a": 1}]},
+		"mark": "point",
+		"encoding": {"x": {"field": "a", "type": "quantitative"}}
+	}`)
+
+	c, err := aster.New(aster.WithSpecTransform(func(spec map[string]any) (map[string]any, error) {
+		spec["title"] = "Injected Title"
+		return spec, nil
+	}))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	svg, err := c.VegaLiteToSVG(spec)
+	if err != nil {
+		t.Fatalf("VegaLiteToSVG: %v", err)
+	}
+	if !strings.Contains(svg, "Injected Title") {
+		t.Error("expected transformed title in Vega-Lite output")
+	}
+
+	vg, err := c.VegaLiteToVega(spec)
+	if err != nil {
+		t.Fatalf("VegaLiteToVega: %v", err)
+	}
+	// The compiled Vega spec carries the title; rendering it runs the
+	// transform again on the Vega side, overwriting with the same value.
+	svg, err = c.VegaToSVG(vg)
+	if err != nil {
+		t.Fatalf("VegaToSVG: %v", err)
+	}
+	if !strings.Contains(svg, "Injected Title") {
+		t.Error("expected transformed title in Vega output")
+	}
+}
+
+func TestWithSpecTransformError(t *testing.T) {
+	errReject := errors.New("rejected")
+	c, err := aster.New(aster.WithSpecTransform(func(map[string]any) (map[string]any, error) {
+		return nil, errReject
+	}))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	spec := []byte(`{"data": {"values": [{"a": 1}]}, "mark": "point"}`)
+	if _, err := c.VegaLiteToSVG(spec); !errors.Is(err, errReject) {
+		t.Errorf("VegaLiteToSVG: expected transform error, got %v", err)
+	}
+	if _, err := c.VegaToSVG([]byte(`{"marks": []}`)); !errors.Is(err, errReject) {
+		t.Errorf("VegaToSVG: expected transform error, got %v", err)
+	}
+}
+
+func TestWithNonFiniteNumbers(t *testing.T) {
+	// Not valid JSON: the threshold domain ends in a bare Infinity.
+	spec := []byte(`{
+		"data": {"values": [{"a": 1, "label": "Infinity"}, {"a": 3, "label": "NaN"}]},
+		"mark": "point",
+		"encoding": {
+			"x": {"field": "a", "type": "quantitative"},
+			"color": {
+				"field": "a", "type": "quantitative",
+				"scale": {"type": "threshold", "domain": [2, Infinity], "range": ["red", "green", "blue"]}
+			}
+		}
+	}`)
+
+	plain, err := aster.New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = plain.Close() }()
+	if _, err := plain.VegaLiteToSVG(spec); err == nil {
+		t.Error("expected a parse error without WithNonFiniteNumbers")
+	}
+
+	c, err := aster.New(aster.WithNonFiniteNumbers())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	svg, err := c.VegaLiteToSVG(spec)
+	if err != nil {
+		t.Fatalf("VegaLiteToSVG: %v", err)
+	}
+	if !strings.Contains(svg, `stroke="red"`) || !strings.Contains(svg, `stroke="green"`) {
+		t.Errorf("expected points on both sides of the threshold: %.300s", svg)
+	}
+
+	vg, err := c.VegaLiteToVega(spec)
+	if err != nil {
+		t.Fatalf("VegaLiteToVega: %v", err)
+	}
+	if !strings.Contains(string(vg), "[2,Infinity]") {
+		t.Errorf("expected the compiled domain to keep Infinity: %s", vg)
+	}
+	if strings.Contains(string(vg), "__aster_") {
+		t.Errorf("placeholder leaked into compiled spec: %s", vg)
+	}
+	// String values that spell a non-finite literal are left alone.
+	if !strings.Contains(string(vg), `"label":"Infinity"`) {
+		t.Errorf("expected string data to stay a string: %s", vg)
+	}
+
+	// The compiled spec is accepted back as Vega input, through a spec
+	// transform that decodes and re-encodes it on the Go side.
+	transformed, err := aster.New(aster.WithNonFiniteNumbers(),
+		aster.WithSpecTransform(func(spec map[string]any) (map[string]any, error) { return spec, nil }))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = transformed.Close() }()
+	if _, err := transformed.VegaToSVG(vg); err != nil {
+		t.Fatalf("VegaToSVG: %v", err)
+	}
+}
+
+// keysSorted reports whether every object in a JSON document lists its keys
+// in ascending order.
+func keysSorted(t *testing.T, doc []byte) bool {
+	t.Helper()
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	var walk func() bool
+	walk = func() bool {
+		tok, err := dec.Token()
+		if err != nil {
+			t.Fatalf("reading JSON: %v", err)
+		}
+		switch tok {
+		case json.Delim('{'):
+			prev := ""
+			for dec.More() {
+				key, _ := dec.Token()
+				k := key.(string)
+				if k < prev {
+					return false
+				}
+				prev = k
+				if !walk() {
+					return false
+				}
+			}
+			_, _ = dec.Token()
+		case json.Delim('['):
+			for dec.More() {
+				if !walk() {
+					return false
+				}
+			}
+			_, _ = dec.Token()
+		}
+		return true
+	}
+	return walk()
+}
+
+func TestWithSortedKeys(t *testing.T) {
+	spec, err := os.ReadFile("testdata/bar-chart.vl.json")
+	if err != nil {
+		t.Fatalf("reading test spec: %v", err)
+	}
+
+	plain, err := aster.New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = plain.Close() }()
+	sorted, err := aster.New(aster.WithSortedKeys())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = sorted.Close() }()
+
+	native, err := plain.VegaLiteToVega(spec)
+	if err != nil {
+		t.Fatalf("VegaLiteToVega: %v", err)
+	}
+	canonical, err := sorted.VegaLiteToVega(spec)
+	if err != nil {
+		t.Fatalf("VegaLiteToVega: %v", err)
+	}
+	if keysSorted(t, native) {
+		t.Error("expected Vega-Lite's native key order by default, got sorted keys")
+	}
+	if !keysSorted(t, canonical) {
+		t.Errorf("expected sorted keys with WithSortedKeys: %.300s", canonical)
+	}
+
+	var a, b any
+	if err := json.Unmarshal(native, &a); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(canonical, &b); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(a, b) {
+		t.Error("sorting keys changed the compiled spec")
+	}
+}
+
+func TestEmptyAndNonObjectSpecs(t *testing.T) {
+	c, err := aster.New(aster.WithTextMeasurement(false))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	calls := map[string]func(spec []byte) error{
+		"VegaLiteToSVG": func(spec []byte) error { _, err := c.VegaLiteToSVG(spec); return err },
+		"VegaToSVG":     func(spec []byte) error { _, err := c.VegaToSVG(spec); return err },
+		"VegaLiteToVega": func(spec []byte) error {
+			_, err := c.VegaLiteToVega(spec)
+			return err
+		},
+		"VegaLiteToSVGWithParams": func(spec []byte) error {
+			_, err := c.VegaLiteToSVGWithParams(spec, nil)
+			return err
+		},
+		"ImportState": func(spec []byte) error { _, err := c.ImportState(spec, nil); return err },
+	}
+	for name, call := range calls {
+		for _, spec := range []string{"", " \n\t"} {
+			if err := call([]byte(spec)); !errors.Is(err, aster.ErrEmptySpec) {
+				t.Errorf("%s(%q): expected ErrEmptySpec, got %v", name, spec, err)
+			}
+		}
+		for spec, kind := range map[string]string{"[1, 2]": "an array", ` "chart"`: "a string", "42": "a number", "null": "null"} {
+			err := call([]byte(spec))
+			if err == nil || !strings.Contains(err.Error(), "must be a JSON object, got "+kind) {
+				t.Errorf("%s(%s): expected a not-an-object error naming %s, got %v", name, spec, kind, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package aster
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// ViewState is the interactive state of a Vega chart: the values of its
+// input signals and of the datasets interactions modify, such as the
+// *_store datasets Vega-Lite keeps its selections and brushes in. It
+// marshals to JSON as {"signals": {...}, "data": {...}}, so a front-end can
+// capture a user's state and send it back for a server-side render.
+type ViewState struct {
+	Signals map[string]any   `json:"signals,omitempty"`
+	Data    map[string][]any `json:"data,omitempty"`
+}
+
+// ExportState parses a Vega spec, runs its dataflow once, and returns its
+// state. The state covers the top-level signals the spec declares without
+// an update expression (so their value is not derived from others) and the
+// top-level datasets that have triggers or are changed with modify(). Values
+// are decoded from JSON, as in Signals.
+//
+// To inspect a Vega-Lite spec, compile it first with VegaLiteToVega.
+func (c *Converter) ExportState(spec []byte) (*ViewState, error) {
+	spec, err := c.prepareVega(spec)
+	if err != nil {
+		return nil, err
+	}
+	vg, err := decodeSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	names, err := json.Marshal(stateNames(vg, spec))
+	if err != nil {
+		return nil, fmt.Errorf("aster: encoding state names: %w", err)
+	}
+
+	if err := c.prefetchVega(spec); err != nil {
+		return nil, err
+	}
+	defer c.clearPrefetched()
+	result, err := c.rt.State(string(spec), string(names))
+	if err != nil {
+		return nil, err
+	}
+
+	var state ViewState
+	if err := json.Unmarshal([]byte(result), &state); err != nil {
+		return nil, fmt.Errorf("aster: decoding state: %w", err)
+	}
+	return &state, nil
+}
+
+// ImportState returns a copy of a Vega spec that starts in state, as
+// exported by ExportState: each signal's value is set (replacing any init
+// expression) and each dataset's values replaced. Render the result with
+// VegaToSVG or VegaToPNG to reproduce the state. It is an error if state
+// names a signal or dataset the spec does not declare at the top level.
+//
+// Converters keep no view between calls, so there is no changeset API for
+// streaming rows into a rendered chart; to show new data, pass the current
+// rows of each named dataset in state.Data and render the result.
+func (c *Converter) ImportState(spec []byte, state *ViewState) ([]byte, error) {
+	if err := c.checkSpecSize(spec); err != nil {
+		return nil, err
+	}
+	if err := checkSpecShape(spec); err != nil {
+		return nil, err
+	}
+	vg, err := decodeSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	if state != nil {
+		if err := applyState(vg, state); err != nil {
+			return nil, err
+		}
+	}
+	out, err := json.Marshal(vg)
+	if err != nil {
+		return nil, fmt.Errorf("aster: encoding spec: %w", err)
+	}
+	return out, nil
+}
+
+// stateNames lists the signals and datasets of vg that ExportState exports.
+// raw is vg's JSON, searched for modify() calls.
+func stateNames(vg map[string]any, raw []byte) map[string][]string {
+	names := map[string][]string{"signals": {}, "data": {}}
+	signals, _ := vg["signals"].([]any)
+	for _, s := range signals {
+		sig, _ := s.(map[string]any)
+		name, _ := sig["name"].(string)
+		if _, derived := sig["update"]; name == "" || derived {
+			continue
+		}
+		names["signals"] = append(names["signals"], name)
+	}
+	data, _ := vg["data"].([]any)
+	for _, d := range data {
+		def, _ := d.(map[string]any)
+		name, _ := def["name"].(string)
+		if name == "" {
+			continue
+		}
+		_, triggered := def["on"]
+		modified := regexp.MustCompile(`modify\(\s*\\?["']` + regexp.QuoteMeta(name) + `\\?["']`).Match(raw)
+		if triggered || modified {
+			names["data"] = append(names["data"], name)
+		}
+	}
+	return names
+}
+
+// applyState writes state into the top-level signals and datasets of vg,
+// in sorted order so the first unknown name reported is deterministic.
+func applyState(vg map[string]any, state *ViewState) error {
+	index := func(key string) map[string]map[string]any {
+		defs := make(map[string]map[string]any)
+		list, _ := vg[key].([]any)
+		for _, item := range list {
+			def, _ := item.(map[string]any)
+			if name, ok := def["name"].(string); ok {
+				defs[name] = def
+			}
+		}
+		return defs
+	}
+
+	signals := index("signals")
+	for _, name := range sortedKeys(state.Signals) {
+		sig, ok := signals[name]
+		if !ok {
+			return fmt.Errorf("aster: state signal %q not found in spec", name)
+		}
+		delete(sig, "init")
+		sig["value"] = state.Signals[name]
+	}
+
+	data := index("data")
+	for _, name := range sortedKeys(state.Data) {
+		def, ok := data[name]
+		if !ok {
+			return fmt.Errorf("aster: state dataset %q not found in spec", name)
+		}
+		def["values"] = state.Data[name]
+	}
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,89 @@
+package aster_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/mgilbir/aster"
+)
+
+// stateSpec draws one point per row of the "picked" dataset, sized by the
+// "size" signal: both stand in for state a user's interactions would set.
+const stateSpec = `{
+	"width": 100, "height": 100,
+	"signals": [
+		{"name": "size", "value": 20, "on": [{"events": "click", "update": "size * 2"}]},
+		{"name": "area", "update": "size * size"}
+	],
+	"data": [
+		{"name": "picked", "values": [{"x": 10}], "on": [{"trigger": "size > 100", "insert": "{x: 50}"}]}
+	],
+	"marks": [{
+		"type": "symbol",
+		"from": {"data": "picked"},
+		"encode": {"update": {"x": {"field": "x"}, "y": {"value": 50}, "size": {"signal": "area"}}}
+	}]
+}`
+
+func TestExportImportState(t *testing.T) {
+	c, err := aster.New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	state, err := c.ExportState([]byte(stateSpec))
+	if err != nil {
+		t.Fatalf("ExportState: %v", err)
+	}
+	if _, ok := state.Signals["area"]; ok {
+		t.Errorf("derived signal area should not be exported: %+v", state)
+	}
+	if state.Signals["size"] != 20.0 || len(state.Data["picked"]) != 1 {
+		t.Fatalf("unexpected initial state: %+v", state)
+	}
+
+	// Stand in for a user's interactions, and round-trip through JSON as a
+	// front-end would.
+	state.Signals["size"] = 40.0
+	state.Data["picked"] = append(state.Data["picked"], map[string]any{"x": 50.0})
+	data, err := json.Marshal(state)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var replayed aster.ViewState
+	if err := json.Unmarshal(data, &replayed); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+
+	spec, err := c.ImportState([]byte(stateSpec), &replayed)
+	if err != nil {
+		t.Fatalf("ImportState: %v", err)
+	}
+	again, err := c.ExportState(spec)
+	if err != nil {
+		t.Fatalf("ExportState of imported spec: %v", err)
+	}
+	if again.Signals["size"] != 40.0 || len(again.Data["picked"]) != 2 {
+		t.Errorf("state did not round-trip: %+v", again)
+	}
+
+	got, err := c.VegaToSVG(spec)
+	if err != nil {
+		t.Fatalf("VegaToSVG: %v", err)
+	}
+	edited := strings.Replace(stateSpec, `"value": 20`, `"value": 40`, 1)
+	edited = strings.Replace(edited, `[{"x": 10}]`, `[{"x": 10}, {"x": 50}]`, 1)
+	want, err := c.VegaToSVG([]byte(edited))
+	if err != nil {
+		t.Fatalf("VegaToSVG: %v", err)
+	}
+	if got != want {
+		t.Errorf("imported state renders differently from the edited spec:\ngot  %s\nwant %s", got, want)
+	}
+
+	if _, err := c.ImportState([]byte(stateSpec), &aster.ViewState{Signals: map[string]any{"nope": 1}}); err == nil {
+		t.Error("expected an error for a signal the spec does not declare")
+	}
+}
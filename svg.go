@@ -0,0 +1,90 @@
+package aster
+
+import (
+	"fmt"
+	"html"
+	"math/rand/v2"
+	"strings"
+
+	"github.com/mgilbir/aster/internal/svgdoc"
+)
+
+// SanitizeSVG removes active content from an SVG document: <script> and
+// <foreignObject> elements (in any case or namespace), on* event handler
+// attributes, and attributes holding javascript: URLs, including
+// entity-encoded ones. Vega does not emit these itself; this is a defense-in-depth pass for
+// applications that inline SVG derived from user-supplied specs into web pages.
+func SanitizeSVG(svg string) string {
+	return svgdoc.Sanitize(svg)
+}
+
+// runSVGPostProcessors passes a rendered SVG through the processors
+// registered with WithSVGPostProcessor, in order.
+func (c *Converter) runSVGPostProcessors(svg string) (string, error) {
+	for i, fn := range c.cfg.svgPostProcessors {
+		var err error
+		svg, err = fn(svg)
+		if err != nil {
+			return "", fmt.Errorf("aster: SVG post-processor %d: %w", i, err)
+		}
+	}
+	return svg, nil
+}
+
+// postProcessSVG applies the Converter's configured output transforms and
+// the call's render options to a rendered SVG.
+func (c *Converter) postProcessSVG(svg string, rc *renderConfig) (string, error) {
+	svg, err := c.runSVGPostProcessors(svg)
+	if err != nil {
+		return "", err
+	}
+	return c.svgOutput(svg, rc), nil
+}
+
+// svgOutput applies the built-in SVG output options and the call's render
+// options to an SVG that has been through the post-processors.
+func (c *Converter) svgOutput(svg string, rc *renderConfig) string {
+	if c.cfg.sanitizeOutput {
+		svg = svgdoc.Sanitize(svg)
+	}
+	if rc.flatGradients {
+		svg = svgdoc.FlattenGradients(svg)
+	}
+	svg = svgdoc.PrefixNames(svg, rc.svgClassPrefix)
+	if c.cfg.uniqueIDs {
+		svg = svgdoc.SuffixIDs(svg, fmt.Sprintf("-%08x", rand.Uint32()))
+	}
+	if scale, ok := fitScale(svg, rc); ok {
+		svg, _ = svgdoc.ScaleRoot(svg, scale)
+	}
+	if c.cfg.responsiveSVG {
+		svg = svgdoc.Responsive(svg)
+	}
+	return svgdoc.PrependChildren(svg, svgMetadata(rc)+c.sourceMetadata(rc))
+}
+
+// fitScale returns the scale that makes svg WithFitWidth pixels wide, or
+// ok=false if no fit width is set or svg has no pixel width.
+func fitScale(svg string, rc *renderConfig) (float64, bool) {
+	if rc.fitWidth <= 0 {
+		return 0, false
+	}
+	w, _, ok := svgdoc.Size(svg)
+	if !ok {
+		return 0, false
+	}
+	return rc.fitWidth / w, true
+}
+
+// svgMetadata builds the <title> and <desc> elements requested by
+// WithSVGTitle and WithSVGDesc.
+func svgMetadata(rc *renderConfig) string {
+	var b strings.Builder
+	if rc.svgTitle != "" {
+		b.WriteString("<title>" + html.EscapeString(rc.svgTitle) + "</title>")
+	}
+	if rc.svgDesc != "" {
+		b.WriteString("<desc>" + html.EscapeString(rc.svgDesc) + "</desc>")
+	}
+	return b.String()
+}
@@ -0,0 +1,632 @@
+package aster_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image/png"
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/mgilbir/aster"
+)
+
+func TestSanitizeSVG(t *testing.T) {
+	got := aster.SanitizeSVG(`<svg width="10" height="10"><script>alert(1)</script><rect onclick="x()" width="10"/></svg>`)
+	if got != `<svg width="10" height="10"><rect width="10"/></svg>` {
+		t.Errorf("unexpected sanitized SVG: %s", got)
+	}
+}
+
+// Vega escapes mark text, so the active content is injected after
+// rendering, by a post-processor, as a compromised or careless one might.
+func TestWithSanitizeOutput(t *testing.T) {
+	spec, err := os.ReadFile("testdata/bar-chart.vl.json")
+	if err != nil {
+		t.Fatalf("reading test spec: %v", err)
+	}
+	inject := func(svg string) (string, error) {
+		end := strings.Index(svg, ">") + 1
+		return svg[:end] +
+			`<script>alert(1)</script>` +
+			`<foreignObject><div>x</div></foreignObject>` +
+			`<a xlink:href="javascript:alert(2)"><rect onload="alert(3)" aria-label="a; onx=1" width="1" height="1"/></a>` +
+			svg[end:], nil
+	}
+
+	c, err := aster.New(aster.WithSVGPostProcessor(inject), aster.WithSanitizeOutput())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	svg, err := c.VegaLiteToSVG(spec)
+	if err != nil {
+		t.Fatalf("VegaLiteToSVG: %v", err)
+	}
+	for _, bad := range []string{"<script", "<foreignObject", "javascript:", `onload="`} {
+		if strings.Contains(svg, bad) {
+			t.Errorf("expected %q stripped from output: %.300s", bad, svg)
+		}
+	}
+	if !strings.Contains(svg, `<a><rect aria-label="a; onx=1" width="1" height="1"/></a>`) {
+		t.Errorf("expected the sanitized link and rect kept intact: %.300s", svg)
+	}
+	if !strings.HasPrefix(svg, "<svg") {
+		t.Errorf("expected SVG output starting with <svg, got: %.100s", svg)
+	}
+}
+
+func TestWithResponsiveSVG(t *testing.T) {
+	spec, err := os.ReadFile("testdata/bar-chart.vl.json")
+	if err != nil {
+		t.Fatalf("reading test spec: %v", err)
+	}
+
+	plain, err := aster.New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = plain.Close() }()
+	responsive, err := aster.New(aster.WithResponsiveSVG())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = responsive.Close() }()
+
+	want, err := plain.VegaLiteToSVG(spec)
+	if err != nil {
+		t.Fatalf("VegaLiteToSVG: %v", err)
+	}
+	got, err := responsive.VegaLiteToSVG(spec)
+	if err != nil {
+		t.Fatalf("VegaLiteToSVG responsive: %v", err)
+	}
+
+	viewBoxRe := regexp.MustCompile(`^<svg[^>]*viewBox="([^"]*)"`)
+	wantBox := viewBoxRe.FindStringSubmatch(want)
+	gotBox := viewBoxRe.FindStringSubmatch(got)
+	if wantBox == nil || gotBox == nil || wantBox[1] != gotBox[1] {
+		t.Errorf("viewBox not preserved: want %v, got %v", wantBox, gotBox)
+	}
+	root := got[:strings.Index(got, ">")]
+	if !strings.Contains(root, `width="100%"`) || strings.Contains(root, " height=") {
+		t.Errorf("expected width=100%% and no height on root: %s", root)
+	}
+
+	// PNG output keeps the chart's pixel size.
+	data, err := responsive.VegaLiteToPNG(spec)
+	if err != nil {
+		t.Fatalf("VegaLiteToPNG: %v", err)
+	}
+	if _, err := png.Decode(bytes.NewReader(data)); err != nil {
+		t.Fatalf("png.Decode: %v", err)
+	}
+}
+
+func TestWithFullPrecision(t *testing.T) {
+	// Thirds of the 300px width give coordinates like 100.33333333333333.
+	spec := []byte(`{
+		"width": 301,
+		"data": {"values": [{"x": 0, "y": 1}, {"x": 1, "y": 3}, {"x": 2, "y": 2}, {"x": 3, "y": 7}]},
+		"mark": "line",
+		"encoding": {
+			"x": {"field": "x", "type": "quantitative"},
+			"y": {"field": "y", "type": "quantitative"}
+		}
+	}`)
+
+	// maxDecimals returns the most decimal places of any number in the
+	// line's path data.
+	pathRe := regexp.MustCompile(`class="mark-line[^"]*".*?\sd="([^"]+)"`)
+	numRe := regexp.MustCompile(`\.(\d+)`)
+	maxDecimals := func(t *testing.T, svg string) int {
+		t.Helper()
+		m := pathRe.FindStringSubmatch(svg)
+		if m == nil {
+			t.Fatalf("no line path in SVG: %.300s", svg)
+		}
+		most := 0
+		for _, n := range numRe.FindAllStringSubmatch(m[1], -1) {
+			most = max(most, len(n[1]))
+		}
+		return most
+	}
+
+	plain, err := aster.New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = plain.Close() }()
+	precise, err := aster.New(aster.WithFullPrecision())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = precise.Close() }()
+
+	svg, err := plain.VegaLiteToSVG(spec)
+	if err != nil {
+		t.Fatalf("VegaLiteToSVG: %v", err)
+	}
+	if got := maxDecimals(t, svg); got > 3 {
+		t.Errorf("default output should round path data to 3 decimals, got %d", got)
+	}
+
+	svg, err = precise.VegaLiteToSVG(spec)
+	if err != nil {
+		t.Fatalf("VegaLiteToSVG full precision: %v", err)
+	}
+	if got := maxDecimals(t, svg); got <= 3 {
+		t.Errorf("full precision path data should keep more than 3 decimals, got %d", got)
+	}
+}
+
+func TestWithSVGPostProcessor(t *testing.T) {
+	spec, err := os.ReadFile("testdata/bar-chart.vl.json")
+	if err != nil {
+		t.Fatalf("reading test spec: %v", err)
+	}
+
+	var order []string
+	c, err := aster.New(
+		aster.WithSVGPostProcessor(func(svg string) (string, error) {
+			order = append(order, "first")
+			return strings.Replace(svg, "<svg", `<svg data-chart="bar"`, 1), nil
+		}),
+		aster.WithSVGPostProcessor(func(svg string) (string, error) {
+			order = append(order, "second")
+			if !strings.Contains(svg, `data-chart="bar"`) {
+				return "", errors.New("first processor did not run")
+			}
+			return svg, nil
+		}),
+	)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	svg, err := c.VegaLiteToSVG(spec)
+	if err != nil {
+		t.Fatalf("VegaLiteToSVG: %v", err)
+	}
+	if !strings.HasPrefix(svg, `<svg data-chart="bar"`) {
+		t.Errorf("expected injected attribute, got: %.100s", svg)
+	}
+	if strings.Join(order, ",") != "first,second" {
+		t.Errorf("expected processors in registration order, got %v", order)
+	}
+
+	// Processors also run ahead of PNG rasterization.
+	order = nil
+	if _, err := c.VegaLiteToPNG(spec); err != nil {
+		t.Fatalf("VegaLiteToPNG: %v", err)
+	}
+	if len(order) != 2 {
+		t.Errorf("expected both processors to run for PNG, got %v", order)
+	}
+}
+
+func TestWithSVGPostProcessorError(t *testing.T) {
+	errBoom := errors.New("boom")
+	c, err := aster.New(aster.WithSVGPostProcessor(func(string) (string, error) {
+		return "", errBoom
+	}))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	_, err = c.VegaLiteToSVG([]byte(`{"data": {"values": [{"a": 1}]}, "mark": "point"}`))
+	if !errors.Is(err, errBoom) {
+		t.Fatalf("expected processor error, got %v", err)
+	}
+}
+
+func TestWithSVGTitleAndDesc(t *testing.T) {
+	spec, err := os.ReadFile("testdata/bar-chart.vl.json")
+	if err != nil {
+		t.Fatalf("reading test spec: %v", err)
+	}
+
+	c, err := aster.New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	svg, err := c.VegaLiteToSVG(spec,
+		aster.WithSVGTitle("Sales <2024>"),
+		aster.WithSVGDesc("Bars by category"),
+	)
+	if err != nil {
+		t.Fatalf("VegaLiteToSVG: %v", err)
+	}
+	if !strings.HasPrefix(svg, "<svg") {
+		t.Fatalf("expected SVG output starting with <svg, got: %.100s", svg)
+	}
+	root := svg[:strings.Index(svg, ">")+1]
+	want := root + "<title>Sales &lt;2024&gt;</title><desc>Bars by category</desc>"
+	if !strings.HasPrefix(svg, want) {
+		t.Errorf("expected title and desc as first children, got: %.300s", svg)
+	}
+
+	// Without the options the output is unchanged.
+	plain, err := c.VegaLiteToSVG(spec)
+	if err != nil {
+		t.Fatalf("VegaLiteToSVG: %v", err)
+	}
+	if strings.Contains(plain, "<title>") {
+		t.Error("expected no <title> without WithSVGTitle")
+	}
+}
+
+func TestWithFitWidth(t *testing.T) {
+	spec, err := os.ReadFile("testdata/bar-chart.vl.json")
+	if err != nil {
+		t.Fatalf("reading test spec: %v", err)
+	}
+
+	c, err := aster.New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	svg, err := c.VegaLiteToSVG(spec, aster.WithFitWidth(400))
+	if err != nil {
+		t.Fatalf("VegaLiteToSVG: %v", err)
+	}
+	root := regexp.MustCompile(`<svg\b[^>]*>`).FindString(svg)
+	if !strings.Contains(root, ` width="400"`) || !strings.Contains(root, `viewBox="`) {
+		t.Errorf("expected a 400px wide root with a viewBox, got %s", root)
+	}
+
+	// WithFitWidth wins over WithScale.
+	data, err := c.VegaLiteToPNG(spec, aster.WithFitWidth(400), aster.WithScale(3))
+	if err != nil {
+		t.Fatalf("VegaLiteToPNG: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("png.Decode: %v", err)
+	}
+	if w := img.Bounds().Dx(); w != 400 {
+		t.Errorf("expected a 400px wide PNG, got %d", w)
+	}
+}
+
+func TestWithSVGClassPrefix(t *testing.T) {
+	spec, err := os.ReadFile("testdata/bar-chart.vl.json")
+	if err != nil {
+		t.Fatalf("reading test spec: %v", err)
+	}
+
+	c, err := aster.New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	svg, err := c.VegaLiteToSVG(spec, aster.WithSVGClassPrefix("chart1-"))
+	if err != nil {
+		t.Fatalf("VegaLiteToSVG: %v", err)
+	}
+	if !strings.Contains(svg, `class="chart1-marks"`) || !strings.Contains(svg, "chart1-mark-rect") {
+		t.Errorf("expected prefixed class names, got: %.300s", svg)
+	}
+	for _, m := range regexp.MustCompile(`class="([^"]*)"`).FindAllStringSubmatch(svg, -1) {
+		for _, name := range strings.Fields(m[1]) {
+			if !strings.HasPrefix(name, "chart1-") {
+				t.Errorf("unprefixed class %q", name)
+			}
+		}
+	}
+	for _, m := range regexp.MustCompile(`url\(#([^)]+)\)`).FindAllStringSubmatch(svg, -1) {
+		if !strings.HasPrefix(m[1], "chart1-") || !strings.Contains(svg, `id="`+m[1]+`"`) {
+			t.Errorf("reference url(#%s) should be prefixed and resolve to an id", m[1])
+		}
+	}
+}
+
+func TestWithUniqueIDs(t *testing.T) {
+	// Bars in a clipped layer give the SVG a clip-path definition.
+	spec := []byte(`{
+		"data": {"values": [{"a": "A", "b": 28}, {"a": "B", "b": 55}]},
+		"mark": {"type": "bar", "clip": true},
+		"encoding": {
+			"x": {"field": "a", "type": "nominal"},
+			"y": {"field": "b", "type": "quantitative"}
+		}
+	}`)
+
+	c, err := aster.New(aster.WithUniqueIDs())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	idRe := regexp.MustCompile(`\sid="([^"]+)"`)
+	ids := func() map[string]bool {
+		svg, err := c.VegaLiteToSVG(spec)
+		if err != nil {
+			t.Fatalf("VegaLiteToSVG: %v", err)
+		}
+		found := make(map[string]bool)
+		for _, m := range idRe.FindAllStringSubmatch(svg, -1) {
+			found[m[1]] = true
+		}
+		for _, m := range regexp.MustCompile(`url\(#([^)]+)\)`).FindAllStringSubmatch(svg, -1) {
+			if !found[m[1]] {
+				t.Errorf("url(#%s) does not resolve to an id in its own SVG", m[1])
+			}
+		}
+		return found
+	}
+
+	first, second := ids(), ids()
+	if len(first) == 0 {
+		t.Fatal("expected the clipped chart to define ids")
+	}
+	for id := range first {
+		if second[id] {
+			t.Errorf("id %q repeated across renders", id)
+		}
+	}
+}
+
+func TestVegaLiteToLegendSVG(t *testing.T) {
+	c, err := aster.New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	spec := []byte(`{
+		"data": {"values": [{"a": "x", "b": 1}, {"a": "y", "b": 2}]},
+		"mark": "bar",
+		"encoding": {
+			"x": {"field": "a", "type": "nominal"},
+			"y": {"field": "b", "type": "quantitative"},
+			"color": {"field": "a", "type": "nominal"}
+		}
+	}`)
+	svg, err := c.VegaLiteToLegendSVG(spec)
+	if err != nil {
+		t.Fatalf("VegaLiteToLegendSVG: %v", err)
+	}
+	if !strings.Contains(svg, "role-legend") {
+		t.Errorf("expected a legend group: %.300s", svg)
+	}
+	if strings.Contains(svg, "role-axis") || strings.Contains(svg, "role-mark") {
+		t.Errorf("expected axes and marks to be dropped: %.300s", svg)
+	}
+	full, err := c.VegaLiteToSVG(spec)
+	if err != nil {
+		t.Fatalf("VegaLiteToSVG: %v", err)
+	}
+	fw, _ := svgSize(t, full)
+	lw, _ := svgSize(t, svg)
+	if lw <= 0 || lw >= fw {
+		t.Errorf("legend width %v should be positive and below the chart's %v", lw, fw)
+	}
+
+	noLegend := []byte(`{"data": {"values": [{"b": 1}]}, "mark": "point", "encoding": {"y": {"field": "b", "type": "quantitative"}}}`)
+	if _, err := c.VegaLiteToLegendSVG(noLegend); err == nil || !strings.Contains(err.Error(), "no legend") {
+		t.Errorf("expected a no-legend error, got %v", err)
+	}
+}
+
+func TestWithNativeTooltips(t *testing.T) {
+	spec := []byte(`{
+		"data": {"values": [{"a": "x", "b": 1}, {"a": "y", "b": 2}]},
+		"mark": "bar",
+		"encoding": {
+			"x": {"field": "a", "type": "nominal"},
+			"y": {"field": "b", "type": "quantitative"},
+			"tooltip": [{"field": "a"}, {"field": "b"}]
+		}
+	}`)
+
+	c, err := aster.New(aster.WithNativeTooltips())
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+	svg, err := c.VegaLiteToSVG(spec)
+	if err != nil {
+		t.Fatalf("VegaLiteToSVG: %v", err)
+	}
+	for _, want := range []string{"<title>a: x\nb: 1</title>", "<title>a: y\nb: 2</title>"} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("expected %q in output: %s", want, svg)
+		}
+	}
+
+	plain, err := aster.New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = plain.Close() }()
+	svg, err = plain.VegaLiteToSVG(spec)
+	if err != nil {
+		t.Fatalf("VegaLiteToSVG: %v", err)
+	}
+	if strings.Contains(svg, "<title>") {
+		t.Errorf("expected no <title> without WithNativeTooltips: %.300s", svg)
+	}
+}
+
+func TestWithRenderDefaultFont(t *testing.T) {
+	// A pad-autosized chart grows to fit its text, so the SVG width follows
+	// the measured width of a label in a family that is not registered.
+	spec := []byte(`{
+		"$schema": "https://vega.github.io/schema/vega/v5.json",
+		"width": 1, "height": 1, "autosize": "pad", "padding": 0,
+		"marks": [{
+			"type": "text",
+			"encode": {"enter": {
+				"text": {"value": "iiiiiiiiii"},
+				"font": {"value": "Unknown Font"},
+				"fontSize": {"value": 20}
+			}}
+		}]
+	}`)
+
+	c, err := aster.New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	width := func(opts ...aster.RenderOption) float64 {
+		t.Helper()
+		svg, err := c.VegaToSVG(spec, opts...)
+		if err != nil {
+			t.Fatalf("VegaToSVG: %v", err)
+		}
+		w, _ := svgSize(t, svg)
+		return w
+	}
+
+	sans := width()
+	if mono := width(aster.WithRenderDefaultFont("Liberation Mono")); mono <= sans {
+		t.Errorf("Liberation Mono default should widen the narrow label: %v <= %v", mono, sans)
+	}
+	if again := width(); again != sans {
+		t.Errorf("default font should be restored after the render: width %v, want %v", again, sans)
+	}
+}
+
+func TestWithFlattenGradients(t *testing.T) {
+	spec := []byte(`{
+		"data": {"values": [{"x": 1}, {"x": 2}]},
+		"mark": {"type": "bar", "color": {
+			"gradient": "linear",
+			"stops": [{"offset": 0, "color": "#000000"}, {"offset": 1, "color": "#ffffff"}]
+		}},
+		"encoding": {"x": {"field": "x", "type": "ordinal"}}
+	}`)
+
+	c, err := aster.New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	plain, err := c.VegaLiteToSVG(spec)
+	if err != nil {
+		t.Fatalf("VegaLiteToSVG: %v", err)
+	}
+	if !strings.Contains(plain, "<linearGradient") {
+		t.Fatalf("expected a gradient without the option, got: %.300s", plain)
+	}
+
+	svg, err := c.VegaLiteToSVG(spec, aster.WithFlattenGradients())
+	if err != nil {
+		t.Fatalf("VegaLiteToSVG: %v", err)
+	}
+	if strings.Contains(svg, "Gradient") || strings.Contains(svg, "url(#") {
+		t.Errorf("expected gradients flattened, got: %.300s", svg)
+	}
+	if !strings.Contains(svg, `fill="#808080"`) {
+		t.Errorf("expected the midpoint color as the bar fill, got: %.300s", svg)
+	}
+}
+
+func TestWithRenderTextMeasurement(t *testing.T) {
+	// As in TestWithRenderDefaultFont, the SVG width follows the label's.
+	spec := []byte(`{
+		"$schema": "https://vega.github.io/schema/vega/v5.json",
+		"width": 1, "height": 1, "autosize": "pad", "padding": 0,
+		"marks": [{
+			"type": "text",
+			"encode": {"enter": {"text": {"value": "iiiiiiiiii"}, "fontSize": {"value": 20}}}
+		}]
+	}`)
+
+	width := func(c *aster.Converter, opts ...aster.RenderOption) float64 {
+		t.Helper()
+		svg, err := c.VegaToSVG(spec, opts...)
+		if err != nil {
+			t.Fatalf("VegaToSVG: %v", err)
+		}
+		w, _ := svgSize(t, svg)
+		return w
+	}
+
+	c, err := aster.New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+	estimating, err := aster.New(aster.WithTextMeasurement(false))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = estimating.Close() }()
+
+	measured := width(c)
+	estimated := width(estimating)
+	if measured == estimated {
+		t.Fatalf("measured and estimated widths should differ for narrow glyphs, both %v", measured)
+	}
+	if got := width(c, aster.WithRenderTextMeasurement(false)); got != estimated {
+		t.Errorf("WithRenderTextMeasurement(false) width %v, want the estimate %v", got, estimated)
+	}
+	if again := width(c); again != measured {
+		t.Errorf("measurement should be restored after the render: width %v, want %v", again, measured)
+	}
+}
+
+func TestWithSourceMetadata(t *testing.T) {
+	spec, err := os.ReadFile("testdata/bar-chart.vl.json")
+	if err != nil {
+		t.Fatalf("reading test spec: %v", err)
+	}
+	versions, err := aster.AvailableVersions()
+	if err != nil {
+		t.Fatalf("AvailableVersions: %v", err)
+	}
+
+	plain, err := aster.New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = plain.Close() }()
+	svg, err := plain.VegaLiteToSVG(spec)
+	if err != nil {
+		t.Fatalf("VegaLiteToSVG: %v", err)
+	}
+	if strings.Contains(svg, "<metadata") {
+		t.Errorf("metadata should be off by default: %.300s", svg)
+	}
+
+	c, err := aster.New(aster.WithSourceMetadata(), aster.WithVegaLiteVersion(versions[0].Key))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer func() { _ = c.Close() }()
+
+	sum := sha256.Sum256(spec)
+	want := `spec-sha256="` + hex.EncodeToString(sum[:]) + `"`
+	for name, render := range map[string]func() (string, error){
+		"VegaLiteToSVG": func() (string, error) { return c.VegaLiteToSVG(spec) },
+		// The hash is of the spec as passed, before the params are set.
+		"VegaLiteToSVGWithParams": func() (string, error) { return c.VegaLiteToSVGWithParams(spec, nil) },
+	} {
+		svg, err := render()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !strings.Contains(svg, "<metadata><aster:source") || !strings.Contains(svg, want) {
+			t.Errorf("%s: expected source metadata with %s, got: %.500s", name, want, svg)
+		}
+		if !strings.Contains(svg, `vega-lite-version="`+versions[0].VegaLite+`"`) {
+			t.Errorf("%s: expected Vega-Lite version %s, got: %.500s", name, versions[0].VegaLite, svg)
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package aster
+
+import "sync"
+
+// Synchronized wraps a Converter so that its methods may be called from
+// several goroutines: a mutex serializes every call, so concurrent callers
+// wait their turn instead of corrupting the single-threaded runtime. This
+// trades throughput for safety, since only one render runs at a time; for
+// parallel rendering, give each goroutine its own Converter instead.
+//
+// Warnings is not wrapped: with several callers, the most recent call may
+// be another goroutine's. Use WithStrictWarnings to get a call's warnings
+// in its error.
+type Synchronized struct {
+	mu sync.Mutex
+	c  *Converter
+}
+
+// NewSynchronized returns a Synchronized wrapping c. All calls on c must
+// then go through the wrapper.
+func NewSynchronized(c *Converter) *Synchronized {
+	return &Synchronized{c: c}
+}
+
+// Close closes the wrapped Converter, after any call in progress.
+func (s *Synchronized) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.c.Close()
+}
+
+// SchemaURL calls Converter.SchemaURL, which only reads the Converter's
+// configuration, without taking the lock.
+func (s *Synchronized) SchemaURL() string {
+	return s.c.SchemaURL()
+}
+
+// VegaToSVG calls Converter.VegaToSVG.
+func (s *Synchronized) VegaToSVG(spec []byte, opts ...RenderOption) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.c.VegaToSVG(spec, opts...)
+}
+
+// VegaLiteToSVG calls Converter.VegaLiteToSVG.
+func (s *Synchronized) VegaLiteToSVG(spec []byte, opts ...RenderOption) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.c.VegaLiteToSVG(spec, opts...)
+}
+
+// VegaLiteToVega calls Converter.VegaLiteToVega.
+func (s *Synchronized) VegaLiteToVega(spec []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.c.VegaLiteToVega(spec)
+}
+
+// CompileMany calls Converter.CompileMany, holding the lock for the whole
+// batch.
+func (s *Synchronized) CompileMany(specs [][]byte) ([][]byte, []error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.c.CompileMany(specs)
+}
+
+// VegaToPNG calls Converter.VegaToPNG.
+func (s *Synchronized) VegaToPNG(spec []byte, opts ...PNGOption) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.c.VegaToPNG(spec, opts...)
+}
+
+// VegaLiteToPNG calls Converter.VegaLiteToPNG.
+func (s *Synchronized) VegaLiteToPNG(spec []byte, opts ...PNGOption) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.c.VegaLiteToPNG(spec, opts...)
+}
+
+// VegaLiteToSVGAndPNG calls Converter.VegaLiteToSVGAndPNG.
+func (s *Synchronized) VegaLiteToSVGAndPNG(spec []byte, opts ...RenderOption) (string, []byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.c.VegaLiteToSVGAndPNG(spec, opts...)
+}
+
+// VegaLiteToPNGMulti calls Converter.VegaLiteToPNGMulti.
+func (s *Synchronized) VegaLiteToPNGMulti(spec []byte, scales []float64, opts ...PNGOption) (map[float64][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.c.VegaLiteToPNGMulti(spec, scales, opts...)
+}
+
+// VegaToPDF calls Converter.VegaToPDF.
+func (s *Synchronized) VegaToPDF(spec []byte, opts ...PNGOption) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.c.VegaToPDF(spec, opts...)
+}
+
+// VegaLiteToPDF calls Converter.VegaLiteToPDF.
+func (s *Synchronized) VegaLiteToPDF(spec []byte, opts ...PNGOption) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.c.VegaLiteToPDF(spec, opts...)
+}
+
+// SVGToPNG calls Converter.SVGToPNG, which is already safe for concurrent
+// use, without taking the lock.
+func (s *Synchronized) SVGToPNG(svg string, opts ...PNGOption) ([]byte, error) {
+	return s.c.SVGToPNG(svg, opts...)
+}
+
+// VegaLiteToLegendSVG calls Converter.VegaLiteToLegendSVG.
+func (s *Synchronized) VegaLiteToLegendSVG(spec []byte, opts ...RenderOption) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.c.VegaLiteToLegendSVG(spec, opts...)
+}
+
+// VegaLiteToSVGWithParams calls Converter.VegaLiteToSVGWithParams.
+func (s *Synchronized) VegaLiteToSVGWithParams(spec []byte, params map[string]any, opts ...RenderOption) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.c.VegaLiteToSVGWithParams(spec, params, opts...)
+}
+
+// VegaLiteToSVGWithScales calls Converter.VegaLiteToSVGWithScales.
+func (s *Synchronized) VegaLiteToSVGWithScales(spec []byte, domains map[string][2]float64, opts ...RenderOption) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.c.VegaLiteToSVGWithScales(spec, domains, opts...)
+}
+
+// VegaLiteToData calls Converter.VegaLiteToData.
+func (s *Synchronized) VegaLiteToData(spec []byte, format string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.c.VegaLiteToData(spec, format)
+}
+
+// ContentBounds calls Converter.ContentBounds.
+func (s *Synchronized) ContentBounds(spec []byte) (x, y, w, h float64, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.c.ContentBounds(spec)
+}
+
+// UsedFonts calls Converter.UsedFonts.
+func (s *Synchronized) UsedFonts(spec []byte) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.c.UsedFonts(spec)
+}
+
+// LintVegaLite calls Converter.LintVegaLite.
+func (s *Synchronized) LintVegaLite(spec []byte) ([]LintFinding, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.c.LintVegaLite(spec)
+}
+
+// ListResources calls Converter.ListResources.
+func (s *Synchronized) ListResources(spec []byte) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.c.ListResources(spec)
+}
+
+// Signals calls Converter.Signals.
+func (s *Synchronized) Signals(spec []byte) (map[string]any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.c.Signals(spec)
+}
+
+// ExportState calls Converter.ExportState.
+func (s *Synchronized) ExportState(spec []byte) (*ViewState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.c.ExportState(spec)
+}
+
+// ImportState calls Converter.ImportState.
+func (s *Synchronized) ImportState(spec []byte, state *ViewState) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.c.ImportState(spec, state)
+}
@@ -0,0 +1,65 @@
+package aster_test
+
+import (
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mgilbir/aster"
+)
+
+// TestSynchronizedConcurrent renders from several goroutines through one
+// Synchronized converter. Run it with -race to check the calls are
+// serialized.
+func TestSynchronizedConcurrent(t *testing.T) {
+	spec, err := os.ReadFile("testdata/bar-chart.vl.json")
+	if err != nil {
+		t.Fatalf("reading test spec: %v", err)
+	}
+
+	c, err := aster.New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	s := aster.NewSynchronized(c)
+	defer func() { _ = s.Close() }()
+
+	want, err := s.VegaLiteToSVG(spec)
+	if err != nil {
+		t.Fatalf("VegaLiteToSVG: %v", err)
+	}
+
+	const workers = 4
+	errs := make(chan error, workers*3)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			svg, err := s.VegaLiteToSVG(spec)
+			if err != nil {
+				errs <- err
+				return
+			}
+			if svg != want {
+				t.Errorf("concurrent render differs from the first")
+			}
+			if _, err := s.VegaLiteToVega(spec); err != nil {
+				errs <- err
+			}
+			if _, err := s.VegaLiteToPNG(spec); err != nil {
+				errs <- err
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if !strings.HasPrefix(want, "<svg") {
+		t.Errorf("expected SVG output, got: %.100s", want)
+	}
+}
@@ -0,0 +1,55 @@
+package aster
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+)
+
+// trimPNG crops the border of a PNG that matches its top-left pixel: fully
+// transparent pixels, or the background color a spec paints across the whole
+// chart. An image that is all border is returned unchanged.
+func trimPNG(data []byte) ([]byte, error) {
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("aster: decoding PNG to trim: %w", err)
+	}
+	b := img.Bounds()
+	r0, g0, b0, a0 := img.At(b.Min.X, b.Min.Y).RGBA()
+	border := func(x, y int) bool {
+		r, g, bl, a := img.At(x, y).RGBA()
+		if a0 == 0 {
+			return a == 0
+		}
+		return r == r0 && g == g0 && bl == b0 && a == a0
+	}
+
+	crop := image.Rectangle{Min: b.Max, Max: b.Min}
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			if border(x, y) {
+				continue
+			}
+			crop.Min.X = min(crop.Min.X, x)
+			crop.Min.Y = min(crop.Min.Y, y)
+			crop.Max.X = max(crop.Max.X, x+1)
+			crop.Max.Y = max(crop.Max.Y, y+1)
+		}
+	}
+	if crop.Empty() || crop == b {
+		return data, nil
+	}
+
+	sub, ok := img.(interface {
+		SubImage(r image.Rectangle) image.Image
+	})
+	if !ok {
+		return data, nil
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, sub.SubImage(crop)); err != nil {
+		return nil, fmt.Errorf("aster: encoding trimmed PNG: %w", err)
+	}
+	return buf.Bytes(), nil
+}
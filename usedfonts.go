@@ -0,0 +1,33 @@
+package aster
+
+import (
+	"errors"
+	"slices"
+)
+
+// UsedFonts renders a Vega-Lite spec (JSON) like VegaLiteToSVG and returns
+// the distinct font families its text asked for during measurement, sorted,
+// including generic families such as "sans-serif". Font strings are parsed
+// as measurement parsed them, with WithFontParser if set. Every family of a
+// fallback list is reported, whether or not it is available, so the result
+// shows which fonts to register with WithFont for the spec to render as
+// designed. It fails if text measurement is disabled, since Vega's
+// estimation does not look at fonts.
+func (c *Converter) UsedFonts(spec []byte) ([]string, error) {
+	if c.measurer == nil {
+		return nil, errors.New("aster: UsedFonts needs text measurement (WithTextMeasurement)")
+	}
+	if _, err := c.VegaLiteToSVG(spec); err != nil {
+		return nil, err
+	}
+	families := []string{}
+	for _, cssFont := range c.rt.MeasuredFonts() {
+		for _, family := range c.measurer.ParseFont(cssFont).Family {
+			if !slices.Contains(families, family) {
+				families = append(families, family)
+			}
+		}
+	}
+	slices.Sort(families)
+	return families, nil
+}
@@ -0,0 +1,74 @@
+package aster
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/mgilbir/aster/internal/runtime"
+)
+
+// VersionInfo describes one vendored Vega/Vega-Lite version set.
+type VersionInfo struct {
+	Key      string // value accepted by WithVegaLiteVersion, e.g. "5.8"
+	Vega     string // Vega release, e.g. "5.25.0"
+	VegaLite string // Vega-Lite release, e.g. "5.8.0"
+}
+
+// AvailableVersions lists the version sets embedded in this build, sorted by
+// key.
+func AvailableVersions() ([]VersionInfo, error) {
+	sets, err := runtime.AvailableVersions()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]VersionInfo, 0, len(sets))
+	for key, v := range sets {
+		out = append(out, VersionInfo{
+			Key:      strings.ReplaceAll(strings.TrimPrefix(key, "vl"), "_", "."),
+			Vega:     v.VegaVersion,
+			VegaLite: v.VegaLiteVersion,
+		})
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out, nil
+}
+
+// VersionKey maps a Vega-Lite version as accepted by WithVegaLiteVersion,
+// e.g. "5.8", to the internal key of its version set, "vl5_8".
+func VersionKey(v string) string {
+	return "vl" + strings.ReplaceAll(v, ".", "_")
+}
+
+// HasVegaLiteVersion reports whether this build embeds the version set that
+// WithVegaLiteVersion(v) selects, so callers can check a user's choice before
+// calling New.
+func HasVegaLiteVersion(v string) bool {
+	sets, err := runtime.AvailableVersions()
+	if err != nil {
+		return false
+	}
+	_, ok := sets[VersionKey(v)]
+	return ok
+}
+
+// SchemaURL returns the JSON schema URL of the Vega-Lite version the
+// Converter compiles with, e.g. "https://vega.github.io/schema/vega-lite/v6.json",
+// so editors and validators can check specs against the schema aster will
+// actually accept. The URL names the major version, as the $schema of most
+// specs does.
+func (c *Converter) SchemaURL() string {
+	key := c.rt.Version()
+	release := strings.ReplaceAll(strings.TrimPrefix(key, "vl"), "_", ".")
+	if sets, err := runtime.AvailableVersions(); err == nil && sets[key].VegaLiteVersion != "" {
+		release = sets[key].VegaLiteVersion
+	}
+	major, _, _ := strings.Cut(release, ".")
+	return "https://vega.github.io/schema/vega-lite/v" + major + ".json"
+}
+
+// EmbeddedFontFamilies lists the font families compiled into every build.
+// They are available for text measurement and PNG rendering unless the
+// Converter is created with WithoutEmbeddedFonts.
+func EmbeddedFontFamilies() []string {
+	return []string{"Liberation Sans", "Liberation Mono"}
+}
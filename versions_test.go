@@ -0,0 +1,68 @@
+package aster_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mgilbir/aster"
+)
+
+func TestAvailableVersions(t *testing.T) {
+	versions, err := aster.AvailableVersions()
+	if err != nil {
+		t.Fatalf("AvailableVersions: %v", err)
+	}
+	if len(versions) == 0 {
+		t.Fatal("expected at least one embedded version set")
+	}
+	for _, v := range versions {
+		if v.Key == "" || v.Vega == "" || v.VegaLite == "" {
+			t.Errorf("incomplete version info: %+v", v)
+		}
+		// Keys round-trip through WithVegaLiteVersion.
+		c, err := aster.New(aster.WithVegaLiteVersion(v.Key), aster.WithTextMeasurement(false))
+		if err != nil {
+			t.Errorf("New(WithVegaLiteVersion(%q)): %v", v.Key, err)
+			continue
+		}
+		_ = c.Close()
+	}
+}
+
+func TestHasVegaLiteVersion(t *testing.T) {
+	if got := aster.VersionKey("5.8"); got != "vl5_8" {
+		t.Errorf(`VersionKey("5.8") = %q, want "vl5_8"`, got)
+	}
+
+	versions, err := aster.AvailableVersions()
+	if err != nil {
+		t.Fatalf("AvailableVersions: %v", err)
+	}
+	for _, v := range versions {
+		if !aster.HasVegaLiteVersion(v.Key) {
+			t.Errorf("HasVegaLiteVersion(%q) = false for an available version", v.Key)
+		}
+	}
+	if aster.HasVegaLiteVersion("0.1") {
+		t.Error(`HasVegaLiteVersion("0.1") = true`)
+	}
+}
+
+func TestSchemaURL(t *testing.T) {
+	versions, err := aster.AvailableVersions()
+	if err != nil {
+		t.Fatalf("AvailableVersions: %v", err)
+	}
+	for _, v := range versions {
+		c, err := aster.New(aster.WithVegaLiteVersion(v.Key), aster.WithTextMeasurement(false))
+		if err != nil {
+			t.Fatalf("New(WithVegaLiteVersion(%q)): %v", v.Key, err)
+		}
+		major, _, _ := strings.Cut(v.VegaLite, ".")
+		want := "https://vega.github.io/schema/vega-lite/v" + major + ".json"
+		if got := c.SchemaURL(); got != want {
+			t.Errorf("SchemaURL for %s = %q, want %q", v.Key, got, want)
+		}
+		_ = c.Close()
+	}
+}
@@ -0,0 +1,25 @@
+package aster
+
+import (
+	"slices"
+
+	"github.com/mgilbir/aster/internal/runtime"
+)
+
+// WarningsError is the error returned when a Converter created with
+// WithStrictWarnings logs Vega or Vega-Lite warnings during a call. Its
+// Warnings field lists the messages in the order they were logged.
+type WarningsError = runtime.WarningsError
+
+// Warnings returns the Vega and Vega-Lite warnings logged by the Converter's
+// most recent render or compile, such as Vega-Lite's notices about dropped
+// encodings or deprecated properties. Warnings are captured whatever the
+// WithLogLevel setting. Sizes lowered by WithScaleClamp are reported first,
+// without failing a WithStrictWarnings render. The result is nil if the call
+// logged none.
+func (c *Converter) Warnings() []string {
+	if len(c.clamps) == 0 {
+		return c.rt.Warnings()
+	}
+	return append(slices.Clone(c.clamps), c.rt.Warnings()...)
+}